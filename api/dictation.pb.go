@@ -0,0 +1,739 @@
+// The daemon's gRPC control API, served when grpc_listen is set and the
+// binary was built with -tags grpc. It does what the hotkey and the HTTP API
+// on the unix socket do: start and stop recordings, which are transcribed
+// and inserted as usual, cancel them, transcribe files and follow the
+// results.
+//
+// Regenerate the Go code with
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative api/dictation.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: api/dictation.proto
+
+package dictationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Status_State int32
+
+const (
+	Status_STATE_UNSPECIFIED Status_State = 0
+	Status_IDLE              Status_State = 1
+	Status_RECORDING         Status_State = 2
+	Status_TRANSCRIBING      Status_State = 3
+)
+
+// Enum value maps for Status_State.
+var (
+	Status_State_name = map[int32]string{
+		0: "STATE_UNSPECIFIED",
+		1: "IDLE",
+		2: "RECORDING",
+		3: "TRANSCRIBING",
+	}
+	Status_State_value = map[string]int32{
+		"STATE_UNSPECIFIED": 0,
+		"IDLE":              1,
+		"RECORDING":         2,
+		"TRANSCRIBING":      3,
+	}
+)
+
+func (x Status_State) Enum() *Status_State {
+	p := new(Status_State)
+	*p = x
+	return p
+}
+
+func (x Status_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Status_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_dictation_proto_enumTypes[0].Descriptor()
+}
+
+func (Status_State) Type() protoreflect.EnumType {
+	return &file_api_dictation_proto_enumTypes[0]
+}
+
+func (x Status_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Status_State.Descriptor instead.
+func (Status_State) EnumDescriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{6, 0}
+}
+
+type StartRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// profile is applied to the recording when set.
+	Profile string `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
+	// translate has the speech translated to English.
+	Translate     bool `protobuf:"varint,2,opt,name=translate,proto3" json:"translate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartRequest) Reset() {
+	*x = StartRequest{}
+	mi := &file_api_dictation_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartRequest) ProtoMessage() {}
+
+func (x *StartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartRequest.ProtoReflect.Descriptor instead.
+func (*StartRequest) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *StartRequest) GetTranslate() bool {
+	if x != nil {
+		return x.Translate
+	}
+	return false
+}
+
+type StopRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopRequest) Reset() {
+	*x = StopRequest{}
+	mi := &file_api_dictation_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopRequest) ProtoMessage() {}
+
+func (x *StopRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopRequest.ProtoReflect.Descriptor instead.
+func (*StopRequest) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{1}
+}
+
+type CancelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelRequest) Reset() {
+	*x = CancelRequest{}
+	mi := &file_api_dictation_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelRequest) ProtoMessage() {}
+
+func (x *CancelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
+func (*CancelRequest) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{2}
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_api_dictation_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{3}
+}
+
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_api_dictation_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{4}
+}
+
+type TranscribeFileRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Source:
+	//
+	//	*TranscribeFileRequest_Path
+	//	*TranscribeFileRequest_Audio
+	Source isTranscribeFileRequest_Source `protobuf_oneof:"source"`
+	// name is the file name for audio, e.g. "memo.ogg" (default "audio.wav").
+	Name string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// profile is applied to the transcription when set.
+	Profile       string `protobuf:"bytes,4,opt,name=profile,proto3" json:"profile,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TranscribeFileRequest) Reset() {
+	*x = TranscribeFileRequest{}
+	mi := &file_api_dictation_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TranscribeFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscribeFileRequest) ProtoMessage() {}
+
+func (x *TranscribeFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscribeFileRequest.ProtoReflect.Descriptor instead.
+func (*TranscribeFileRequest) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TranscribeFileRequest) GetSource() isTranscribeFileRequest_Source {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *TranscribeFileRequest) GetPath() string {
+	if x != nil {
+		if x, ok := x.Source.(*TranscribeFileRequest_Path); ok {
+			return x.Path
+		}
+	}
+	return ""
+}
+
+func (x *TranscribeFileRequest) GetAudio() []byte {
+	if x != nil {
+		if x, ok := x.Source.(*TranscribeFileRequest_Audio); ok {
+			return x.Audio
+		}
+	}
+	return nil
+}
+
+func (x *TranscribeFileRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TranscribeFileRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+type isTranscribeFileRequest_Source interface {
+	isTranscribeFileRequest_Source()
+}
+
+type TranscribeFileRequest_Path struct {
+	// path is a file the daemon can read.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3,oneof"`
+}
+
+type TranscribeFileRequest_Audio struct {
+	// audio is the file's contents; name then gives its format.
+	Audio []byte `protobuf:"bytes,2,opt,name=audio,proto3,oneof"`
+}
+
+func (*TranscribeFileRequest_Path) isTranscribeFileRequest_Source() {}
+
+func (*TranscribeFileRequest_Audio) isTranscribeFileRequest_Source() {}
+
+type Status struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	State Status_State           `protobuf:"varint,1,opt,name=state,proto3,enum=dictation.v1.Status_State" json:"state,omitempty"`
+	// elapsed_ms is how long the recording has been going.
+	ElapsedMs     int64 `protobuf:"varint,2,opt,name=elapsed_ms,json=elapsedMs,proto3" json:"elapsed_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	mi := &file_api_dictation_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Status) GetState() Status_State {
+	if x != nil {
+		return x.State
+	}
+	return Status_STATE_UNSPECIFIED
+}
+
+func (x *Status) GetElapsedMs() int64 {
+	if x != nil {
+		return x.ElapsedMs
+	}
+	return 0
+}
+
+type Transcript struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id is the transcript's id in the history; empty for TranscribeFile.
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Text     string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Provider string `protobuf:"bytes,3,opt,name=provider,proto3" json:"provider,omitempty"`
+	Model    string `protobuf:"bytes,4,opt,name=model,proto3" json:"model,omitempty"`
+	// language is the spoken language, when the provider reports it.
+	Language string `protobuf:"bytes,5,opt,name=language,proto3" json:"language,omitempty"`
+	// time_unix_ms is when the transcript was made.
+	TimeUnixMs    int64 `protobuf:"varint,6,opt,name=time_unix_ms,json=timeUnixMs,proto3" json:"time_unix_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transcript) Reset() {
+	*x = Transcript{}
+	mi := &file_api_dictation_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transcript) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transcript) ProtoMessage() {}
+
+func (x *Transcript) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transcript.ProtoReflect.Descriptor instead.
+func (*Transcript) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Transcript) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Transcript) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Transcript) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Transcript) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Transcript) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *Transcript) GetTimeUnixMs() int64 {
+	if x != nil {
+		return x.TimeUnixMs
+	}
+	return 0
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*Event_Status
+	//	*Event_Transcript
+	Event         isEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_api_dictation_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dictation_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_api_dictation_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Event) GetEvent() isEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *Event) GetStatus() *Status {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Status); ok {
+			return x.Status
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetTranscript() *Transcript {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Transcript); ok {
+			return x.Transcript
+		}
+	}
+	return nil
+}
+
+type isEvent_Event interface {
+	isEvent_Event()
+}
+
+type Event_Status struct {
+	Status *Status `protobuf:"bytes,1,opt,name=status,proto3,oneof"`
+}
+
+type Event_Transcript struct {
+	Transcript *Transcript `protobuf:"bytes,2,opt,name=transcript,proto3,oneof"`
+}
+
+func (*Event_Status) isEvent_Event() {}
+
+func (*Event_Transcript) isEvent_Event() {}
+
+var File_api_dictation_proto protoreflect.FileDescriptor
+
+const file_api_dictation_proto_rawDesc = "" +
+	"\n" +
+	"\x13api/dictation.proto\x12\fdictation.v1\"F\n" +
+	"\fStartRequest\x12\x18\n" +
+	"\aprofile\x18\x01 \x01(\tR\aprofile\x12\x1c\n" +
+	"\ttranslate\x18\x02 \x01(\bR\ttranslate\"\r\n" +
+	"\vStopRequest\"\x0f\n" +
+	"\rCancelRequest\"\x0f\n" +
+	"\rStatusRequest\"\x0e\n" +
+	"\fWatchRequest\"}\n" +
+	"\x15TranscribeFileRequest\x12\x14\n" +
+	"\x04path\x18\x01 \x01(\tH\x00R\x04path\x12\x16\n" +
+	"\x05audio\x18\x02 \x01(\fH\x00R\x05audio\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x18\n" +
+	"\aprofile\x18\x04 \x01(\tR\aprofileB\b\n" +
+	"\x06source\"\xa4\x01\n" +
+	"\x06Status\x120\n" +
+	"\x05state\x18\x01 \x01(\x0e2\x1a.dictation.v1.Status.StateR\x05state\x12\x1d\n" +
+	"\n" +
+	"elapsed_ms\x18\x02 \x01(\x03R\telapsedMs\"I\n" +
+	"\x05State\x12\x15\n" +
+	"\x11STATE_UNSPECIFIED\x10\x00\x12\b\n" +
+	"\x04IDLE\x10\x01\x12\r\n" +
+	"\tRECORDING\x10\x02\x12\x10\n" +
+	"\fTRANSCRIBING\x10\x03\"\xa0\x01\n" +
+	"\n" +
+	"Transcript\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x1a\n" +
+	"\bprovider\x18\x03 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05model\x18\x04 \x01(\tR\x05model\x12\x1a\n" +
+	"\blanguage\x18\x05 \x01(\tR\blanguage\x12 \n" +
+	"\ftime_unix_ms\x18\x06 \x01(\x03R\n" +
+	"timeUnixMs\"|\n" +
+	"\x05Event\x12.\n" +
+	"\x06status\x18\x01 \x01(\v2\x14.dictation.v1.StatusH\x00R\x06status\x12:\n" +
+	"\n" +
+	"transcript\x18\x02 \x01(\v2\x18.dictation.v1.TranscriptH\x00R\n" +
+	"transcriptB\a\n" +
+	"\x05event2\x8d\x03\n" +
+	"\tDictation\x129\n" +
+	"\x05Start\x12\x1a.dictation.v1.StartRequest\x1a\x14.dictation.v1.Status\x12;\n" +
+	"\x04Stop\x12\x19.dictation.v1.StopRequest\x1a\x18.dictation.v1.Transcript\x12;\n" +
+	"\x06Cancel\x12\x1b.dictation.v1.CancelRequest\x1a\x14.dictation.v1.Status\x12>\n" +
+	"\tGetStatus\x12\x1b.dictation.v1.StatusRequest\x1a\x14.dictation.v1.Status\x12O\n" +
+	"\x0eTranscribeFile\x12#.dictation.v1.TranscribeFileRequest\x1a\x18.dictation.v1.Transcript\x12:\n" +
+	"\x05Watch\x12\x1a.dictation.v1.WatchRequest\x1a\x13.dictation.v1.Event0\x01B+Z)github.com/user/dictation/api;dictationpbb\x06proto3"
+
+var (
+	file_api_dictation_proto_rawDescOnce sync.Once
+	file_api_dictation_proto_rawDescData []byte
+)
+
+func file_api_dictation_proto_rawDescGZIP() []byte {
+	file_api_dictation_proto_rawDescOnce.Do(func() {
+		file_api_dictation_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_dictation_proto_rawDesc), len(file_api_dictation_proto_rawDesc)))
+	})
+	return file_api_dictation_proto_rawDescData
+}
+
+var file_api_dictation_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_dictation_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_api_dictation_proto_goTypes = []any{
+	(Status_State)(0),             // 0: dictation.v1.Status.State
+	(*StartRequest)(nil),          // 1: dictation.v1.StartRequest
+	(*StopRequest)(nil),           // 2: dictation.v1.StopRequest
+	(*CancelRequest)(nil),         // 3: dictation.v1.CancelRequest
+	(*StatusRequest)(nil),         // 4: dictation.v1.StatusRequest
+	(*WatchRequest)(nil),          // 5: dictation.v1.WatchRequest
+	(*TranscribeFileRequest)(nil), // 6: dictation.v1.TranscribeFileRequest
+	(*Status)(nil),                // 7: dictation.v1.Status
+	(*Transcript)(nil),            // 8: dictation.v1.Transcript
+	(*Event)(nil),                 // 9: dictation.v1.Event
+}
+var file_api_dictation_proto_depIdxs = []int32{
+	0, // 0: dictation.v1.Status.state:type_name -> dictation.v1.Status.State
+	7, // 1: dictation.v1.Event.status:type_name -> dictation.v1.Status
+	8, // 2: dictation.v1.Event.transcript:type_name -> dictation.v1.Transcript
+	1, // 3: dictation.v1.Dictation.Start:input_type -> dictation.v1.StartRequest
+	2, // 4: dictation.v1.Dictation.Stop:input_type -> dictation.v1.StopRequest
+	3, // 5: dictation.v1.Dictation.Cancel:input_type -> dictation.v1.CancelRequest
+	4, // 6: dictation.v1.Dictation.GetStatus:input_type -> dictation.v1.StatusRequest
+	6, // 7: dictation.v1.Dictation.TranscribeFile:input_type -> dictation.v1.TranscribeFileRequest
+	5, // 8: dictation.v1.Dictation.Watch:input_type -> dictation.v1.WatchRequest
+	7, // 9: dictation.v1.Dictation.Start:output_type -> dictation.v1.Status
+	8, // 10: dictation.v1.Dictation.Stop:output_type -> dictation.v1.Transcript
+	7, // 11: dictation.v1.Dictation.Cancel:output_type -> dictation.v1.Status
+	7, // 12: dictation.v1.Dictation.GetStatus:output_type -> dictation.v1.Status
+	8, // 13: dictation.v1.Dictation.TranscribeFile:output_type -> dictation.v1.Transcript
+	9, // 14: dictation.v1.Dictation.Watch:output_type -> dictation.v1.Event
+	9, // [9:15] is the sub-list for method output_type
+	3, // [3:9] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_api_dictation_proto_init() }
+func file_api_dictation_proto_init() {
+	if File_api_dictation_proto != nil {
+		return
+	}
+	file_api_dictation_proto_msgTypes[5].OneofWrappers = []any{
+		(*TranscribeFileRequest_Path)(nil),
+		(*TranscribeFileRequest_Audio)(nil),
+	}
+	file_api_dictation_proto_msgTypes[8].OneofWrappers = []any{
+		(*Event_Status)(nil),
+		(*Event_Transcript)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_dictation_proto_rawDesc), len(file_api_dictation_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_dictation_proto_goTypes,
+		DependencyIndexes: file_api_dictation_proto_depIdxs,
+		EnumInfos:         file_api_dictation_proto_enumTypes,
+		MessageInfos:      file_api_dictation_proto_msgTypes,
+	}.Build()
+	File_api_dictation_proto = out.File
+	file_api_dictation_proto_goTypes = nil
+	file_api_dictation_proto_depIdxs = nil
+}
@@ -0,0 +1,346 @@
+// The daemon's gRPC control API, served when grpc_listen is set and the
+// binary was built with -tags grpc. It does what the hotkey and the HTTP API
+// on the unix socket do: start and stop recordings, which are transcribed
+// and inserted as usual, cancel them, transcribe files and follow the
+// results.
+//
+// Regenerate the Go code with
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative api/dictation.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: api/dictation.proto
+
+package dictationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Dictation_Start_FullMethodName          = "/dictation.v1.Dictation/Start"
+	Dictation_Stop_FullMethodName           = "/dictation.v1.Dictation/Stop"
+	Dictation_Cancel_FullMethodName         = "/dictation.v1.Dictation/Cancel"
+	Dictation_GetStatus_FullMethodName      = "/dictation.v1.Dictation/GetStatus"
+	Dictation_TranscribeFile_FullMethodName = "/dictation.v1.Dictation/TranscribeFile"
+	Dictation_Watch_FullMethodName          = "/dictation.v1.Dictation/Watch"
+)
+
+// DictationClient is the client API for Dictation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DictationClient interface {
+	// Start starts a recording. It fails with FAILED_PRECONDITION when one is
+	// already going on.
+	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*Status, error)
+	// Stop stops the recording, transcribes it and inserts the text, and
+	// returns the transcript. It fails with FAILED_PRECONDITION when nothing
+	// is being recorded and NOT_FOUND when no speech was recognised.
+	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*Transcript, error)
+	// Cancel discards the recording.
+	Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*Status, error)
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*Status, error)
+	// TranscribeFile transcribes audio without inserting it or keeping it in
+	// the history.
+	TranscribeFile(ctx context.Context, in *TranscribeFileRequest, opts ...grpc.CallOption) (*Transcript, error)
+	// Watch streams the current status, then every change of it and every
+	// new transcript, however the dictation was started.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type dictationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDictationClient(cc grpc.ClientConnInterface) DictationClient {
+	return &dictationClient{cc}
+}
+
+func (c *dictationClient) Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*Status, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Status)
+	err := c.cc.Invoke(ctx, Dictation_Start_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dictationClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*Transcript, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transcript)
+	err := c.cc.Invoke(ctx, Dictation_Stop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dictationClient) Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*Status, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Status)
+	err := c.cc.Invoke(ctx, Dictation_Cancel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dictationClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*Status, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Status)
+	err := c.cc.Invoke(ctx, Dictation_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dictationClient) TranscribeFile(ctx context.Context, in *TranscribeFileRequest, opts ...grpc.CallOption) (*Transcript, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transcript)
+	err := c.cc.Invoke(ctx, Dictation_TranscribeFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dictationClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Dictation_ServiceDesc.Streams[0], Dictation_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dictation_WatchClient = grpc.ServerStreamingClient[Event]
+
+// DictationServer is the server API for Dictation service.
+// All implementations must embed UnimplementedDictationServer
+// for forward compatibility.
+type DictationServer interface {
+	// Start starts a recording. It fails with FAILED_PRECONDITION when one is
+	// already going on.
+	Start(context.Context, *StartRequest) (*Status, error)
+	// Stop stops the recording, transcribes it and inserts the text, and
+	// returns the transcript. It fails with FAILED_PRECONDITION when nothing
+	// is being recorded and NOT_FOUND when no speech was recognised.
+	Stop(context.Context, *StopRequest) (*Transcript, error)
+	// Cancel discards the recording.
+	Cancel(context.Context, *CancelRequest) (*Status, error)
+	GetStatus(context.Context, *StatusRequest) (*Status, error)
+	// TranscribeFile transcribes audio without inserting it or keeping it in
+	// the history.
+	TranscribeFile(context.Context, *TranscribeFileRequest) (*Transcript, error)
+	// Watch streams the current status, then every change of it and every
+	// new transcript, however the dictation was started.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedDictationServer()
+}
+
+// UnimplementedDictationServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDictationServer struct{}
+
+func (UnimplementedDictationServer) Start(context.Context, *StartRequest) (*Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedDictationServer) Stop(context.Context, *StopRequest) (*Transcript, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedDictationServer) Cancel(context.Context, *CancelRequest) (*Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedDictationServer) GetStatus(context.Context, *StatusRequest) (*Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedDictationServer) TranscribeFile(context.Context, *TranscribeFileRequest) (*Transcript, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TranscribeFile not implemented")
+}
+func (UnimplementedDictationServer) Watch(*WatchRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDictationServer) mustEmbedUnimplementedDictationServer() {}
+func (UnimplementedDictationServer) testEmbeddedByValue()                   {}
+
+// UnsafeDictationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DictationServer will
+// result in compilation errors.
+type UnsafeDictationServer interface {
+	mustEmbedUnimplementedDictationServer()
+}
+
+func RegisterDictationServer(s grpc.ServiceRegistrar, srv DictationServer) {
+	// If the following call pancis, it indicates UnimplementedDictationServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Dictation_ServiceDesc, srv)
+}
+
+func _Dictation_Start_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DictationServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dictation_Start_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DictationServer).Start(ctx, req.(*StartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dictation_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DictationServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dictation_Stop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DictationServer).Stop(ctx, req.(*StopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dictation_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DictationServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dictation_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DictationServer).Cancel(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dictation_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DictationServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dictation_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DictationServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dictation_TranscribeFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranscribeFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DictationServer).TranscribeFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dictation_TranscribeFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DictationServer).TranscribeFile(ctx, req.(*TranscribeFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dictation_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DictationServer).Watch(m, &grpc.GenericServerStream[WatchRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dictation_WatchServer = grpc.ServerStreamingServer[Event]
+
+// Dictation_ServiceDesc is the grpc.ServiceDesc for Dictation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Dictation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dictation.v1.Dictation",
+	HandlerType: (*DictationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Start",
+			Handler:    _Dictation_Start_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _Dictation_Stop_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Dictation_Cancel_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Dictation_GetStatus_Handler,
+		},
+		{
+			MethodName: "TranscribeFile",
+			Handler:    _Dictation_TranscribeFile_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Dictation_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/dictation.proto",
+}
@@ -0,0 +1,105 @@
+//go:build nativeaudio
+
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ebitengine/oto/v3"
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// Built with -tags nativeaudio, sounds are played in-process through oto
+// (ALSA on Linux, which needs cgo and the ALSA headers to build; WASAPI
+// and Core Audio elsewhere) instead of by starting a player.
+
+const nativeRate = 44100
+
+var (
+	nativeOnce sync.Once
+	nativeCtx  *oto.Context
+	nativeErr  error
+)
+
+func nativeContext() (*oto.Context, error) {
+	nativeOnce.Do(func() {
+		var ready chan struct{}
+		nativeCtx, ready, nativeErr = oto.NewContext(&oto.NewContextOptions{
+			SampleRate:   nativeRate,
+			ChannelCount: 2,
+			Format:       oto.FormatSignedInt16LE,
+		})
+		if nativeErr == nil {
+			<-ready
+		}
+	})
+	return nativeCtx, nativeErr
+}
+
+// PlayNative plays a WAV or MP3 file's contents at gain and returns once
+// it has been played.
+func PlayNative(b []byte, gain float64) error {
+	pcm, err := decodeSound(b)
+	if err != nil {
+		return err
+	}
+	ctx, err := nativeContext()
+	if err != nil {
+		return err
+	}
+	p := ctx.NewPlayer(bytes.NewReader(pcm))
+	defer p.Close()
+	p.SetVolume(min(gain, 1))
+	p.Play()
+	for p.IsPlaying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// decodeSound turns a 16-bit PCM WAV or an MP3 into 16-bit stereo at
+// nativeRate.
+func decodeSound(b []byte) ([]byte, error) {
+	if info, err := ParseWAV(b); err == nil {
+		if info.BitsPerSample != 16 || info.Channels < 1 || info.Channels > 2 {
+			return nil, errors.New("only 16-bit mono and stereo WAV files can be played")
+		}
+		return resampleStereo(b[info.DataOffset:info.DataOffset+info.DataLen], info.Channels, info.SampleRate), nil
+	}
+	d, err := mp3.NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	var pcm bytes.Buffer
+	if _, err := pcm.ReadFrom(d); err != nil {
+		return nil, err
+	}
+	return resampleStereo(pcm.Bytes(), 2, d.SampleRate()), nil
+}
+
+// resampleStereo converts interleaved 16-bit samples to stereo at
+// nativeRate, interpolating linearly.
+func resampleStereo(pcm []byte, channels, rate int) []byte {
+	frames := len(pcm) / (2 * channels)
+	sample := func(frame, ch int) float64 {
+		if frame >= frames {
+			frame = frames - 1
+		}
+		return float64(int16(binary.LittleEndian.Uint16(pcm[2*(frame*channels+ch%channels):])))
+	}
+	n := frames * nativeRate / max(rate, 1)
+	out := make([]byte, 0, 4*n)
+	for i := 0; i < n; i++ {
+		pos := float64(i) * float64(rate) / nativeRate
+		f, frac := int(pos), pos-float64(int(pos))
+		for ch := 0; ch < 2; ch++ {
+			v := sample(f, ch)*(1-frac) + sample(f+1, ch)*frac
+			out = binary.LittleEndian.AppendUint16(out, uint16(int16(v)))
+		}
+	}
+	return out
+}
@@ -0,0 +1,10 @@
+//go:build !nativeaudio
+
+package audio
+
+import "errors"
+
+// PlayNative is only built in with -tags nativeaudio; see native.go.
+func PlayNative(b []byte, gain float64) error {
+	return errors.New("built without native audio")
+}
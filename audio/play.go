@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Play plays an in-memory WAV file with whatever the platform offers:
+// in-process in nativeaudio builds, else paplay or aplay on Linux, afplay
+// on macOS and PowerShell's SoundPlayer on Windows.
+func Play(b []byte) error {
+	if PlayNative(b, 1) == nil {
+		return nil
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return playTemp(b, func(path string) *exec.Cmd { return exec.Command("afplay", path) })
+	case "windows":
+		return playTemp(b, func(path string) *exec.Cmd {
+			cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+				`(New-Object Media.SoundPlayer $env:DICTATION_WAV).PlaySync()`)
+			cmd.Env = append(os.Environ(), "DICTATION_WAV="+path)
+			return cmd
+		})
+	}
+	var err error
+	for _, player := range []string{"paplay", "aplay"} {
+		cmd := exec.Command(player)
+		cmd.Stdin = bytes.NewReader(b)
+		if err = cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// playTemp plays b with a player that cannot read stdin, through a
+// temporary file.
+func playTemp(b []byte, player func(path string) *exec.Cmd) error {
+	f, err := os.CreateTemp("", "dictation-*.wav")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return player(f.Name()).Run()
+}
+
+// PlayFile plays a sound file at gain (1 is its own level), in-process in
+// nativeaudio builds, else with the first player that can. aplay, the last
+// resort for WAV files, has no volume control.
+func PlayFile(path string, gain float64) error {
+	if gain <= 0 {
+		return nil
+	}
+	if b, err := os.ReadFile(path); err == nil && PlayNative(b, gain) == nil {
+		return nil
+	}
+	players := [][]string{
+		{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet", "-volume", strconv.Itoa(int(min(gain, 1) * 100)), path},
+		{"afplay", "-v", strconv.FormatFloat(gain, 'f', 2, 64), path},
+		{"paplay", "--volume=" + strconv.Itoa(int(gain*65536)), path},
+	}
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		// aplay takes anything else for raw samples
+		players = append(players, []string{"aplay", "-q", path})
+	}
+	err := fmt.Errorf("no player for %s", path)
+	for _, p := range players {
+		if _, lerr := exec.LookPath(p[0]); lerr != nil {
+			continue
+		}
+		if err = exec.Command(p[0], p[1:]...).Run(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+// Package audio reads and writes the PCM WAV files dictation records and
+// plays sounds with whatever the platform offers.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+)
+
+// WAVInfo describes the PCM layout of a WAV file and where its samples are.
+type WAVInfo struct {
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+	// DataOffset and DataLen locate the "data" chunk payload.
+	DataOffset int
+	DataLen    int
+}
+
+// BytesPerSecond is the data rate of the samples.
+func (w WAVInfo) BytesPerSecond() int {
+	return w.SampleRate * w.Channels * w.BitsPerSample / 8
+}
+
+// ParseWAV walks the RIFF chunks of a PCM WAV. arecord writes a data chunk
+// size of 0x7fffffff (or leaves it unfinished) when interrupted, so the data
+// length is clamped to what is actually there.
+func ParseWAV(b []byte) (WAVInfo, error) {
+	var info WAVInfo
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return info, errors.New("not a WAV file")
+	}
+	gotFmt := false
+	for off := 12; off+8 <= len(b); {
+		id := string(b[off : off+4])
+		size := int(binary.LittleEndian.Uint32(b[off+4 : off+8]))
+		payload := off + 8
+		switch id {
+		case "fmt ":
+			if payload+16 > len(b) {
+				return info, errors.New("truncated fmt chunk")
+			}
+			info.Channels = int(binary.LittleEndian.Uint16(b[payload+2:]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(b[payload+4:]))
+			info.BitsPerSample = int(binary.LittleEndian.Uint16(b[payload+14:]))
+			gotFmt = true
+		case "data":
+			if !gotFmt {
+				return info, errors.New("data chunk before fmt chunk")
+			}
+			if size > len(b)-payload || size <= 0 {
+				size = len(b) - payload
+			}
+			info.DataOffset = payload
+			info.DataLen = size
+			return info, nil
+		}
+		off = payload + size + size%2
+	}
+	return info, errors.New("no data chunk")
+}
+
+// EncodeWAV builds a canonical 44-byte-header PCM WAV around samples.
+func EncodeWAV(info WAVInfo, samples []byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(36+len(samples)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(buf, binary.LittleEndian, uint32(16))
+	binary.Write(buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(buf, binary.LittleEndian, uint16(info.Channels))
+	binary.Write(buf, binary.LittleEndian, uint32(info.SampleRate))
+	binary.Write(buf, binary.LittleEndian, uint32(info.BytesPerSecond()))
+	binary.Write(buf, binary.LittleEndian, uint16(info.Channels*info.BitsPerSample/8))
+	binary.Write(buf, binary.LittleEndian, uint16(info.BitsPerSample))
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, uint32(len(samples)))
+	buf.Write(samples)
+	return buf.Bytes()
+}
+
+// SliceWAV returns a new WAV holding only the audio between start and end
+// seconds.
+func SliceWAV(b []byte, start, end float64) ([]byte, error) {
+	info, err := ParseWAV(b)
+	if err != nil {
+		return nil, err
+	}
+	frame := info.Channels * info.BitsPerSample / 8
+	if frame == 0 {
+		return nil, errors.New("invalid WAV format")
+	}
+	toOffset := func(sec float64) int {
+		n := int(sec*float64(info.SampleRate)) * frame
+		if n < 0 {
+			n = 0
+		}
+		if n > info.DataLen {
+			n = info.DataLen
+		}
+		return n
+	}
+	from, to := toOffset(start), toOffset(end)
+	if to < from {
+		to = from
+	}
+	data := b[info.DataOffset : info.DataOffset+info.DataLen]
+	return EncodeWAV(info, data[from:to]), nil
+}
+
+// RepairWAVHeader rewrites the RIFF and data chunk sizes of a WAV whose
+// recorder was killed before it could finalise them.
+func RepairWAVHeader(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := ParseWAV(b)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(b[4:8], uint32(len(b)-8))
+	binary.LittleEndian.PutUint32(b[info.DataOffset-4:info.DataOffset], uint32(info.DataLen))
+	return os.WriteFile(path, b, 0644)
+}
+
+// WAVDuration returns the length of a WAV file in seconds.
+func WAVDuration(path string) (float64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	info, err := ParseWAV(b)
+	if err != nil {
+		return 0, err
+	}
+	if info.BytesPerSecond() == 0 {
+		return 0, errors.New("invalid WAV format")
+	}
+	return float64(info.DataLen) / float64(info.BytesPerSecond()), nil
+}
+
+// Tone makes a beep as a 16 kHz mono WAV; gain scales its level, 1 being
+// the default.
+func Tone(freqHz, seconds, gain float64) []byte {
+	const rate = 16000
+	n := int(rate * seconds)
+	samples := make([]byte, 0, 2*n)
+	for i := 0; i < n; i++ {
+		t := float64(i) / rate
+		v := int16(math.Round(32767 * 0.3 * min(gain, 1) * math.Sin(2*math.Pi*freqHz*t)))
+		samples = binary.LittleEndian.AppendUint16(samples, uint16(v))
+	}
+	return EncodeWAV(WAVInfo{Channels: 1, SampleRate: rate, BitsPerSample: 16}, samples)
+}
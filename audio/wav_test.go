@@ -0,0 +1,156 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var mono16k = WAVInfo{Channels: 1, SampleRate: 16000, BitsPerSample: 16}
+
+func TestEncodeParseWAV(t *testing.T) {
+	samples := make([]byte, 3200)
+	for i := range samples {
+		samples[i] = byte(i)
+	}
+	b := EncodeWAV(mono16k, samples)
+	if len(b) != 44+len(samples) {
+		t.Fatalf("encoded %d bytes, want %d", len(b), 44+len(samples))
+	}
+	info, err := ParseWAV(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := WAVInfo{Channels: 1, SampleRate: 16000, BitsPerSample: 16, DataOffset: 44, DataLen: len(samples)}
+	if info != want {
+		t.Errorf("ParseWAV = %+v, want %+v", info, want)
+	}
+	if !bytes.Equal(b[info.DataOffset:info.DataOffset+info.DataLen], samples) {
+		t.Error("samples changed")
+	}
+	if got := info.BytesPerSecond(); got != 32000 {
+		t.Errorf("BytesPerSecond = %d, want 32000", got)
+	}
+}
+
+func TestParseWAVErrors(t *testing.T) {
+	noData := EncodeWAV(mono16k, nil)[:36]
+	dataFirst := append([]byte("RIFF\x00\x00\x00\x00WAVE"), "data\x00\x00\x00\x00"...)
+	tests := map[string][]byte{
+		"empty":       nil,
+		"not riff":    []byte("RIFX\x00\x00\x00\x00WAVE"),
+		"no data":     noData,
+		"data first":  dataFirst,
+		"short fmt":   []byte("RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00"),
+		"only header": []byte("RIFF\x00\x00\x00\x00WAVE"),
+	}
+	for name, b := range tests {
+		if _, err := ParseWAV(b); err == nil {
+			t.Errorf("%s: ParseWAV succeeded", name)
+		}
+	}
+}
+
+// An interrupted arecord leaves 0x7fffffff as the data size.
+func TestParseWAVClampsDataLen(t *testing.T) {
+	b := EncodeWAV(mono16k, make([]byte, 100))
+	binary.LittleEndian.PutUint32(b[40:44], 0x7fffffff)
+	info, err := ParseWAV(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.DataLen != 100 {
+		t.Errorf("DataLen = %d, want 100", info.DataLen)
+	}
+}
+
+func TestSliceWAV(t *testing.T) {
+	// one second, each frame holding its index
+	samples := make([]byte, 0, 32000)
+	for i := 0; i < 16000; i++ {
+		samples = binary.LittleEndian.AppendUint16(samples, uint16(i))
+	}
+	b := EncodeWAV(mono16k, samples)
+	tests := []struct {
+		start, end float64
+		want       []byte
+	}{
+		{0.25, 0.5, samples[8000:16000]},
+		{-1, 0.001, samples[:32]},
+		{0.75, 5, samples[24000:]},
+		{0.5, 0.25, nil},
+	}
+	for _, tt := range tests {
+		out, err := SliceWAV(b, tt.start, tt.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := ParseWAV(out)
+		if err != nil {
+			t.Fatalf("SliceWAV(%v, %v) is not a WAV: %v", tt.start, tt.end, err)
+		}
+		if got := out[info.DataOffset : info.DataOffset+info.DataLen]; !bytes.Equal(got, tt.want) {
+			t.Errorf("SliceWAV(%v, %v) has %d bytes of samples, want %d", tt.start, tt.end, len(got), len(tt.want))
+		}
+	}
+}
+
+func TestRepairWAVHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cut.wav")
+	b := EncodeWAV(mono16k, make([]byte, 16000))
+	binary.LittleEndian.PutUint32(b[4:8], 0)
+	binary.LittleEndian.PutUint32(b[40:44], 0x7fffffff)
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RepairWAVHeader(path); err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if riff := binary.LittleEndian.Uint32(fixed[4:8]); riff != uint32(len(fixed)-8) {
+		t.Errorf("RIFF size = %d, want %d", riff, len(fixed)-8)
+	}
+	if data := binary.LittleEndian.Uint32(fixed[40:44]); data != 16000 {
+		t.Errorf("data size = %d, want 16000", data)
+	}
+	d, err := WAVDuration(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 0.5 {
+		t.Errorf("WAVDuration = %v, want 0.5", d)
+	}
+}
+
+func TestTone(t *testing.T) {
+	info, err := ParseWAV(Tone(440, 0.1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SampleRate != 16000 || info.Channels != 1 || info.BitsPerSample != 16 {
+		t.Errorf("Tone format = %+v", info)
+	}
+	if info.DataLen != 3200 {
+		t.Errorf("Tone data = %d bytes, want 3200", info.DataLen)
+	}
+	// the gain is capped at 1
+	peak := func(b []byte) int {
+		peak := 0.0
+		for i := 44; i+1 < len(b); i += 2 {
+			peak = max(peak, math.Abs(float64(int16(binary.LittleEndian.Uint16(b[i:])))))
+		}
+		return int(peak)
+	}
+	if loud, capped := peak(Tone(440, 0.1, 1)), peak(Tone(440, 0.1, 5)); loud != capped {
+		t.Errorf("gain 5 peaks at %d, gain 1 at %d", capped, loud)
+	}
+	if quiet, loud := peak(Tone(440, 0.1, 0.5)), peak(Tone(440, 0.1, 1)); quiet >= loud {
+		t.Errorf("gain 0.5 peaks at %d, not below gain 1's %d", quiet, loud)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/user/dictation/recorder"
+)
+
+// A transcription in flight can be aborted: `dictate cancel`, the cancel
+// hotkey action and the cancel endpoints of the daemon and `dictate serve`
+// stop the upload at once, and the recording is discarded. With when_busy
+// "abort" another press does the same but keeps the recording for
+// `dictate retry`, so a slow API does not hold up the next dictation.
+//
+// In the process doing the transcription the request's context is
+// cancelled; a toggle running in a process of its own is sent SIGUSR1 or
+// SIGUSR2 (not on Windows, where the press waits for the transcription to
+// end).
+
+// Why a transcription was aborted, as the cause of its context.
+var (
+	errCancelled   = errors.New("transcription cancelled")
+	errInterrupted = errors.New("transcription interrupted by another press")
+)
+
+var inFlight struct {
+	sync.Mutex
+	cancel context.CancelCauseFunc
+}
+
+// abortable returns a context for a transcription that abortTranscription
+// cancels, and the function to call once it is over.
+func abortable() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	inFlight.Lock()
+	inFlight.cancel = cancel
+	inFlight.Unlock()
+	return ctx, func() {
+		inFlight.Lock()
+		inFlight.cancel = nil
+		inFlight.Unlock()
+		cancel(nil)
+	}
+}
+
+// abortInProcess aborts this process's transcription, if there is one,
+// with cause errCancelled or errInterrupted.
+func abortInProcess(cause error) bool {
+	inFlight.Lock()
+	defer inFlight.Unlock()
+	if inFlight.cancel == nil {
+		return false
+	}
+	inFlight.cancel(cause)
+	inFlight.cancel = nil
+	return true
+}
+
+// abortTranscription aborts the transcription in flight, in this process
+// or in the toggle that marked itself busy while it is still transcribing,
+// and reports whether there was one.
+func abortTranscription(cause error) bool {
+	if abortInProcess(cause) {
+		return true
+	}
+	// once the toggle is inserting there is nothing left to abort
+	pid, stage, ok := readBusy()
+	if !ok || stage != busyTranscribing || pid == os.Getpid() {
+		return false
+	}
+	// the pid may have been reused since; only signal another dictate
+	name, err := recorder.ProcessName(pid)
+	if err != nil {
+		return false
+	}
+	if self, err := recorder.ProcessName(os.Getpid()); err != nil || name != self {
+		return false
+	}
+	return signalAbort(pid, cause) == nil
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// listenForAbort has SIGUSR1 cancel this process's transcription and
+// SIGUSR2 interrupt it.
+func listenForAbort() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range c {
+			if sig == syscall.SIGUSR2 {
+				abortInProcess(errInterrupted)
+			} else {
+				abortInProcess(errCancelled)
+			}
+		}
+	}()
+}
+
+func signalAbort(pid int, cause error) error {
+	if errors.Is(cause, errInterrupted) {
+		return syscall.Kill(pid, syscall.SIGUSR2)
+	}
+	return syscall.Kill(pid, syscall.SIGUSR1)
+}
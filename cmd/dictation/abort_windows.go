@@ -0,0 +1,10 @@
+package main
+
+import "errors"
+
+// listenForAbort does nothing: Windows has no signal to spare.
+func listenForAbort() {}
+
+func signalAbort(pid int, cause error) error {
+	return errors.New("cannot abort a transcription in another process on Windows")
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Accelerators in order of preference.
+const (
+	accelCUDA   = "cuda"
+	accelROCm   = "rocm"
+	accelVulkan = "vulkan"
+	accelCPU    = "cpu"
+)
+
+var accelPreference = []string{accelCUDA, accelROCm, accelVulkan, accelCPU}
+
+// backendChoice is what a local worker looks like for one accelerator.
+type backendChoice struct {
+	Image       string
+	GPUFlags    func(runtime string) []string
+	Model       string
+	ComputeType string
+}
+
+// backendDefaults only lists images known to serve the OpenAI audio API.
+// ROCm and Vulkan have none by default; they are used once an image is
+// configured under worker.images.
+var backendDefaults = map[string]backendChoice{
+	accelCUDA: {
+		Image: "docker.io/fedirz/faster-whisper-server:latest-cuda",
+		GPUFlags: func(runtime string) []string {
+			if runtime == "docker" {
+				return []string{"--gpus=all"}
+			}
+			return []string{"--device", "nvidia.com/gpu=all"}
+		},
+		Model:       "Systran/faster-whisper-large-v3",
+		ComputeType: "float16",
+	},
+	accelROCm: {
+		GPUFlags: func(string) []string {
+			return []string{"--device=/dev/kfd", "--device=/dev/dri", "--group-add=video"}
+		},
+		Model:       "Systran/faster-whisper-large-v3",
+		ComputeType: "float16",
+	},
+	accelVulkan: {
+		GPUFlags:    func(string) []string { return []string{"--device=/dev/dri"} },
+		Model:       "Systran/faster-whisper-medium",
+		ComputeType: "float16",
+	},
+	// without a GPU only a small int8 model keeps latency bearable
+	accelCPU: {
+		Image:       "docker.io/fedirz/faster-whisper-server:latest-cpu",
+		GPUFlags:    func(string) []string { return nil },
+		Model:       "Systran/faster-whisper-small",
+		ComputeType: "int8",
+	},
+}
+
+// detectAccelerators returns the accelerators found on this machine, each
+// with a short human readable description, best first. The CPU is always
+// last.
+func detectAccelerators() (found []string, details map[string]string) {
+	details = map[string]string{}
+	if out, err := exec.Command("nvidia-smi", "-L").Output(); err == nil && len(out) > 0 {
+		found = append(found, accelCUDA)
+		details[accelCUDA] = firstLine(string(out))
+	}
+	if _, err := os.Stat("/dev/kfd"); err == nil {
+		d := "/dev/kfd present"
+		if out, err := exec.Command("rocm-smi", "--showproductname").Output(); err == nil {
+			for _, l := range strings.Split(string(out), "\n") {
+				if strings.Contains(l, "Card series") {
+					d = strings.TrimSpace(l[strings.LastIndex(l, ":")+1:])
+					break
+				}
+			}
+		}
+		found = append(found, accelROCm)
+		details[accelROCm] = d
+	}
+	if out, err := exec.Command("vulkaninfo", "--summary").Output(); err == nil {
+		for _, l := range strings.Split(string(out), "\n") {
+			if strings.Contains(l, "PHYSICAL_DEVICE_TYPE_DISCRETE_GPU") || strings.Contains(l, "PHYSICAL_DEVICE_TYPE_INTEGRATED_GPU") {
+				found = append(found, accelVulkan)
+				details[accelVulkan] = strings.TrimSpace(l)
+				break
+			}
+		}
+	}
+	found = append(found, accelCPU)
+	details[accelCPU] = "always available"
+	return found, details
+}
+
+// acceleratorCacheTTL is how long a detection is reused. Probing runs
+// nvidia-smi, rocm-smi and vulkaninfo, too slow to do on every toggle;
+// `dictate doctor` always probes afresh.
+const acceleratorCacheTTL = 24 * time.Hour
+
+type acceleratorCache struct {
+	Time    time.Time         `json:"time"`
+	Found   []string          `json:"found"`
+	Details map[string]string `json:"details"`
+}
+
+func acceleratorCachePath() string {
+	return filepath.Join(stateDir(), "accelerators.json")
+}
+
+// cachedAccelerators is detectAccelerators, reusing the last detection
+// while it is recent.
+func cachedAccelerators() []string {
+	var c acceleratorCache
+	if b, err := os.ReadFile(acceleratorCachePath()); err == nil && json.Unmarshal(b, &c) == nil &&
+		len(c.Found) > 0 && time.Since(c.Time) < acceleratorCacheTTL {
+		return c.Found
+	}
+	found, details := detectAccelerators()
+	saveAccelerators(found, details)
+	return found
+}
+
+func saveAccelerators(found []string, details map[string]string) {
+	b, err := json.MarshalIndent(acceleratorCache{Time: time.Now(), Found: found, Details: details}, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(acceleratorCachePath(), b, 0600)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not cache the accelerators:", err)
+	}
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// imageFor returns the worker image for an accelerator, preferring the
+// user's worker.images entry.
+func imageFor(w *WorkerConfig, accel string) string {
+	if img := w.Images[accel]; img != "" {
+		return img
+	}
+	return backendDefaults[accel].Image
+}
+
+// chooseAccelerator picks the best detected accelerator that has an image.
+func chooseAccelerator(w *WorkerConfig) string {
+	if w.Accelerator != "" && w.Accelerator != "auto" {
+		return w.Accelerator
+	}
+	found := cachedAccelerators()
+	for _, a := range accelPreference {
+		for _, f := range found {
+			if f == a && imageFor(w, a) != "" {
+				return a
+			}
+		}
+	}
+	return accelCPU
+}
+
+// resolveBackend fills in whatever the worker config leaves open (image, GPU
+// flags, model, compute type) from the chosen accelerator. Explicit settings
+// always win.
+func resolveBackend(cfg *Config) {
+	w := cfg.Worker
+	if w == nil {
+		return
+	}
+	accel := chooseAccelerator(w)
+	choice := backendDefaults[accel]
+	w.resolvedAccel = accel
+	if w.Image == "" {
+		w.Image = imageFor(w, accel)
+	}
+	if w.GPUFlags == nil && choice.GPUFlags != nil {
+		w.GPUFlags = choice.GPUFlags(w.runtime())
+	}
+	if w.ComputeType == "" {
+		w.ComputeType = choice.ComputeType
+	}
+	if w.Model == "" {
+		// "whisper-1" is the OpenAI default and means nothing to a local
+		// server, so only a top-level model the user changed carries over
+		if cfg.Model != "" && cfg.Model != defaultConfig().Model {
+			w.Model = cfg.Model
+		} else {
+			w.Model = choice.Model
+			w.cpuFallback = accel == accelCPU && (w.Accelerator == "" || w.Accelerator == "auto")
+		}
+	}
+}
+
+// cpuFallbackWarning explains the accuracy trade-off when no GPU was found
+// and the small CPU model was picked automatically. It is empty otherwise.
+func cpuFallbackWarning(cfg Config) string {
+	if cfg.Worker == nil || !cfg.Worker.cpuFallback {
+		return ""
+	}
+	return "No GPU found: using " + cfg.Worker.Model + " (" + cfg.Worker.ComputeType +
+		") on the CPU. Expect lower accuracy than with a GPU or OpenAI; set worker.model to override."
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Actions are what a hotkey can do. The hotkeys map in the config binds
+// key combos to them; `dictate action <name>` runs one, and `dictate
+// hotkeys` turns the map into bindings for the desktop's key binder.
+const (
+	actionToggle     = "toggle"
+	actionPushToTalk = "push-to-talk"
+	actionCancel     = "cancel"
+	actionRepeatLast = "repeat-last"
+	actionTranslate  = "translate"
+	actionNote       = "private-note"
+	actionEdit       = "edit"
+	actionContinuous = "continuous"
+	// actionProfile is followed by the profile name, as in "profile:code".
+	actionProfile = "profile:"
+)
+
+// Push-to-talk is bound twice: press starts the recording, release stops
+// it.
+const (
+	pttPress   = ":press"
+	pttRelease = ":release"
+)
+
+// Recording modes besides modeEdit, kept in modeFile for the stop.
+const (
+	modeTranslate = "translate"
+	modeNote      = "note"
+)
+
+// recordingProfilePath names the profile a recording was started with, so
+// the press that stops it transcribes it with that profile too.
+func recordingProfilePath() string {
+	return filepath.Join(runtimeDir(), "dictation-profile")
+}
+
+// validAction reports whether name is an action the hotkeys map may use.
+func validAction(cfg Config, name string) error {
+	switch name {
+	case actionToggle, actionPushToTalk, actionCancel, actionRepeatLast, actionTranslate, actionNote, actionEdit, actionContinuous:
+		return nil
+	}
+	if p, ok := strings.CutPrefix(name, actionProfile); ok {
+		if _, ok := cfg.Profiles[p]; !ok {
+			return fmt.Errorf("unknown profile %q", p)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", name)
+}
+
+// runAction implements `dictate action <name>`.
+func runAction(cfg Config, args []string) error {
+	if len(args) != 1 {
+		return exitError{exitUsage, errors.New("usage: dictate action <toggle|push-to-talk[:press|:release]|cancel|repeat-last|translate|private-note|edit|continuous|profile:NAME>")}
+	}
+	return doAction(cfg, args[0], "")
+}
+
+// doAction runs the named action. Recordings it starts use profile, when
+// set, unless the action names one itself.
+func doAction(cfg Config, name, profile string) error {
+	base, phase, _ := strings.Cut(name, ":")
+	if base == actionPushToTalk {
+		_, recording := recordingSince()
+		switch ":" + phase {
+		case pttPress:
+			if recording {
+				return nil
+			}
+		case pttRelease:
+			if !recording {
+				return nil
+			}
+		case ":":
+		default:
+			return exitError{exitUsage, fmt.Errorf("unknown action %q", name)}
+		}
+		return toggleWith(cfg, "", profile)
+	}
+	if err := validAction(cfg, name); err != nil {
+		return exitError{exitUsage, err}
+	}
+	switch name {
+	case actionToggle:
+		return toggleWith(cfg, "", profile)
+	case actionCancel:
+		return cancelRecording()
+	case actionRepeatLast:
+		return runAgain(cfg, nil)
+	case actionTranslate:
+		return toggleWith(cfg, modeTranslate, profile)
+	case actionNote:
+		return toggleWith(cfg, modeNote, profile)
+	case actionEdit:
+		return toggleWith(cfg, modeEdit, profile)
+	case actionContinuous:
+		// the daemon runs it itself; anywhere else it is asked to
+		q := url.Values{}
+		if profile != "" {
+			q.Set("profile", profile)
+		}
+		return postDaemon("/continuous", q)
+	}
+	return toggleWith(cfg, "", strings.TrimPrefix(name, actionProfile))
+}
+
+// toggleWith toggles, and when that starts a recording, marks it to be
+// handled in mode and with profile once it is stopped.
+func toggleWith(cfg Config, mode, profile string) error {
+	if _, recording := recordingSince(); !recording {
+		if mode != "" {
+			if err := os.WriteFile(modeFile(), []byte(mode), 0644); err != nil {
+				return err
+			}
+		}
+		if profile != "" {
+			if err := os.WriteFile(recordingProfilePath(), []byte(profile), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return toggle(cfg)
+}
+
+// notesFile is where private notes go.
+func notesFile(cfg Config) string {
+	if cfg.NotesFile != "" {
+		return cfg.NotesFile
+	}
+	return filepath.Join(dataDir(), "notes.md")
+}
+
+// saveNote appends a private note: it is not typed, not kept as the last
+// transcript and not added to the history.
+func saveNote(cfg Config, text string) error {
+	cfg.OutputFile = notesFile(cfg)
+	return appendToFile(cfg, text)
+}
+
+// Key binders `dictate hotkeys` writes bindings for.
+var hotkeyFormats = []string{"sxhkd", "sway", "i3", "hyprland"}
+
+// runHotkeys implements `dictate hotkeys [--format F]`: it prints the
+// hotkeys map as bindings to paste into the key binder's config.
+func runHotkeys(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("hotkeys", flag.ContinueOnError)
+	format := fs.String("format", "sxhkd", "binding syntax: "+strings.Join(hotkeyFormats, ", "))
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if !validHotkeyFormat(*format) {
+		return exitError{exitUsage, fmt.Errorf("unknown format %q (%s)", *format, strings.Join(hotkeyFormats, ", "))}
+	}
+	if len(cfg.Hotkeys) == 0 {
+		return exitError{exitUsage, errors.New(`no "hotkeys" in the config`)}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	combos := make([]string, 0, len(cfg.Hotkeys))
+	for c := range cfg.Hotkeys {
+		combos = append(combos, c)
+	}
+	sort.Strings(combos)
+	for _, c := range combos {
+		action := cfg.Hotkeys[c]
+		mods, key := splitCombo(c)
+		run := shellQuote(self) + " action " + action
+		var lines []string
+		bind := func(release bool, cmd string) {
+			switch *format {
+			case "sxhkd":
+				k := key
+				if release {
+					k = "@" + key
+				}
+				lines = append(lines, strings.Join(append(mods, k), " + "), "\t"+cmd)
+			case "sway", "i3":
+				opt := ""
+				if release {
+					opt = "--release "
+				}
+				lines = append(lines, fmt.Sprintf("bindsym %s%s exec %s", opt, strings.Join(append(swayMods(mods), key), "+"), cmd))
+			case "hyprland":
+				b := "bind"
+				if release {
+					b = "bindr"
+				}
+				lines = append(lines, fmt.Sprintf("%s = %s, %s, exec, %s", b, strings.ToUpper(strings.Join(mods, " ")), key, cmd))
+			}
+		}
+		if action == actionPushToTalk {
+			bind(false, run+pttPress)
+			bind(true, run+pttRelease)
+		} else {
+			bind(false, run)
+		}
+		fmt.Println(strings.Join(lines, "\n"))
+	}
+	return nil
+}
+
+func validHotkeyFormat(f string) bool {
+	for _, h := range hotkeyFormats {
+		if f == h {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCombo splits "super+shift+d" into lower-case modifiers and the key.
+func splitCombo(combo string) ([]string, string) {
+	parts := strings.Split(combo, "+")
+	var mods []string
+	for _, p := range parts[:len(parts)-1] {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			mods = append(mods, p)
+		}
+	}
+	return mods, strings.TrimSpace(parts[len(parts)-1])
+}
+
+// swayMods spells modifiers the way sway and i3 do.
+func swayMods(mods []string) []string {
+	out := make([]string, len(mods))
+	for i, m := range mods {
+		switch m {
+		case "super", "win", "mod4":
+			out[i] = "Mod4"
+		case "alt", "mod1":
+			out[i] = "Mod1"
+		case "ctrl", "control":
+			out[i] = "Ctrl"
+		default:
+			out[i] = strings.ToUpper(m[:1]) + m[1:]
+		}
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/user/dictation/insert"
+)
+
+// runAgain implements `dictate again [--copy]`: it inserts the most recent
+// transcript once more, for when focus was on the wrong window. App rules
+// are matched against the window focused now.
+func runAgain(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("again", flag.ContinueOnError)
+	toClipboard := fs.Bool("copy", false, "copy the transcript to the clipboard instead of inserting it")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	t, err := loadLastTranscript()
+	if os.IsNotExist(err) {
+		return exitError{exitEmpty, errors.New("no transcript yet")}
+	}
+	if err != nil {
+		return err
+	}
+	text := t.FinalText()
+	if *toClipboard {
+		if err := insert.Copy(text); err != nil {
+			return err
+		}
+		notifyUser("Dictation", "Last transcript copied to clipboard")
+		return nil
+	}
+	text, err = prepareOutput(&cfg, text, "")
+	if err != nil {
+		return err
+	}
+	typed, err := insertText(cfg, text)
+	if err != nil {
+		notifyFailure("Dictation", "Insert failed: "+err.Error())
+		return err
+	}
+	if typed {
+		markInserted(t.ID, text)
+	}
+	return nil
+}
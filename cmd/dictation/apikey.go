@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// openAIKey returns the API key from api_key_file or api_key_cmd, the
+// environment, the keyring or, in portable mode, from the
+// passphrase-encrypted key file next to the binary.
+func openAIKey(cfg Config) (string, error) {
+	if cfg.APIKeyFile != "" || cfg.APIKeyCmd != "" {
+		return keyFromConfig(cfg.APIKeyFile, cfg.APIKeyCmd)
+	}
+	if k := os.Getenv("OPENAI_API_KEY"); k != "" {
+		return k, nil
+	}
+	if cfg.Portable {
+		return portableKey()
+	}
+	if k := keyFromKeyring(cfg, "OPENAI_API_KEY"); k != "" {
+		return k, nil
+	}
+	return "", errors.New("OPENAI_API_KEY not set (or store it with `dictate set-key`)")
+}
+
+// keyCmdCache keeps the output of key commands for the life of the
+// process, so a password manager is asked once, not on every request.
+var keyCmdCache struct {
+	sync.Mutex
+	keys map[string]string
+}
+
+// keyFromConfig reads the key from file or, if file is empty, runs cmd
+// through the shell and takes the first line it prints.
+func keyFromConfig(file, cmd string) (string, error) {
+	if file != "" {
+		b, err := os.ReadFile(expandHome(file))
+		if err != nil {
+			return "", fmt.Errorf("api_key_file: %w", err)
+		}
+		if k := strings.TrimSpace(string(b)); k != "" {
+			return k, nil
+		}
+		return "", fmt.Errorf("api_key_file %s is empty", file)
+	}
+	keyCmdCache.Lock()
+	defer keyCmdCache.Unlock()
+	if k, ok := keyCmdCache.keys[cmd]; ok {
+		return k, nil
+	}
+	c := shellCommand(cmd)
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("api_key_cmd: %w", err)
+	}
+	// pass and similar tools print the secret on the first line
+	k, _, _ := strings.Cut(string(out), "\n")
+	if k = strings.TrimSpace(k); k == "" {
+		return "", errors.New("api_key_cmd printed nothing")
+	}
+	if keyCmdCache.keys == nil {
+		keyCmdCache.keys = map[string]string{}
+	}
+	keyCmdCache.keys[cmd] = k
+	return k, nil
+}
+
+// encryptedKey is the on-disk format of the portable key file.
+type encryptedKey struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+const pbkdf2Iterations = 600000
+
+func portableKeyPath() string {
+	return filepath.Join(portableDir, "api_key.enc")
+}
+
+// portableKeyCache keeps the decrypted key for the life of the process, so
+// the passphrase is asked once per dictation rather than for every request
+// (transcription, the LLM stage, speech) that needs the key.
+var portableKeyCache struct {
+	sync.Mutex
+	key string
+}
+
+// portableKey decrypts the stored key, or asks for one (and a passphrase to
+// protect it) on first use.
+func portableKey() (string, error) {
+	portableKeyCache.Lock()
+	defer portableKeyCache.Unlock()
+	if portableKeyCache.key != "" {
+		return portableKeyCache.key, nil
+	}
+	key, err := loadPortableKey()
+	if err != nil {
+		return "", err
+	}
+	portableKeyCache.key = key
+	return key, nil
+}
+
+func loadPortableKey() (string, error) {
+	b, err := os.ReadFile(portableKeyPath())
+	if os.IsNotExist(err) {
+		return setupPortableKey()
+	}
+	if err != nil {
+		return "", err
+	}
+	var ek encryptedKey
+	if err := json.Unmarshal(b, &ek); err != nil {
+		return "", err
+	}
+	pass, err := promptInput("Dictation", "Passphrase for the stored API key:", true)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := keyCipher(pass, ek.Salt)
+	if err != nil {
+		return "", err
+	}
+	key, err := gcm.Open(nil, ek.Nonce, ek.Ciphertext, nil)
+	if err != nil {
+		return "", errors.New("wrong passphrase")
+	}
+	return string(key), nil
+}
+
+func setupPortableKey() (string, error) {
+	key, err := promptInput("Dictation", "OpenAI API key:", true)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", errors.New("no API key given")
+	}
+	pass, err := promptInput("Dictation", "Choose a passphrase to encrypt the key:", true)
+	if err != nil {
+		return "", err
+	}
+	again, err := promptInput("Dictation", "Repeat the passphrase:", true)
+	if err != nil {
+		return "", err
+	}
+	if pass != again {
+		return "", errors.New("passphrases do not match")
+	}
+
+	ek := encryptedKey{Salt: make([]byte, 16)}
+	if _, err := rand.Read(ek.Salt); err != nil {
+		return "", err
+	}
+	gcm, err := keyCipher(pass, ek.Salt)
+	if err != nil {
+		return "", err
+	}
+	ek.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(ek.Nonce); err != nil {
+		return "", err
+	}
+	ek.Ciphertext = gcm.Seal(nil, ek.Nonce, []byte(key), nil)
+	b, err := json.Marshal(ek)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(portableKeyPath(), b, 0600); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// keyCipher derives an AES-256-GCM cipher from the passphrase.
+func keyCipher(pass string, salt []byte) (cipher.AEAD, error) {
+	k, err := pbkdf2.Key(sha256.New, pass, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// AppRule changes how text is delivered when the focused window matches.
+// Class and Title are case-insensitive regular expressions; an empty pattern
+// matches anything. The first matching rule wins.
+type AppRule struct {
+	Class string `json:"class"`
+	Title string `json:"title"`
+
+	// Output overrides the output mode, e.g. "clipboard" for terminals.
+	Output string `json:"output"`
+	// Suffix is appended to the text, e.g. "\n" to send in chat apps.
+	Suffix string `json:"suffix"`
+	// Template replaces output_template for this window.
+	Template string `json:"template"`
+	// Disable stops anything from being typed, e.g. in password managers.
+	Disable bool `json:"disable"`
+}
+
+func (r AppRule) matches(class, title string) bool {
+	return matchPattern(r.Class, class) && matchPattern(r.Title, title)
+}
+
+func matchPattern(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: bad app rule pattern %q: %v\n", pattern, err)
+		return false
+	}
+	return re.MatchString(s)
+}
+
+// focusedWindow returns the class and title of the window that currently
+// has focus, as far as the platform lets us find out.
+func focusedWindow() (class, title string, err error) {
+	if isMac {
+		out, err := exec.Command("osascript", "-e",
+			`tell application "System Events" to get name of first application process whose frontmost is true`).Output()
+		if err != nil {
+			return "", "", err
+		}
+		return strings.TrimSpace(string(out)), "", nil
+	}
+	if !pathExists("xdotool") {
+		return "", "", fmt.Errorf("xdotool not found")
+	}
+	out, err := exec.Command("xdotool", "getactivewindow", "getwindowclassname").Output()
+	if err != nil {
+		return "", "", err
+	}
+	class = strings.TrimSpace(string(out))
+	out, err = exec.Command("xdotool", "getactivewindow", "getwindowname").Output()
+	if err == nil {
+		title = strings.TrimSpace(string(out))
+	}
+	return class, title, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out requests so a batch stays under a requests per
+// minute budget. The zero limit means no limit.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rpm int) *rateLimiter {
+	l := &rateLimiter{}
+	if rpm > 0 {
+		l.interval = time.Minute / time.Duration(rpm)
+	}
+	return l
+}
+
+// wait blocks until the next request may start.
+func (l *rateLimiter) wait() {
+	if l.interval == 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
+
+// progress draws a one-line progress bar on stderr while a batch runs. It
+// does nothing when stderr is not a terminal, so logs stay clean.
+type progress struct {
+	mu     sync.Mutex
+	total  int
+	done   int
+	failed int
+	start  time.Time
+	tty    bool
+}
+
+func newProgress(total int) *progress {
+	fi, err := os.Stderr.Stat()
+	return &progress{total: total, start: time.Now(), tty: err == nil && fi.Mode()&os.ModeCharDevice != 0}
+}
+
+// add counts a finished file and redraws the bar.
+func (p *progress) add(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if err != nil {
+		p.failed++
+	}
+	p.draw()
+}
+
+// logf prints a message above the bar.
+func (p *progress) logf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+	p.draw()
+}
+
+func (p *progress) draw() {
+	if !p.tty {
+		return
+	}
+	const width = 30
+	filled := width * p.done / p.total
+	line := fmt.Sprintf("[%s%s] %d/%d", strings.Repeat("#", filled), strings.Repeat(".", width-filled), p.done, p.total)
+	if p.failed > 0 {
+		line += fmt.Sprintf(", %d failed", p.failed)
+	}
+	if p.done > 0 && p.done < p.total {
+		left := time.Since(p.start) / time.Duration(p.done) * time.Duration(p.total-p.done)
+		line += fmt.Sprintf(", ~%s left", left.Round(time.Second))
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K"+line)
+}
+
+// finish ends the bar's line.
+func (p *progress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		fmt.Fprintln(os.Stderr)
+	}
+}
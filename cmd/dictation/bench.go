@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/provider"
+)
+
+// `dictate bench` sends the same recordings to several providers and shows
+// how their transcripts differ, word by word, to judge which one copes best
+// with your voice and vocabulary.
+
+// benchResult is one provider's transcript of one file.
+type benchResult struct {
+	Provider string
+	Model    string
+	Text     string
+	Latency  time.Duration
+	Err      error
+	// Diff compares Text with the first provider's; Edits counts the
+	// words that differ.
+	Diff  []diffOp
+	Edits int
+}
+
+// diffOp is a run of words that both transcripts share (diffSame), or that
+// only the reference (diffDel) or the other one (diffIns) has.
+type diffOp struct {
+	Kind  int
+	Words []string
+}
+
+const (
+	diffSame = iota
+	diffDel
+	diffIns
+)
+
+// wordDiff aligns the words of b with those of a, ignoring case and
+// punctuation around words, via their longest common subsequence.
+func wordDiff(a, b string) []diffOp {
+	x, y := strings.Fields(a), strings.Fields(b)
+	norm := func(w string) string {
+		return strings.ToLower(strings.Trim(w, ".,;:!?\"'()"))
+	}
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if norm(x[i]) == norm(y[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []diffOp
+	add := func(kind int, w string) {
+		if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+			ops[n-1].Words = append(ops[n-1].Words, w)
+			return
+		}
+		ops = append(ops, diffOp{kind, []string{w}})
+	}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && norm(x[i]) == norm(y[j]):
+			add(diffSame, y[j])
+			i, j = i+1, j+1
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			add(diffDel, x[i])
+			i++
+		default:
+			add(diffIns, y[j])
+			j++
+		}
+	}
+	return ops
+}
+
+// runBench implements `dictate bench [--providers a,b] [--html file] file.wav...`.
+func runBench(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: dictate bench [--providers a,b] [--html report.html] file.wav...")
+		fs.PrintDefaults()
+	}
+	only := fs.String("providers", "", "comma-separated providers to compare (default: every one whose key is available); the first is the reference")
+	htmlOut := fs.String("html", "", "also write the comparison as an HTML page to this file")
+	color := fs.String("color", "auto", "color the terminal diff: auto, always or never")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError{exitUsage, errors.New("no input files")}
+	}
+
+	var providers []provider.Provider
+	if *only != "" {
+		for _, name := range strings.Split(*only, ",") {
+			p, err := findProvider(cfg, strings.TrimSpace(name))
+			if err != nil {
+				return exitError{exitUsage, err}
+			}
+			providers = append(providers, p)
+		}
+	} else {
+		for _, p := range providerList(cfg) {
+			if _, err := providerKey(cfg, p); err == nil {
+				providers = append(providers, p)
+			}
+		}
+	}
+	if len(providers) < 2 {
+		return exitError{exitUsage, errors.New("bench needs at least two providers with their keys available")}
+	}
+
+	useColor := *color == "always"
+	if *color == "auto" {
+		fi, err := os.Stdout.Stat()
+		useColor = err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == ""
+	}
+
+	report := map[string][]benchResult{}
+	for _, path := range fs.Args() {
+		var results []benchResult
+		for _, p := range providers {
+			start := time.Now()
+			res, err := transcribeWith(context.Background(), cfg, p, path)
+			results = append(results, benchResult{Provider: p.Name, Model: p.Model, Text: strings.TrimSpace(res.Text),
+				Latency: time.Since(start), Err: err})
+		}
+		ref := results[0]
+		for i := range results[1:] {
+			r := &results[i+1]
+			if r.Err != nil || ref.Err != nil {
+				continue
+			}
+			r.Diff = wordDiff(ref.Text, r.Text)
+			r.Edits = wordEdits(ref.Text, r.Text)
+		}
+		report[path] = results
+		printBench(os.Stdout, path, results, useColor)
+	}
+	if *htmlOut != "" {
+		f, err := os.Create(*htmlOut)
+		if err != nil {
+			return err
+		}
+		if err := writeBenchHTML(f, fs.Args(), report); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return nil
+}
+
+func printBench(w io.Writer, path string, results []benchResult, color bool) {
+	fmt.Fprintf(w, "== %s\n", filepath.Base(path))
+	for i, r := range results {
+		fmt.Fprintf(w, "%s (%s, %.1fs", r.Provider, r.Model, r.Latency.Seconds())
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(w, "): error: %v\n", r.Err)
+			continue
+		case i > 0 && r.Diff != nil:
+			fmt.Fprintf(w, ", %d edits", r.Edits)
+		}
+		fmt.Fprint(w, "): ")
+		if r.Diff == nil {
+			fmt.Fprintln(w, r.Text)
+			continue
+		}
+		var parts []string
+		for _, op := range r.Diff {
+			s := strings.Join(op.Words, " ")
+			switch {
+			case op.Kind == diffSame:
+			case color && op.Kind == diffDel:
+				s = "\033[31;9m" + s + "\033[0m"
+			case color:
+				s = "\033[32m" + s + "\033[0m"
+			case op.Kind == diffDel:
+				s = "[-" + s + "-]"
+			default:
+				s = "{+" + s + "+}"
+			}
+			parts = append(parts, s)
+		}
+		fmt.Fprintln(w, strings.Join(parts, " "))
+	}
+	fmt.Fprintln(w)
+}
+
+var benchHTML = template.Must(template.New("bench").Funcs(template.FuncMap{
+	"base":  filepath.Base,
+	"words": func(ws []string) string { return strings.Join(ws, " ") },
+	"secs":  func(d time.Duration) string { return fmt.Sprintf("%.1fs", d.Seconds()) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>dictate bench</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+td, th { border: 1px solid #ccc; padding: .4em .6em; text-align: left; vertical-align: top; }
+del { background: #fdd; color: #900; }
+ins { background: #dfd; color: #060; text-decoration: none; }
+.err { color: #900; }
+</style></head><body>
+<h1>Transcript comparison</h1>
+<p>Differences are against the first provider: <del>missing</del> and <ins>added</ins> words.</p>
+{{range $path := .Files}}<h2>{{base $path}}</h2>
+<table><tr><th>Provider</th><th>Model</th><th>Latency</th><th>Edits</th><th>Transcript</th></tr>
+{{range $i, $r := index $.Results $path}}<tr><td>{{$r.Provider}}</td><td>{{$r.Model}}</td><td>{{secs $r.Latency}}</td>
+<td>{{if $r.Diff}}{{$r.Edits}}{{end}}</td>
+<td>{{if $r.Err}}<span class="err">{{$r.Err}}</span>{{else if $r.Diff}}{{range $r.Diff}}{{if eq .Kind 1}}<del>{{words .Words}}</del> {{else if eq .Kind 2}}<ins>{{words .Words}}</ins> {{else}}{{words .Words}} {{end}}{{end}}{{else}}{{$r.Text}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}</body></html>
+`))
+
+func writeBenchHTML(w io.Writer, files []string, results map[string][]benchResult) error {
+	return benchHTML.Execute(w, struct {
+		Files   []string
+		Results map[string][]benchResult
+	}{files, results})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/audio"
+	"github.com/user/dictation/recorder"
+)
+
+// calibration is the measured ambient noise of one input device.
+type calibration struct {
+	// NoiseFloorDB is the level (dBFS) that nine in ten 30ms frames of
+	// silence stay under.
+	NoiseFloorDB float64   `json:"noise_floor_db"`
+	Time         time.Time `json:"time"`
+}
+
+// speechMarginDB is how far above the noise floor a frame has to be to count
+// as speech.
+const speechMarginDB = 10
+
+func calibrationPath() string {
+	return filepath.Join(stateDir(), "calibration.json")
+}
+
+// inputDevice names the device the recorder uses, so calibrations made with
+// a headset do not apply to the laptop microphone.
+func inputDevice() string {
+	if !isMac && !isWindows && pathExists("pactl") {
+		if out, err := exec.Command("pactl", "get-default-source").Output(); err == nil {
+			if d := strings.TrimSpace(string(out)); d != "" {
+				return d
+			}
+		}
+	}
+	return "default"
+}
+
+func loadCalibrations() (map[string]calibration, error) {
+	cals := map[string]calibration{}
+	b, err := os.ReadFile(calibrationPath())
+	if os.IsNotExist(err) {
+		return cals, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cals, json.Unmarshal(b, &cals)
+}
+
+// noiseFloor returns the calibrated noise floor of the current input
+// device, if it has been measured.
+func noiseFloor() (float64, bool) {
+	cals, err := loadCalibrations()
+	if err != nil {
+		return 0, false
+	}
+	c, ok := cals[inputDevice()]
+	return c.NoiseFloorDB, ok
+}
+
+// frameDB is the RMS level of 16-bit little-endian PCM in dBFS, -100 for
+// digital silence.
+func frameDB(frame []byte) float64 {
+	var sum float64
+	n := len(frame) / 2
+	for i := 0; i < n; i++ {
+		s := float64(int16(binary.LittleEndian.Uint16(frame[2*i:]))) / 32768
+		sum += s * s
+	}
+	if n == 0 || sum == 0 {
+		return -100
+	}
+	return 10 * math.Log10(sum/float64(n))
+}
+
+// frameLevels splits the samples of a 16-bit mono WAV into 30ms frames and
+// returns their levels.
+func frameLevels(b []byte) ([]float64, error) {
+	info, err := audio.ParseWAV(b)
+	if err != nil {
+		return nil, err
+	}
+	if info.Channels != 1 || info.BitsPerSample != 16 {
+		return nil, fmt.Errorf("need 16-bit mono audio, got %d channels of %d bits", info.Channels, info.BitsPerSample)
+	}
+	data := b[info.DataOffset : info.DataOffset+info.DataLen]
+	size := 2 * info.SampleRate * 30 / 1000
+	var levels []float64
+	for off := 0; off+size <= len(data); off += size {
+		levels = append(levels, frameDB(data[off:off+size]))
+	}
+	return levels, nil
+}
+
+// percentile returns the level that fraction p of levels stay under.
+func percentile(levels []float64, p float64) float64 {
+	s := append([]float64(nil), levels...)
+	sort.Float64s(s)
+	return s[min(len(s)-1, int(p*float64(len(s))))]
+}
+
+// checkLevels looks at a finished recording against the calibrated noise
+// floor. It reports dead air, a recording with nothing above the floor, as
+// an error, and returns a warning when the speech was barely above it.
+func checkLevels(wav string) (warning string, err error) {
+	floor, ok := noiseFloor()
+	if !ok {
+		return "", nil
+	}
+	b, err := os.ReadFile(wav)
+	if err != nil {
+		return "", nil
+	}
+	levels, err := frameLevels(b)
+	if err != nil || len(levels) == 0 {
+		return "", nil
+	}
+	peak := percentile(levels, 0.98)
+	switch {
+	case peak < floor+speechMarginDB/2:
+		return "", errors.New("nothing but background noise was recorded")
+	case peak < floor+speechMarginDB:
+		return "The recording was barely louder than the background — move closer to the microphone or raise its level", nil
+	}
+	return "", nil
+}
+
+// runCalibrate implements `dictate calibrate [--seconds N]`: it records N
+// seconds of the room, stores the noise floor for the current input device
+// and says whether the room is too noisy for reliable detection.
+func runCalibrate(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	seconds := fs.Float64("seconds", 3, "how long to sample, in seconds")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	f, err := os.CreateTemp("", "dictation-calibrate-*.wav")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	fmt.Fprintf(os.Stderr, "Stay quiet for %g seconds…\n", *seconds)
+	cmd := recorder.Command(f.Name())
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start recorder: %v", err)
+	}
+	time.Sleep(time.Duration(*seconds * float64(time.Second)))
+	if err := recorder.StopProcess(cmd.Process.Pid); err != nil {
+		return err
+	}
+	cmd.Wait()
+	if isWindows {
+		if err := audio.RepairWAVHeader(f.Name()); err != nil {
+			return err
+		}
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+	levels, err := frameLevels(b)
+	if err != nil {
+		return err
+	}
+	if len(levels) < 10 {
+		return errors.New("recording too short to calibrate")
+	}
+
+	device := inputDevice()
+	c := calibration{NoiseFloorDB: math.Round(percentile(levels, 0.9)*10) / 10, Time: time.Now()}
+	unlock, err := lockFile(calibrationPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	cals, err := loadCalibrations()
+	if err != nil {
+		return err
+	}
+	cals[device] = c
+	out, err := json.MarshalIndent(cals, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(calibrationPath(), out, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("noise floor of %s: %.1f dBFS (speech from %.1f dBFS)\n", device, c.NoiseFloorDB, c.NoiseFloorDB+speechMarginDB)
+	if c.NoiseFloorDB > -35 {
+		fmt.Println("This is a noisy room: auto-stop may not trigger; consider the silero VAD backend or a headset.")
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/dictation/audio"
+)
+
+// Exit codes for scripted use.
+const (
+	exitFailure = 1
+	exitUsage   = 2
+	// exitEmpty means the transcription succeeded but nothing was said.
+	exitEmpty = 3
+)
+
+// exitError carries a specific exit code up to main.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string { return e.err.Error() }
+
+// transcribeResult is the --json output for one file, and the content of
+// its metadata sidecar.
+type transcribeResult struct {
+	File          string  `json:"file"`
+	Text          string  `json:"text"`
+	Language      string  `json:"language,omitempty"`
+	AudioDuration float64 `json:"audio_duration"`
+	Provider      string  `json:"backend"`
+	Model         string  `json:"model"`
+	Confidence    float64 `json:"confidence,omitempty"`
+	Latency       struct {
+		EncodeMS      int64 `json:"encode_ms"`
+		RequestMS     int64 `json:"request_ms"`
+		PostprocessMS int64 `json:"postprocess_ms"`
+		TotalMS       int64 `json:"total_ms"`
+	} `json:"latency"`
+	Words []Word `json:"words,omitempty"`
+}
+
+// runTranscribe implements `dictate transcribe [--json] file.wav...`: it
+// transcribes the given files and prints the text, one transcript (or JSON
+// object) per line, without typing, notifying or deleting anything. With
+// several files failures do not stop the batch.
+func runTranscribe(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: dictate transcribe [--stdout] [--notify] [--sidecar] [--parallel N] [--rpm N] [--fresh] [--json | --format srt|vtt|words] file.wav...")
+		fs.PrintDefaults()
+	}
+	// stdout is where transcripts go anyway; the flag is for scripts that
+	// say so
+	fs.Bool("stdout", false, "print to stdout (the default)")
+	asJSON := fs.Bool("json", false, "print a JSON object per file with text, duration, backend, model, latency and confidence")
+	sidecar := fs.Bool("sidecar", false, "write file.meta.json next to each input with backend, model, language, duration, timings and confidence")
+	workers := fs.Int("parallel", 0, "number of files transcribed at once (default batch_workers, 1)")
+	rpm := fs.Int("rpm", 0, "at most this many requests per minute (default batch_rpm, unlimited)")
+	fresh := fs.Bool("fresh", false, "transcribe every file again instead of skipping the ones an earlier run finished")
+	notifyDone := fs.Bool("notify", false, "show a desktop notification when done; batches get a periodic digest")
+	format := fs.String("format", formatText, "output format: text, srt, vtt or words (start, end, word per line)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError{exitUsage, errors.New("no input files")}
+	}
+
+	switch *format {
+	case formatText:
+	case formatSRT, formatVTT:
+		// segments come with the verbose response
+		cfg.wantDetails = true
+	case formatWords:
+		cfg.WordTimestamps = true
+	default:
+		return exitError{exitUsage, fmt.Errorf("unknown format %q", *format)}
+	}
+	if *asJSON && *format != formatText {
+		return exitError{exitUsage, errors.New("--json and --format are exclusive")}
+	}
+	if *sidecar {
+		cfg.MetadataSidecar = true
+	}
+	if *asJSON || cfg.MetadataSidecar {
+		cfg.wantDetails = true
+	}
+	cfg.quiet = true
+	// one transcribes a single file and prints or exports the result to w.
+	one := func(path string, w io.Writer) (string, error) {
+		start := time.Now()
+		res, err := transcribeFile(context.Background(), cfg, path)
+		if err != nil {
+			return "", err
+		}
+		ppStart := time.Now()
+		text := postProcess(cfg, res.Text)
+		out := newTranscribeResult(cfg, path, text, res, start, ppStart)
+		if cfg.MetadataSidecar {
+			if err := writeSidecar(path, out); err != nil {
+				return text, err
+			}
+		}
+		if *format != formatText {
+			return text, exportTranscript(w, *format, path, res, fs.NArg() > 1)
+		}
+		if !*asJSON {
+			_, err := fmt.Fprintln(w, text)
+			return text, err
+		}
+		return text, json.NewEncoder(w).Encode(out)
+	}
+
+	if fs.NArg() == 1 {
+		text, err := one(fs.Arg(0), os.Stdout)
+		if *notifyDone {
+			notifyResult(err)
+		}
+		if err != nil {
+			return exitError{exitFailure, fmt.Errorf("%s: %v", fs.Arg(0), err)}
+		}
+		if strings.TrimSpace(text) == "" {
+			return exitError{exitEmpty, errors.New("no speech recognised")}
+		}
+		return nil
+	}
+
+	// A batch keeps going past failures; they are reported on stderr and
+	// recorded in the job for `dictate jobs`. Files are transcribed by
+	// several workers at once, but their output is printed in input order.
+	files := fs.Args()
+	j, err := startJob(files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not record job:", err)
+	}
+	var dg *digest
+	if *notifyDone {
+		dg = newDigest(time.Duration(cfg.BatchNotifyInterval) * time.Second)
+		defer dg.flush()
+	}
+	if *workers > 0 {
+		cfg.BatchWorkers = *workers
+	}
+	if *rpm > 0 {
+		cfg.BatchRPM = *rpm
+	}
+	limit := newRateLimiter(cfg.BatchRPM)
+	prog := newProgress(len(files))
+	// results are only reused for the same output, backend and
+	// post-processing settings
+	mode := fmt.Sprintf("%s|%v|%v|%s|%s|%s|%s|%s|%v|%q|%s", *format, *asJSON, cfg.MetadataSidecar, cfg.Provider, cfg.Model,
+		cfg.forceModel, cfg.Profile, cfg.Language, cfg.Translate, cfg.TranscriptionPrompt, postProcessKey(cfg))
+	exported := *format != formatText
+	// exports are remembered as the paths they were written to, other
+	// output as the transcripts themselves, which must not sit unredacted
+	// in the state directory when the history is redacted
+	resumable := !*fresh && (exported || len(cfg.Redact) == 0)
+
+	type result struct {
+		out  bytes.Buffer
+		done bool
+	}
+	results := make([]result, len(files))
+	var (
+		mu      sync.Mutex
+		next    int // next result to print
+		empty   = true
+		failed  int
+		skipped int
+	)
+	finish := func(i int, text string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failed++
+			prog.logf("%s: %v\n", files[i], err)
+			j.Files[i].Status, j.Files[i].Error = jobFailed, err.Error()
+		} else {
+			j.Files[i].Status = jobDone
+			if strings.TrimSpace(text) != "" {
+				empty = false
+			}
+		}
+		prog.add(err)
+		if dg != nil {
+			dg.add(err)
+		}
+		results[i].done = true
+		for next < len(files) && results[next].done {
+			os.Stdout.Write(results[next].out.Bytes())
+			results[next].out.Reset()
+			next++
+		}
+		if next == len(files) {
+			j.Finished = time.Now()
+		}
+		if err := saveJob(j); err != nil {
+			prog.logf("warning: could not record job: %v\n", err)
+		}
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(cfg.BatchWorkers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				key := ""
+				if resumable {
+					m := mode
+					if exported {
+						// the export goes next to this input, not the one
+						// the same audio was first transcribed from
+						abs, _ := filepath.Abs(files[i])
+						m += "|" + abs
+					}
+					key = resumeKey(files[i], m)
+					if out, ok := loadResume(key, exported); ok {
+						results[i].out.Write(out)
+						mu.Lock()
+						skipped++
+						mu.Unlock()
+						finish(i, string(out), nil)
+						continue
+					}
+				}
+				limit.wait()
+				text, err := one(files[i], &results[i].out)
+				if err == nil {
+					saveResume(key, results[i].out.Bytes())
+				}
+				finish(i, text, err)
+			}
+		}()
+	}
+	for i := range files {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	prog.finish()
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d files were done in an earlier run and skipped (--fresh redoes them)\n", skipped, len(files))
+	}
+
+	if failed > 0 {
+		return exitError{exitFailure, fmt.Errorf("%d of %d files failed, see `dictate jobs %s`", failed, fs.NArg(), j.ID)}
+	}
+	if empty {
+		return exitError{exitEmpty, errors.New("no speech recognised")}
+	}
+	return nil
+}
+
+// newTranscribeResult collects what is known about one transcription;
+// start and ppStart are when the request and the post-processing began.
+func newTranscribeResult(cfg Config, path, text string, res transcription, start, ppStart time.Time) transcribeResult {
+	out := transcribeResult{File: path, Text: text, Language: res.Language, Provider: res.Provider, Model: res.Model,
+		Confidence: res.Confidence, Words: res.Words, AudioDuration: res.Duration}
+	if out.Language == "" {
+		out.Language = cfg.Language
+	}
+	if d, err := audio.WAVDuration(path); err == nil {
+		out.AudioDuration = d
+	}
+	out.Latency.EncodeMS = res.EncodeTime.Milliseconds()
+	out.Latency.RequestMS = res.RequestTime.Milliseconds()
+	out.Latency.PostprocessMS = time.Since(ppStart).Milliseconds()
+	out.Latency.TotalMS = time.Since(start).Milliseconds()
+	return out
+}
+
+// writeSidecar stores r as file.meta.json next to the audio, for tooling
+// that filters transcripts by confidence, backend or language.
+func writeSidecar(path string, r transcribeResult) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	dst := strings.TrimSuffix(path, filepath.Ext(path)) + ".meta.json"
+	return writeFileAtomic(dst, append(b, '\n'), 0644)
+}
+
+// notifyResult reports a single finished transcription.
+func notifyResult(err error) {
+	if err != nil {
+		notifyFailure("Dictation", "Transcription failed: "+err.Error())
+		return
+	}
+	notifyUser("Dictation", "1 file transcribed")
+}
+
+// exportTranscript prints res in a timed format. With several input files
+// each export is written next to its input (file.srt, file.vtt,
+// file.words.txt) instead, since concatenated subtitle files are useless.
+func exportTranscript(w io.Writer, format, path string, res transcription, toFile bool) error {
+	var out, ext string
+	switch format {
+	case formatSRT, formatVTT:
+		if len(res.Segments) == 0 {
+			return fmt.Errorf("%s: backend returned no segment timings", path)
+		}
+		out, ext = formatSubtitles(format, res.Segments), "."+format
+	case formatWords:
+		if len(res.Words) == 0 {
+			return fmt.Errorf("%s: backend returned no word timings", path)
+		}
+		out, ext = formatWordList(res.Words), ".words.txt"
+	}
+	if !toFile {
+		_, err := fmt.Fprint(w, out)
+		return err
+	}
+	dst := strings.TrimSuffix(path, filepath.Ext(path)) + ext
+	if err := os.WriteFile(dst, []byte(out), 0644); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, dst)
+	return err
+}
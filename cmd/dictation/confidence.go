@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log/slog"
+)
+
+// With min_confidence set, a transcript the provider is unsure of is not
+// typed as it is: it opens in the review dialog, or with low_confidence
+// "clipboard" (or when no dialog can be shown) is only copied to the
+// clipboard. The confidence is the mean segment probability of verbose
+// responses, which toggles then ask for from the providers that have them;
+// providers that report none are never held back.
+
+// What happens to a low-confidence transcript (low_confidence).
+const (
+	lowConfidenceReview    = "review"
+	lowConfidenceClipboard = "clipboard"
+)
+
+func validLowConfidence(s string) bool {
+	return s == "" || s == lowConfidenceReview || s == lowConfidenceClipboard
+}
+
+// lowConfidence returns what to do with res, lowConfidenceReview or
+// lowConfidenceClipboard, when it is below min_confidence, and "" when it
+// can be typed.
+func lowConfidence(cfg Config, res transcription) string {
+	if cfg.MinConfidence <= 0 || res.Confidence <= 0 || res.Confidence >= cfg.MinConfidence {
+		return ""
+	}
+	action := lowConfidenceClipboard
+	if cfg.LowConfidence != lowConfidenceClipboard && canReview(cfg) {
+		action = lowConfidenceReview
+	}
+	slog.Info("low confidence transcript", "provider", res.Provider, "confidence", res.Confidence,
+		"min_confidence", cfg.MinConfidence, "action", action)
+	return action
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user/dictation/postprocess"
+	"github.com/user/dictation/provider"
+)
+
+// Config holds user settings. It is read from
+// $XDG_CONFIG_HOME/dictation/config.json; any field missing from the file
+// keeps its default value.
+type Config struct {
+	// MaxTypeLength is the transcript length (in characters) above which the
+	// user has to confirm before the text is typed. 0 disables the guard.
+	MaxTypeLength int `json:"max_type_length"`
+	// Review shows every transcript in an editable dialog before it is
+	// inserted (see review.go); ReviewDialog picks zenity, yad or rofi, the
+	// first one installed by default.
+	Review       bool   `json:"review"`
+	ReviewDialog string `json:"review_dialog"`
+	// MinConfidence (0 to 1, off at 0) is the confidence below which a
+	// transcript is not typed straight away; LowConfidence says what
+	// happens instead, "review" (the default) or "clipboard". See
+	// confidence.go.
+	MinConfidence float64 `json:"min_confidence"`
+	LowConfidence string  `json:"low_confidence"`
+
+	// Output selects how the transcript is delivered (see output.go).
+	Output string `json:"output"`
+	// ClipboardOnly always delivers to the clipboard, whatever the
+	// profile, app rules and routes say, as --clipboard-only does.
+	ClipboardOnly bool `json:"clipboard_only"`
+	// OutputFile is the file transcripts are appended to in "file" mode.
+	// It may use strftime directives and template fields, e.g.
+	// "~/notes/{{slug .Window.Title}}.md".
+	OutputFile string `json:"output_file"`
+	// NvimServer is the address of the Neovim the "nvim" mode pastes into
+	// and EmacsServer the server name or socket for "emacs"; see editor.go.
+	NvimServer  string `json:"nvim_server"`
+	EmacsServer string `json:"emacs_server"`
+	// Obsidian is the vault note the "obsidian" mode appends to; see
+	// obsidian.go.
+	Obsidian *ObsidianConfig `json:"obsidian"`
+	// OutputTemplate wraps the text before it is delivered, e.g.
+	// "[{{.Window.Class}}] {{.Text}}"; see sinkData for the fields.
+	OutputTemplate string `json:"output_template"`
+	// sink is the template data of the transcript being delivered.
+	sink sinkData
+	// OutputTimestamp prefixes every entry in "file" mode (strftime-style).
+	// Empty writes the bare text.
+	OutputTimestamp string `json:"output_timestamp"`
+	// AppRules adjust the output for specific focused applications.
+	AppRules []AppRule `json:"app_rules"`
+	// Routes are routing lines evaluated per transcript after the app
+	// rules; see routes.go for the syntax.
+	Routes []string `json:"routes"`
+	routes []route
+	// Hotkeys binds key combos ("super+shift+d") to actions such as
+	// toggle, push-to-talk or profile:code; see actions.go.
+	Hotkeys map[string]string `json:"hotkeys"`
+	// PushToTalk is a key or mouse button (KEY_RIGHTCTRL, BTN_SIDE) the
+	// daemon records while it is held; see pushtotalk.go.
+	PushToTalk string `json:"push_to_talk"`
+	// HotkeyListener has the daemon listen for the hotkeys itself: "x11",
+	// "evdev" or "auto"; see hotkeylisten.go.
+	HotkeyListener string `json:"hotkey_listener"`
+	// Continuous tunes continuous dictation, which the daemon runs while
+	// the continuous action has it on; see continuous.go.
+	Continuous ContinuousConfig `json:"continuous"`
+	// ServeToken is the bearer token `dictate serve` asks clients for.
+	ServeToken string `json:"serve_token"`
+	// GRPCListen is where the daemon serves the gRPC API
+	// (api/dictation.proto): "unix:PATH" or a loopback "host:port". It needs
+	// a build with -tags grpc.
+	GRPCListen string `json:"grpc_listen"`
+	// WakeWord has the daemon start a recording when it hears a wake
+	// word; see wakeword.go.
+	WakeWord *WakeWordConfig `json:"wake_word"`
+	// HomeAssistant is the instance the assist output mode talks to; see
+	// homeassistant.go.
+	HomeAssistant *HomeAssistantConfig `json:"home_assistant"`
+	// MetricsListen is where the daemon serves Prometheus metrics, e.g.
+	// "127.0.0.1:9464"; see latency.go.
+	MetricsListen string `json:"metrics_listen"`
+	// MQTT has the daemon publish its state and transcripts to a broker;
+	// see mqtt.go.
+	MQTT *MQTTConfig `json:"mqtt"`
+	// Pedals are foot pedals and other HID buttons the daemon reads; see
+	// pedal.go.
+	Pedals []Pedal `json:"pedals"`
+	// SessionSeparator goes between the dictations of a session (a blank
+	// line by default); see session.go.
+	SessionSeparator string `json:"session_separator"`
+	// NotesFile is where the private-note action appends to (notes.md in
+	// the data directory by default).
+	NotesFile string `json:"notes_file"`
+	// Hooks are shell commands run before and after every insertion; see
+	// hooks.go.
+	Hooks Hooks `json:"hooks"`
+	// HumanTyping sets the speed of the "human" output mode.
+	HumanTyping HumanTyping `json:"human_typing"`
+	// LogLevel is how much goes to dictation.log in the state directory:
+	// debug, info (default), warn, error or off; see logging.go.
+	LogLevel string `json:"log_level"`
+	// dryRun is set by --dry-run; see dryrun.go.
+	dryRun bool
+	// outputFromFlag is set when --output was given, or in clipboard-only
+	// mode; app rules then leave the output mode alone.
+	outputFromFlag bool
+
+	// Model is the transcription model for OpenAI and for providers that
+	// don't set their own.
+	Model string `json:"model"`
+	// Provider selects the transcription provider by name ("openai",
+	// "worker" or one from Providers), or is an exec plugin
+	// ("exec:/path/to/program"; see plugin.go).
+	Provider  string              `json:"provider"`
+	Providers []provider.Provider `json:"providers"`
+	// APIKeyFile and APIKeyCmd supply the OpenAI key (for transcription, the
+	// LLM and speech) from a file or the output of a shell command such as
+	// "pass show openai", so it need not be in the environment.
+	APIKeyFile string `json:"api_key_file"`
+	APIKeyCmd  string `json:"api_key_cmd"`
+	// AutoRoute lets the routing stats pick the provider that has performed
+	// best (lowest correction rate and latency) for this user.
+	AutoRoute bool `json:"auto_route"`
+	// Worker, when set, runs a local OpenAI-compatible transcription server
+	// in a container and sends requests there instead of to OpenAI.
+	Worker *WorkerConfig `json:"worker"`
+
+	// ReencodeFormat is the format (file extension) audio is converted to
+	// when a provider rejects the original; "flac" by default.
+	ReencodeFormat string `json:"reencode_format"`
+
+	// Language is the ISO 639-1 code of the spoken language, sent as a hint
+	// to the provider. Empty lets the provider detect it.
+	Language string `json:"language"`
+	// TranscriptionPrompt is sent to the provider as the Whisper prompt,
+	// e.g. names and jargon it should spell the way you do.
+	TranscriptionPrompt string `json:"transcription_prompt"`
+	// RequestTimeout is how long a transcription request may take, in
+	// seconds (120 by default); providers can set their own "timeout".
+	RequestTimeout float64 `json:"request_timeout"`
+	// Denoise runs the recording through ffmpeg's FFT denoiser before it
+	// is uploaded.
+	Denoise bool `json:"denoise"`
+	// Punctuation applies language-specific punctuation rules; "fr" puts
+	// no-break spaces before ; : ! ? and inside « ».
+	Punctuation string `json:"punctuation"`
+	// TypingLayout is the keyboard layout xdotool types with ("us" by
+	// default). "keep" leaves the active layout and input method alone,
+	// "auto" keeps a single Latin layout and otherwise switches to the
+	// first Latin one configured.
+	TypingLayout string `json:"typing_layout"`
+
+	// Translate sends audio to the translation endpoint, so speech in any
+	// language comes out as English text.
+	Translate bool `json:"translate"`
+
+	// WordTimestamps requests per-word timings from the API and keeps the
+	// last recording so correction UIs can play back single words.
+	WordTimestamps bool `json:"word_timestamps"`
+
+	// ReplacementsFile holds literal and regex replacement rules applied to
+	// every transcript. Empty disables replacements.
+	ReplacementsFile string `json:"replacements_file"`
+
+	// SpokenPunctuation turns spoken "comma", "period", "new line", "new
+	// paragraph" etc. into punctuation and line breaks. Turn it off (e.g.
+	// in a profile) when dictating text that contains those words.
+	SpokenPunctuation bool `json:"spoken_punctuation"`
+
+	// CodeMode maps spoken programming constructs to code ("equals
+	// equals", "open paren", "camel case user name") instead of prose. It
+	// replaces spoken punctuation and language punctuation rules.
+	CodeMode bool `json:"code_mode"`
+	// VoiceCommands makes utterances like "scratch that" edit the last
+	// dictation instead of being typed.
+	VoiceCommands bool `json:"voice_commands"`
+
+	// VAD picks the voice activity detector and enables auto-stop.
+	VAD VADConfig `json:"vad"`
+
+	// Sounds configures progress ticks and completion tones.
+	Sounds SoundTheme `json:"sounds"`
+
+	// TTS is the speech provider for speak-selection. Empty (or name
+	// "system") uses the system synthesizer; {"name": "openai"} the OpenAI
+	// speech endpoint with TTSVoice.
+	TTS      provider.Provider `json:"tts"`
+	TTSVoice string            `json:"tts_voice"`
+
+	// LLM is the chat completions endpoint for post-processing; empty fields
+	// default to OpenAI's gpt-4o-mini.
+	LLM provider.Provider `json:"llm"`
+	// Prompt enables LLM post-processing with this system prompt: either
+	// the name of an entry in Prompts or the prompt text itself.
+	Prompt  string            `json:"prompt"`
+	Prompts map[string]string `json:"prompts"`
+	// PostProcessor is an exec plugin ("exec:/path/to/program") the
+	// transcript goes through after the LLM stage; see plugin.go.
+	PostProcessor string `json:"post_processor"`
+
+	// KeepAudio archives recordings in AudioArchiveDir (by default
+	// audio/ in the data directory) instead of deleting them once they
+	// are transcribed.
+	KeepAudio       bool   `json:"keep_audio"`
+	AudioArchiveDir string `json:"audio_archive_dir"`
+
+	// Redact lists patterns hidden in the history; the typed text is not
+	// affected.
+	Redact []RedactRule `json:"redact"`
+
+	// Retention prunes old archived audio and history automatically
+	// (once a day) and with `dictate gc`.
+	Retention Retention `json:"retention"`
+
+	// Watch lists the directories `dictate watch` transcribes new audio
+	// files from.
+	Watch []WatchRule `json:"watch"`
+	// MetadataSidecar writes file.meta.json next to every file transcribed
+	// by `transcribe` or `watch`.
+	MetadataSidecar bool `json:"metadata_sidecar"`
+	// BatchWorkers is how many files `transcribe` works on at once, and
+	// BatchRPM caps its requests per minute (0 means no cap), so large
+	// backlogs stay under provider rate limits.
+	BatchWorkers int `json:"batch_workers"`
+	BatchRPM     int `json:"batch_rpm"`
+	// BatchNotifyInterval is the number of seconds between digest
+	// notifications while a batch runs with --notify.
+	BatchNotifyInterval int `json:"batch_notify_interval"`
+	// WhenBusy decides what a toggle does while another one is still
+	// running: "abort" (default) interrupts a transcription in flight,
+	// keeping its recording for `dictate retry`, and starts recording;
+	// "ignore" drops the press, "queue" runs it afterwards. Presses while
+	// the other toggle does something else than transcribing are ignored
+	// unless queued.
+	WhenBusy string `json:"when_busy"`
+	// FailureAlertAfter is how many failures of the same kind in a row
+	// (recording, transcription, insertion) raise a troubleshooting
+	// notification; 0 turns it off.
+	FailureAlertAfter int `json:"failure_alert_after"`
+	// TimerNotification has the daemon keep a notification up with the
+	// recording time and then "Transcribing…" (Linux; on by default).
+	TimerNotification bool `json:"timer_notification"`
+	// ResultNotification has the daemon show each transcript with Copy
+	// again, Retry, Discard and Open history buttons (Linux).
+	ResultNotification bool `json:"result_notification"`
+	// inDaemon is set for toggles the daemon runs.
+	inDaemon bool
+	// Metrics keeps a local log of latencies, corrections and failures
+	// for `dictate report`.
+	Metrics bool `json:"metrics"`
+
+	// Casing is the casing policy applied as the last post-processing step.
+	Casing string `json:"casing"`
+
+	// Profile names the entry of Profiles to apply on top of the settings
+	// above. Empty means no profile.
+	Profile  string             `json:"profile"`
+	Profiles map[string]Profile `json:"profiles"`
+
+	// Portable is set by --portable; it is never read from the file.
+	Portable bool `json:"-"`
+	// wantDetails asks providers for the verbose response (confidence,
+	// duration) even without word timestamps; set by --json.
+	wantDetails bool
+	// quiet keeps post-processing failures off the desktop (they still go
+	// to stderr); set by `dictate transcribe`, which has no side effects.
+	quiet bool
+	// forceModel overrides every provider's model; set by retranscribe
+	// --model.
+	forceModel string
+}
+
+// Profile overrides a subset of Config. Empty fields inherit the top-level
+// setting.
+type Profile struct {
+	Casing            string `json:"casing"`
+	Translate         *bool  `json:"translate"`
+	SpokenPunctuation *bool  `json:"spoken_punctuation"`
+	CodeMode          *bool  `json:"code_mode"`
+	// Prompt overrides the LLM prompt; "none" turns the stage off.
+	Prompt string `json:"prompt"`
+	// Provider, Model and Language pick the transcription backend and
+	// hint for this kind of dictation.
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Language string `json:"language"`
+	// ReplacementsFile swaps in a profile's own replacement rules; "none"
+	// turns replacements off.
+	ReplacementsFile string `json:"replacements_file"`
+	Output           string `json:"output"`
+	OutputTemplate   string `json:"output_template"`
+}
+
+// applyProfile overlays the named profile onto cfg.
+func applyProfile(cfg *Config, name string) error {
+	if name == "" {
+		return nil
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	cfg.Profile = name
+	if p.Casing != "" {
+		cfg.Casing = p.Casing
+	}
+	if p.Translate != nil {
+		cfg.Translate = *p.Translate
+	}
+	if p.SpokenPunctuation != nil {
+		cfg.SpokenPunctuation = *p.SpokenPunctuation
+	}
+	if p.CodeMode != nil {
+		cfg.CodeMode = *p.CodeMode
+	}
+	if p.Provider != "" {
+		cfg.Provider = p.Provider
+	}
+	if p.Model != "" {
+		cfg.Model = p.Model
+	}
+	if p.Language != "" {
+		cfg.Language = p.Language
+	}
+	switch p.ReplacementsFile {
+	case "":
+	case "none":
+		cfg.ReplacementsFile = ""
+	default:
+		cfg.ReplacementsFile = p.ReplacementsFile
+	}
+	if p.OutputTemplate != "" {
+		cfg.OutputTemplate = p.OutputTemplate
+	}
+	if p.Output != "" {
+		cfg.Output = p.Output
+	}
+	switch p.Prompt {
+	case "":
+	case "none":
+		cfg.Prompt = ""
+	default:
+		cfg.Prompt = p.Prompt
+	}
+	return nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		MaxTypeLength:       1000,
+		Output:              outputAuto,
+		Model:               "whisper-1",
+		OutputTimestamp:     "%Y-%m-%d %H:%M:%S",
+		Casing:              postprocess.CasingNone,
+		VoiceCommands:       true,
+		BatchNotifyInterval: 60,
+		BatchWorkers:        1,
+		FailureAlertAfter:   3,
+		WhenBusy:            busyAbort,
+		LowConfidence:       lowConfidenceReview,
+		TimerNotification:   true,
+		Sounds:              SoundTheme{Volume: 100},
+	}
+}
+
+// portableDir is set in portable mode; everything that would normally go
+// under the XDG directories lives in it instead.
+var portableDir string
+
+// enablePortable points all state at a dictation-data directory next to the
+// binary and makes it the working directory, so relative paths in its config
+// resolve there too.
+func enablePortable() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(filepath.Dir(exe), "dictation-data")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	portableDir = dir
+	return os.Chdir(dir)
+}
+
+func configDir() string {
+	if portableDir != "" {
+		return portableDir
+	}
+	if d := os.Getenv("XDG_CONFIG_HOME"); d != "" {
+		return filepath.Join(d, "dictation")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "dictation")
+}
+
+// loadConfig returns the defaults overlaid with the config file. On first
+// run, when there is no config file, one is created from the locale (see
+// detectLocaleDefaults).
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+	path := filepath.Join(configDir(), "config.json")
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		d := detectLocaleDefaults()
+		if err := writeFirstRunConfig(path, d); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: could not write config:", err)
+		}
+		b, err = json.Marshal(d)
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("config: %v", err)
+	}
+	if err := compileRedactions(cfg.Redact); err != nil {
+		return cfg, fmt.Errorf("config: %v", err)
+	}
+	if err := compileRoutes(&cfg); err != nil {
+		return cfg, fmt.Errorf("config: %v", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/user/dictation/notify"
+)
+
+const previewLength = 300
+
+// confirmInsert asks the user whether a long transcript should really be
+// typed. It shows a preview with zenity when available, otherwise it falls
+// back to a notification with actions. If no way of asking is available the
+// answer is no.
+func confirmInsert(text string) bool {
+	n := len([]rune(text))
+	preview := text
+	if r := []rune(text); len(r) > previewLength {
+		preview = string(r[:previewLength]) + "…"
+	}
+	msg := fmt.Sprintf("Type %d characters into the focused window?\n\n%s", n, preview)
+
+	if isMac {
+		return macConfirm(msg)
+	}
+	if isWindows {
+		return winConfirm(msg)
+	}
+
+	if pathExists("zenity") {
+		// zenity exits 0 on OK, 1 on Cancel
+		cmd := exec.Command("zenity", "--question", "--title=Dictation",
+			"--ok-label=Type", "--cancel-label=Cancel", "--no-markup", "--width=500", "--text", msg)
+		return cmd.Run() == nil
+	}
+
+	action, err := notify.WaitAction(notify.Notification{Summary: "Dictation", Body: msg,
+		Timeout: -1, Actions: []string{"type", "Type", "cancel", "Cancel"}})
+	return err == nil && action == "type"
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/user/dictation/audio"
+	"github.com/user/dictation/recorder"
+)
+
+// Continuous dictation: the daemon keeps the microphone open, cuts the
+// audio into segments at the pauses the voice activity detector finds, and
+// transcribes and types each one while it goes on listening, until the
+// continuous action is run again.
+
+// ContinuousConfig tunes how continuous dictation segments speech.
+type ContinuousConfig struct {
+	// Pause is the seconds of silence that end a segment (default 0.8).
+	Pause float64 `json:"pause"`
+	// MaxSegment is the longest a segment gets, in seconds, before it is
+	// cut even without a pause (default 30).
+	MaxSegment float64 `json:"max_segment"`
+}
+
+const continuousRate = 16000
+
+// preRollMS of audio from before the speech started is kept at the start
+// of each segment, so its first syllable is not cut off.
+const preRollMS = 300
+
+var errContinuous = errors.New("continuous dictation is on; run the continuous action again to stop it")
+
+// continuousSession is a running continuous dictation.
+type continuousSession struct {
+	stop func()
+	// typed is set once a segment has been typed, so the next one starts
+	// with a space.
+	typed bool
+}
+
+// toggleContinuous starts continuous dictation, or stops it when it is on.
+// It is called with d.mu held.
+func (d *daemon) toggleContinuous(profile string) error {
+	if d.continuous != nil {
+		d.continuous.stop()
+		d.continuous = nil
+		playPip(d.cfg, false)
+		notifyUser("Dictation", "Continuous dictation off")
+		return nil
+	}
+	if currentStatus().State != stateIdle {
+		return errors.New("a recording is in progress")
+	}
+	cfg := d.cfg
+	if profile != "" {
+		if err := applyProfile(&cfg, profile); err != nil {
+			return err
+		}
+	}
+	det, err := newVoiceDetector(cfg.VAD, continuousRate)
+	if err != nil {
+		return err
+	}
+	rec := recorder.RawCommand()
+	out, err := rec.StdoutPipe()
+	if err != nil {
+		det.close()
+		return err
+	}
+	if err := rec.Start(); err != nil {
+		det.close()
+		return fmt.Errorf("could not start recorder: %v", err)
+	}
+	s := &continuousSession{stop: func() { rec.Process.Kill() }}
+	d.continuous = s
+
+	segments := make(chan []byte, 8)
+	go func() {
+		for pcm := range segments {
+			if err := d.dictateSegment(cfg, s, pcm); err != nil {
+				notifyFailure("Dictation", err.Error())
+			}
+		}
+	}()
+	go func() {
+		err := segmentSpeech(cfg, det, out, segments)
+		close(segments)
+		det.close()
+		rec.Wait()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.continuous != s {
+			return // stopped
+		}
+		d.continuous = nil
+		if err == nil {
+			err = errors.New("the recorder stopped")
+		}
+		notifyFailure("Dictation", "Continuous dictation stopped: "+err.Error())
+	}()
+	playPip(cfg, true)
+	notifyUser("Dictation", "Continuous dictation on")
+	return nil
+}
+
+// segmentSpeech reads PCM from r and sends each stretch of speech, from
+// just before it starts until the pause after it, to segments. It returns
+// when r ends, after sending the speech that was cut off.
+func segmentSpeech(cfg Config, det voiceDetector, r io.Reader, segments chan<- []byte) error {
+	pause, maxSegment := cfg.Continuous.Pause, cfg.Continuous.MaxSegment
+	if pause <= 0 {
+		pause = 0.8
+	}
+	if maxSegment <= 0 {
+		maxSegment = 30
+	}
+	frame := make([]byte, 2*det.frameSamples())
+	frameMS := float64(det.frameSamples()) * 1000 / continuousRate
+	preRoll := int(preRollMS/frameMS) * len(frame)
+	var (
+		seg      []byte
+		speech   bool
+		silentMS float64
+	)
+	for {
+		if _, err := io.ReadFull(r, frame); err != nil {
+			if speech {
+				segments <- seg
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		ok, err := det.isSpeech(frame)
+		if err != nil {
+			return err
+		}
+		seg = append(seg, frame...)
+		switch {
+		case ok:
+			speech, silentMS = true, 0
+		case speech:
+			silentMS += frameMS
+		case len(seg) > preRoll:
+			seg = append(seg[:0], seg[len(seg)-preRoll:]...)
+		}
+		long := float64(len(seg)) >= maxSegment*continuousRate*2
+		if speech && (silentMS >= pause*1000 || long) {
+			select {
+			case segments <- seg:
+			default:
+				fmt.Fprintln(os.Stderr, "continuous: segment dropped, transcription is falling behind")
+			}
+			seg, speech, silentMS = nil, false, 0
+		}
+	}
+}
+
+// dictateSegment transcribes a segment and types it after the ones before
+// it. Segments still queued when continuous dictation is stopped are typed
+// too.
+func (d *daemon) dictateSegment(cfg Config, s *continuousSession, pcm []byte) error {
+	res, wav, err := transcribePCM(cfg, pcm)
+	if err != nil {
+		return fmt.Errorf("transcription failed: %v", err)
+	}
+	defer os.Remove(wav)
+	if strings.TrimSpace(res.Text) == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cmd, ok := voiceCommand(res.Text); ok && cfg.VoiceCommands && typesIntoWindow(cfg.Output) {
+		return runVoiceCommand(cfg, cmd)
+	}
+	t := keepTranscript(cfg, res, wav)
+	if _, ok := activeSession(); ok {
+		_, err := addToSession(cfg, t.Text)
+		return err
+	}
+	text, err := prepareOutput(&cfg, t.Text, res.Language)
+	if errors.Is(err, errTypingDisabled) || errors.Is(err, errHookDropped) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("insert failed: %v", err)
+	}
+	typing := typesIntoWindow(cfg.Output)
+	if typing && s.typed && text != "" && !unicode.IsSpace([]rune(text)[0]) {
+		text = " " + text
+	}
+	typed, err := insertText(cfg, text)
+	if err != nil {
+		return fmt.Errorf("insert failed: %v", err)
+	}
+	if typed {
+		s.typed = true
+		markInserted(t.ID, text)
+	}
+	return nil
+}
+
+// transcribePCM transcribes a segment of 16 kHz mono PCM. When it
+// succeeds, the WAV the segment was sent as is left for the caller to
+// remove.
+func transcribePCM(cfg Config, pcm []byte) (transcription, string, error) {
+	f, err := os.CreateTemp(runtimeDir(), "dictation-segment-*.wav")
+	if err != nil {
+		return transcription{}, "", err
+	}
+	_, err = f.Write(audio.EncodeWAV(audio.WAVInfo{Channels: 1, SampleRate: continuousRate, BitsPerSample: 16}, pcm))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return transcription{}, "", err
+	}
+	res, err := transcribeFile(context.Background(), cfg, f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return res, "", err
+	}
+	return res, f.Name(), nil
+}
+
+// postDaemon posts to the daemon's API, for actions only the daemon can
+// run.
+func postDaemon(path string, query url.Values) error {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath())
+		},
+	}}
+	u := "http://d" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := client.Post(u, "", nil)
+	if err != nil {
+		return errors.New("this needs `dictate daemon` running")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct{ Error string }
+		if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return errors.New(e.Error)
+	}
+	return nil
+}
+
+func (d *daemon) handleContinuous(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.toggleContinuous(r.URL.Query().Get("profile")); err != nil {
+		httpError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
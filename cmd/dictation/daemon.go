@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/user/dictation/audio"
+	"github.com/user/dictation/recorder"
+)
+
+func socketPath() string {
+	return filepath.Join(runtimeDir(), "dictation.sock")
+}
+
+// daemon serves a small HTTP API on a unix socket so other tools (editor
+// plugins, correction UIs, the browser extension) can drive dictation:
+//
+//	POST /toggle                     start recording / stop and transcribe
+//	POST /continuous                 start or stop continuous dictation;
+//	                                 ?profile=NAME applies a profile
+//	GET  /transcript/last            last transcript as JSON
+//	POST /transcript/last/correction {"text": "..."} replaces the text
+//	POST /transcript/last/insert     re-inserts the (corrected) text
+//	GET  /transcript/last/audio      recording of the last transcript as WAV;
+//	                                 ?word=N limits it to the Nth word
+//
+// e.g. curl --unix-socket $XDG_RUNTIME_DIR/dictation.sock http://d/transcript/last
+type daemon struct {
+	cfg Config
+	// mu serialises actions that touch the recorder or the focused window.
+	mu sync.Mutex
+	// continuous is the continuous dictation going on, if any.
+	continuous *continuousSession
+}
+
+func runDaemon(cfg Config) error {
+	path := socketPath()
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("daemon already running on %s", path)
+	}
+	// stale socket from a previous run
+	_ = os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	if cfg.Worker != nil {
+		fmt.Fprintln(os.Stderr, "starting transcription worker", cfg.Worker.Image)
+		if err := startWorker(cfg.Worker); err != nil {
+			l.Close()
+			return err
+		}
+		if warn := cpuFallbackWarning(cfg); warn != "" {
+			fmt.Fprintln(os.Stderr, warn)
+			notifyUser("Dictation", warn)
+		}
+		defer stopWorker(cfg.Worker)
+		done := make(chan struct{})
+		defer close(done)
+		go superviseWorker(cfg.Worker, done)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+
+	if cfg.TimerNotification && !isMac && !isWindows {
+		done := make(chan struct{})
+		defer close(done)
+		go watchTimer(done)
+	}
+
+	cfg.inDaemon = true
+	d := &daemon{cfg: cfg}
+	recorder.OnCrash = d.salvageRecording
+	for _, p := range cfg.Pedals {
+		go watchPedal(p, d.action)
+	}
+	if cfg.HotkeyListener != "" && !isMac && !isWindows {
+		go listenHotkeys(cfg, d.action)
+	}
+	if cfg.PushToTalk != "" && !isMac && !isWindows {
+		go watchPushToTalk(cfg.PushToTalk, d.action)
+	}
+	if cfg.WakeWord != nil && !isWindows {
+		go d.watchWakeWord(cfg.WakeWord)
+	}
+	if cfg.GRPCListen != "" {
+		stop, err := d.serveGRPC(cfg.GRPCListen)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		defer stop()
+	}
+	if cfg.MQTT != nil {
+		defer startMQTT(cfg.MQTT)()
+	}
+	if cfg.MetricsListen != "" {
+		stop, err := serveMetrics(cfg.MetricsListen)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		defer stop()
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/toggle", d.handleToggle)
+	mux.HandleFunc("/continuous", d.handleContinuous)
+	mux.HandleFunc("/cancel", d.handleCancel)
+	mux.HandleFunc("/transcript/last", d.handleLast)
+	mux.HandleFunc("/transcript/last/correction", d.handleCorrection)
+	mux.HandleFunc("/transcript/last/insert", d.handleInsert)
+	mux.HandleFunc("/transcript/last/audio", d.handleAudio)
+
+	fmt.Fprintln(os.Stderr, "dictation daemon listening on", path)
+	err = http.Serve(l, mux)
+	_ = os.Remove(path)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (d *daemon) handleToggle(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if d.cfg.WhenBusy == busyAbort {
+		abortInProcess(errInterrupted)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.continuous != nil {
+		httpError(w, http.StatusConflict, errContinuous)
+		return
+	}
+	defer d.showResult(lastTranscriptID())
+	if err := toggle(d.cfg); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCancel discards the recording, aborts the transcription in flight
+// or ends continuous dictation, like the cancel action.
+func (d *daemon) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := d.action(actionCancel, ""); err != nil {
+		httpError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// action runs a pedal's or hotkey's action under the lock the HTTP
+// toggles take.
+func (d *daemon) action(action, profile string) error {
+	// the toggle being aborted holds the lock
+	switch {
+	case action == actionCancel && abortInProcess(errCancelled):
+		return nil
+	case action == actionToggle && d.cfg.WhenBusy == busyAbort:
+		abortInProcess(errInterrupted)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case action == actionContinuous:
+		return d.toggleContinuous(profile)
+	case d.continuous != nil && action == actionCancel:
+		return d.toggleContinuous("")
+	case d.continuous != nil:
+		return errContinuous
+	}
+	defer d.showResult(lastTranscriptID())
+	return doAction(d.cfg, action, profile)
+}
+
+// salvageRecording handles a recorder that died mid-recording: what it
+// captured is quarantined for `dictate retry` and the next toggle starts a
+// new recording instead of transcribing the fragment.
+func (d *daemon) salvageRecording(wav string, exitErr error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// a toggle or cancel run outside the daemon stops the recorder too; by
+	// the time it lets go of the lock the wav is dealt with
+	if unlock, err := lockFile(toggleLockPath()); err == nil {
+		defer unlock()
+	}
+	if _, err := os.Stat(wav); err != nil {
+		return
+	}
+	if err := audio.RepairWAVHeader(wav); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not repair wav header:", err)
+	}
+	if secs, err := audio.WAVDuration(wav); err != nil || secs < 0.1 {
+		os.Remove(wav)
+		notifyFailure("Dictation", "Recording failed — the recorder stopped before capturing anything")
+		recordFailure(d.cfg, checkRecorder)
+		return
+	}
+	reason := "exited"
+	if exitErr != nil {
+		reason = exitErr.Error()
+	}
+	fmt.Fprintln(os.Stderr, "recorder stopped unexpectedly:", reason)
+	playCue(d.cfg, cueFailed)
+	notifyFailure("Dictation", "Recording stopped unexpectedly ("+reason+") — what was captured is kept; `dictate retry last` transcribes it")
+	quarantine(wav, stageTranscribe, "", "", fmt.Errorf("recorder stopped unexpectedly: %s", reason))
+	recordFailure(d.cfg, checkRecorder)
+}
+
+func (d *daemon) handleLast(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	t, err := loadLastTranscript()
+	if err != nil {
+		lastTranscriptError(w, err)
+		return
+	}
+	writeJSON(w, t)
+}
+
+func (d *daemon) handleCorrection(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	t, err := correctLastTranscript(d.cfg, "", req.Text)
+	if err != nil {
+		lastTranscriptError(w, err)
+		return
+	}
+	writeJSON(w, t)
+}
+
+func (d *daemon) handleInsert(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t, err := loadLastTranscript()
+	if err != nil {
+		lastTranscriptError(w, err)
+		return
+	}
+	if _, err := insertText(d.cfg, t.FinalText()); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// wordPadding is added around a word's timings when serving its audio, since
+// Whisper's word boundaries tend to clip the first and last phoneme.
+const wordPadding = 0.15
+
+func (d *daemon) handleAudio(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	b, err := os.ReadFile(lastAudioPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			httpError(w, http.StatusNotFound, errors.New("no recording kept; enable word_timestamps"))
+			return
+		}
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if q := r.URL.Query().Get("word"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		t, err := loadLastTranscript()
+		if err != nil {
+			lastTranscriptError(w, err)
+			return
+		}
+		if n < 0 || n >= len(t.Words) {
+			httpError(w, http.StatusNotFound, fmt.Errorf("no word %d", n))
+			return
+		}
+		b, err = audio.SliceWAV(b, t.Words[n].Start-wordPadding, t.Words[n].End+wordPadding)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "audio/wav")
+	_, _ = w.Write(b)
+}
+
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		httpError(w, http.StatusMethodNotAllowed, fmt.Errorf("use %s", method))
+		return false
+	}
+	return true
+}
+
+func lastTranscriptError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		httpError(w, http.StatusNotFound, errors.New("no transcript yet"))
+		return
+	}
+	httpError(w, http.StatusInternalServerError, err)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/dictation/notify"
+	"github.com/user/dictation/recorder"
+)
+
+// Doctor sections, also the failure classes tracked by recordFailure.
+const (
+	checkRecorder      = "recorder"
+	checkTranscription = "transcription"
+	checkTyping        = "typing"
+)
+
+// runDoctor implements `dictate doctor [recorder|transcription|typing]`: it
+// prints what dictation can find on this machine and what it would use, to
+// make setup problems easy to spot. Naming a section limits the output to
+// that subsystem and adds troubleshooting hints for it.
+func runDoctor(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	// --notify N is how troubleshootNotify waits for its action
+	alert := fs.Int("notify", 0, "")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	section := fs.Arg(0)
+	switch section {
+	case "", checkRecorder, checkTranscription, checkTyping:
+	default:
+		return exitError{exitUsage, fmt.Errorf("unknown doctor section %q (recorder, transcription or typing)", section)}
+	}
+	if *alert > 0 && section != "" {
+		return waitTroubleshootAction(cfg, section, *alert)
+	}
+	return doctorReport(os.Stdout, cfg, section)
+}
+
+func doctorReport(out io.Writer, cfg Config, section string) error {
+	show := func(s string) bool { return section == "" || section == s }
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	row := func(name, status, detail string) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, status, detail)
+	}
+	tool := func(name string, alternatives ...string) {
+		for _, t := range alternatives {
+			if pathExists(t) {
+				row(name, "ok", t)
+				return
+			}
+		}
+		row(name, "missing", strings.Join(alternatives, " or "))
+	}
+
+	if show(checkTranscription) {
+		doctorTranscription(cfg, row, tool)
+	}
+	if show(checkRecorder) {
+		if isMac || isWindows {
+			tool("recorder", "sox")
+		} else {
+			tool("recorder", "arecord")
+		}
+	}
+	if show(checkTyping) {
+		switch {
+		case isMac:
+			tool("clipboard", "pbcopy")
+		case isWindows:
+			tool("shell", "powershell")
+		default:
+			switch {
+			case os.Getenv("WAYLAND_DISPLAY") != "":
+				row("session", "wayland", "WAYLAND_DISPLAY="+os.Getenv("WAYLAND_DISPLAY"))
+			case os.Getenv("DISPLAY") != "":
+				row("session", "x11", "DISPLAY="+os.Getenv("DISPLAY"))
+			default:
+				row("session", "none", "neither WAYLAND_DISPLAY nor DISPLAY is set")
+			}
+			tool("typing", "xdotool")
+			tool("keymap", "xmodmap")
+			tool("clipboard", "wl-copy", "xclip", "xsel")
+		}
+		mode := cfg.Output
+		if mode == "" {
+			mode = outputAuto
+		}
+		row("output", mode, "")
+	}
+	if section == "" && !isMac && !isWindows {
+		if server, err := notify.Server(); err == nil {
+			row("notifications", "ok", server)
+		} else {
+			row("notifications", "missing", "no notification daemon on the session bus: "+err.Error())
+		}
+		tool("sound", "ffplay", "paplay", "aplay")
+		for _, p := range cfg.Pedals {
+			if f, err := os.Open(p.path()); err != nil {
+				row("pedal", "missing", err.Error())
+			} else {
+				f.Close()
+				row("pedal", "ok", p.Device)
+			}
+		}
+	}
+	if section == "" {
+		tool("search index", "sqlite3")
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if hints := doctorHints(cfg, section); len(hints) > 0 {
+		fmt.Fprintln(out, "\nThings to check:")
+		for _, h := range hints {
+			fmt.Fprintln(out, "  - "+h)
+		}
+	}
+	return nil
+}
+
+func doctorTranscription(cfg Config, row func(name, status, detail string), tool func(string, ...string)) {
+	cfgPath := filepath.Join(configDir(), "config.json")
+	if _, err := os.Stat(cfgPath); err == nil {
+		row("config", "ok", cfgPath)
+	} else {
+		row("config", "default", cfgPath+" not found")
+	}
+
+	switch {
+	case cfg.APIKeyFile != "":
+		row("api key", "ok", "api_key_file "+cfg.APIKeyFile)
+	case cfg.APIKeyCmd != "":
+		row("api key", "ok", "api_key_cmd "+cfg.APIKeyCmd)
+	case os.Getenv("OPENAI_API_KEY") != "":
+		row("api key", "ok", "OPENAI_API_KEY")
+	case cfg.Portable:
+		row("api key", "ok", "encrypted key file (portable)")
+	case keyFromKeyring(cfg, "OPENAI_API_KEY") != "":
+		row("api key", "ok", "keyring")
+	default:
+		row("api key", "missing", "set OPENAI_API_KEY or run `dictate set-key`")
+	}
+
+	found, details := detectAccelerators()
+	saveAccelerators(found, details)
+	for _, a := range found {
+		row("accelerator", a, details[a])
+	}
+	if w := cfg.Worker; w != nil {
+		tool("container", w.runtime())
+		row("backend", w.resolvedAccel, fmt.Sprintf("image=%s model=%s compute=%s flags=%s",
+			w.Image, w.Model, w.ComputeType, strings.Join(w.GPUFlags, " ")))
+		if warn := cpuFallbackWarning(cfg); warn != "" {
+			row("accuracy", "warning", warn)
+		}
+		if workerHealthy(w) {
+			row("worker", "ok", w.baseURL())
+		} else {
+			row("worker", "down", w.baseURL()+" (started by `dictate daemon`)")
+		}
+	}
+	for _, p := range providerList(cfg) {
+		status := "ok"
+		if _, err := providerKey(cfg, p); err != nil {
+			status = "no key"
+		}
+		if p.Name == defaultProviderName(cfg) {
+			status += " (default)"
+		}
+		url, err := p.Endpoint()
+		if err != nil {
+			status, url = "bad url", err.Error()
+		}
+		row("provider "+p.Name, status, url+" model="+p.Model)
+	}
+}
+
+// doctorHints suggests what to look at for one subsystem, based on what
+// was found.
+func doctorHints(cfg Config, section string) []string {
+	var hints []string
+	switch section {
+	case checkRecorder:
+		switch {
+		case isMac:
+			hints = append(hints, "sox needs microphone access for the app that launches it (System Settings → Privacy & Security → Microphone)")
+		case isWindows:
+			hints = append(hints, "sox records from the default input device; check Settings → Privacy → Microphone")
+		default:
+			hints = append(hints, "`arecord -l` lists the capture devices ALSA can see; an empty list means no microphone is available",
+				"check that the input is not muted (e.g. in pavucontrol, Input Devices)")
+		}
+		hints = append(hints, "if recordings come out empty, run the recorder by hand ("+strings.Join(recorder.Command("test.wav").Args, " ")+") and look at its errors")
+	case checkTranscription:
+		if _, err := openAIKey(cfg); err != nil && defaultProviderName(cfg) == "openai" {
+			hints = append(hints, "no API key: set OPENAI_API_KEY, api_key_file or api_key_cmd, or run `dictate set-key`; hotkey launchers do not see variables exported in your shell profile")
+		}
+		if w := cfg.Worker; w != nil && !workerHealthy(w) {
+			hints = append(hints, "the worker is not answering; start it with `dictate daemon`")
+		}
+		hints = append(hints, "`dictate retry` lists the failed recordings with their errors and resubmits them")
+	case checkTyping:
+		if !isMac && !isWindows {
+			if os.Getenv("WAYLAND_DISPLAY") != "" {
+				hints = append(hints, "on Wayland, xdotool only reaches XWayland windows; install wl-clipboard and set \"output\": \"clipboard\" if native windows get nothing")
+			}
+			if !pathExists("xdotool") {
+				hints = append(hints, "install xdotool to type and paste")
+			}
+		}
+		hints = append(hints, "`dictate again` re-inserts the last transcript, and `dictate again --copy` puts it on the clipboard")
+	}
+	return hints
+}
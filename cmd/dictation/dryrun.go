@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// With --dry-run a toggle records and transcribes as usual, but prints what
+// it would insert instead of inserting it and keeps the recording. Nothing
+// is saved as the last transcript or in the history, so config, backends
+// and replacement rules can be tried out safely.
+
+// dryRunDir is where dry runs leave their recordings.
+func dryRunDir() string {
+	return filepath.Join(dataDir(), "dry-run")
+}
+
+// dryRun reports what the toggle would do with res. The raw transcript and
+// what decided the output go to stderr, the text itself to stdout.
+func dryRun(cfg Config, wav string, res transcription) error {
+	defer finishWAV(cfg, wav, "")
+	fmt.Fprintf(os.Stderr, "provider: %s, model: %s\n", res.Provider, res.Model)
+	fmt.Fprintf(os.Stderr, "transcript: %s\n", res.Text)
+	if cmd, ok := voiceCommand(res.Text); ok && cfg.VoiceCommands && typesIntoWindow(cfg.Output) {
+		fmt.Println("voice command:", cmd)
+		return nil
+	}
+	text, err := prepareOutput(&cfg, postProcess(cfg, res.Text), res.Language)
+	switch {
+	case errors.Is(err, errTypingDisabled):
+		fmt.Println("not inserted: typing is disabled for this window")
+		return nil
+	case errors.Is(err, errHookDropped):
+		fmt.Println("not inserted: the before_insert hook dropped it")
+		return nil
+	case err != nil:
+		return err
+	}
+	output := cfg.Output
+	if output == "" {
+		output = outputAuto
+	}
+	fmt.Fprintf(os.Stderr, "output: %s\n", output)
+	fmt.Println(text)
+	notifyUser("Dictation (dry run)", text)
+	return nil
+}
+
+// keepDryRunWAV moves a dry run's recording out of the recordings
+// directory, where the next toggle would take it for a new one.
+func keepDryRunWAV(wav string) {
+	dst := filepath.Join(dryRunDir(), fmt.Sprintf("%d_%s", time.Now().Unix(), filepath.Base(wav)))
+	err := os.MkdirAll(dryRunDir(), 0700)
+	if err == nil {
+		err = os.Rename(wav, dst)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not keep wav:", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "recording kept in", dst)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/dictation/insert"
+)
+
+const modeEdit = "edit"
+
+// modeFile marks a recording started by `dictate edit`: when it is
+// stopped, the speech is an instruction for the selected text rather than
+// text to insert.
+func modeFile() string {
+	return filepath.Join(runtimeDir(), "dictation-mode")
+}
+
+// runEdit implements `dictate edit [--fix]`. Like the toggle, a first run
+// starts recording and a second one stops it; what was said ("make this
+// more formal") is applied to the selected text by the LLM and the result
+// replaces the selection. --fix skips the recording and runs the selection
+// through the normal post-processing instead.
+func runEdit(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "run the selection through post-processing (replacements, prompt, casing) without a spoken instruction")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if *fix {
+		sel, err := insert.Selection()
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(sel) == "" {
+			return exitError{exitEmpty, errors.New("nothing selected")}
+		}
+		return replaceSelection(cfg, postProcess(cfg, sel))
+	}
+	return toggleWith(cfg, modeEdit, "")
+}
+
+// editSelection applies a spoken instruction to the selected text.
+func editSelection(cfg Config, instruction string) error {
+	instruction = strings.TrimSpace(instruction)
+	if instruction == "" {
+		return exitError{exitEmpty, errors.New("no instruction recognised")}
+	}
+	sel, err := insert.Selection()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(sel) == "" {
+		return exitError{exitEmpty, errors.New("nothing selected")}
+	}
+	out, err := llmRewrite(cfg, "Edit the text as instructed: "+instruction, sel)
+	if err != nil {
+		return err
+	}
+	return replaceSelection(cfg, out)
+}
+
+// replaceSelection inserts text over the still selected original.
+func replaceSelection(cfg Config, text string) error {
+	if _, err := insertText(cfg, text); err != nil {
+		notifyFailure("Dictation", "Insert failed: "+err.Error())
+		return err
+	}
+	return nil
+}
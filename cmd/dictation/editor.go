@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The "nvim" and "emacs" output modes hand the text to a running editor over
+// its server socket, which inserts it at the cursor: no synthetic
+// keystrokes, so mappings, auto-indent, completion popups and keyboard
+// layouts cannot get in the way, and the editor need not be focused.
+
+// nvimServer is the Neovim to talk to: nvim_server, $NVIM (set in
+// Neovim's terminal buffers), or else the most recently started one with a
+// socket in the runtime directory.
+func nvimServer(cfg Config) (string, error) {
+	if cfg.NvimServer != "" {
+		return expandHome(cfg.NvimServer), nil
+	}
+	if s := os.Getenv("NVIM"); s != "" {
+		return s, nil
+	}
+	// Neovim 0.9 and later listen on $XDG_RUNTIME_DIR/nvim.<pid>.0
+	socks, _ := filepath.Glob(filepath.Join(runtimeDir(), "nvim.*.0"))
+	var newest string
+	var newestTime int64
+	for _, s := range socks {
+		fi, err := os.Stat(s)
+		if err != nil || fi.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+		if t := fi.ModTime().UnixNano(); t > newestTime {
+			newest, newestTime = s, t
+		}
+	}
+	if newest == "" {
+		return "", errors.New("no running Neovim found; set nvim_server to its --listen address")
+	}
+	return newest, nil
+}
+
+// vimString quotes s as a double-quoted Vim string.
+func vimString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// nvimInsert pastes text at the cursor of a running Neovim. nvim_paste
+// works in any mode and is a single undo step, like a paste from a
+// terminal.
+func nvimInsert(cfg Config, text string) error {
+	if !pathExists("nvim") {
+		return errors.New("nvim not found")
+	}
+	server, err := nvimServer(cfg)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("nvim", "--server", server, "--remote-expr", "nvim_paste("+vimString(text)+", v:false, -1)")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("nvim: %s", msg)
+		}
+		return fmt.Errorf("nvim: %v", err)
+	}
+	// nvim_paste returns false when the paste was cancelled
+	if strings.TrimSpace(string(out)) == "v:false" {
+		return errors.New("Neovim refused the paste")
+	}
+	return nil
+}
+
+// elispString quotes s as an Emacs Lisp string.
+func elispString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// emacsInsert inserts text at point in the selected window of the Emacs
+// server (emacs_server names it when it is not the default one).
+func emacsInsert(cfg Config, text string) error {
+	if !pathExists("emacsclient") {
+		return errors.New("emacsclient not found")
+	}
+	var args []string
+	if cfg.EmacsServer != "" {
+		args = append(args, "--socket-name", expandHome(cfg.EmacsServer))
+	}
+	args = append(args, "--eval", "(with-current-buffer (window-buffer (selected-window)) (insert "+elispString(text)+") nil)")
+	cmd := exec.Command("emacsclient", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("emacsclient: %s", msg)
+		}
+		return fmt.Errorf("emacsclient: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/dictation/notify"
+)
+
+// failureStreak counts consecutive failures of one class, so chronic
+// breakage (every insert failing on Wayland) gets a pointer to the
+// relevant doctor output instead of the same error each time.
+type failureStreak struct {
+	Class string `json:"class"`
+	Count int    `json:"count"`
+}
+
+func failuresPath() string {
+	return filepath.Join(stateDir(), "failures.json")
+}
+
+// recordFailure counts a failure of class (a doctor section) and raises the
+// troubleshooting notification when the streak reaches
+// cfg.FailureAlertAfter.
+func recordFailure(cfg Config, class string) {
+	recordMetric(cfg, metric{Kind: metricFailure, Class: class})
+	var st failureStreak
+	unlock, err := lockFile(failuresPath())
+	if err != nil {
+		return
+	}
+	if b, err := os.ReadFile(failuresPath()); err == nil {
+		json.Unmarshal(b, &st)
+	}
+	if st.Class != class {
+		st = failureStreak{Class: class}
+	}
+	st.Count++
+	b, _ := json.Marshal(st)
+	err = writeFileAtomic(failuresPath(), b, 0600)
+	unlock()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not record failure:", err)
+	}
+	if cfg.FailureAlertAfter > 0 && st.Count == cfg.FailureAlertAfter {
+		troubleshootNotify(class, st.Count)
+	}
+}
+
+// recordSuccess ends the current failure streak.
+func recordSuccess() {
+	os.Remove(failuresPath())
+}
+
+// troubleshootNotify tells the user the same thing keeps failing. On
+// Linux, clicking Troubleshoot opens the doctor report for the subsystem;
+// the notification is waited on by a detached `dictate doctor --notify`
+// so the dictation itself is not held up.
+func troubleshootNotify(class string, n int) {
+	if !isMac && !isWindows {
+		if self, err := os.Executable(); err == nil {
+			cmd := exec.Command(self, "doctor", "--notify", fmt.Sprint(n), class)
+			if cmd.Start() == nil {
+				cmd.Process.Release()
+				return
+			}
+		}
+	}
+	notifyFailure("Dictation", troubleshootMessage(class, n))
+}
+
+func troubleshootMessage(class string, n int) string {
+	return fmt.Sprintf("%s failed %d times in a row — run `dictate doctor %s` for what to check", class, n, class)
+}
+
+// waitTroubleshootAction shows the notification with a Troubleshoot action
+// and, if it is clicked, writes the doctor report for class to the state
+// directory and opens it.
+func waitTroubleshootAction(cfg Config, class string, n int) error {
+	action, err := notify.WaitAction(notify.Notification{Summary: "Dictation",
+		Body: fmt.Sprintf("%s failed %d times in a row", class, n),
+		Icon: notify.IconFailure, Urgency: notify.Critical, Timeout: -1,
+		Actions: []string{"doctor", "Troubleshoot"}})
+	if err != nil {
+		notifyFailure("Dictation", troubleshootMessage(class, n))
+		return nil
+	}
+	if action != "doctor" {
+		return nil
+	}
+	var report strings.Builder
+	if err := doctorReport(&report, cfg, class); err != nil {
+		return err
+	}
+	path := filepath.Join(stateDir(), "doctor-"+class+".txt")
+	if err := writeFileAtomic(path, []byte(report.String()), 0600); err != nil {
+		return err
+	}
+	return exec.Command("xdg-open", path).Run()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Retention limits what is kept on disk. Zero values keep everything.
+type Retention struct {
+	// MaxAgeDays prunes archived audio, history entries, quarantined
+	// dictations and batch progress older than this.
+	MaxAgeDays int `json:"max_age_days"`
+	// MaxAudioMB caps the archived audio; the oldest recordings go first.
+	MaxAudioMB int `json:"max_audio_mb"`
+}
+
+func (r Retention) enabled() bool { return r.MaxAgeDays > 0 || r.MaxAudioMB > 0 }
+
+// gcInterval is how often dictations prune automatically.
+const gcInterval = 24 * time.Hour
+
+func gcStampPath() string {
+	return filepath.Join(stateDir(), "gc-stamp")
+}
+
+// autoGC runs the retention policy when it has not run for gcInterval.
+func autoGC(cfg Config) {
+	if !cfg.Retention.enabled() {
+		return
+	}
+	if fi, err := os.Stat(gcStampPath()); err == nil && time.Since(fi.ModTime()) < gcInterval {
+		return
+	}
+	if err := runRetention(cfg, false, func(string, ...any) {}); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: cleanup failed:", err)
+	}
+}
+
+// runGC implements `dictate gc [--dry-run]`: apply the retention policy
+// now and print what is removed.
+func runGC(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only print what would be removed")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if !cfg.Retention.enabled() {
+		return exitError{exitUsage, errors.New(`no retention policy configured (set "retention" in the config)`)}
+	}
+	return runRetention(cfg, *dryRun, func(format string, args ...any) {
+		fmt.Fprintf(os.Stdout, format+"\n", args...)
+	})
+}
+
+// runRetention prunes archived audio, history and batch progress according
+// to cfg.Retention, reporting every removal through logf.
+func runRetention(cfg Config, dryRun bool, logf func(string, ...any)) error {
+	r := cfg.Retention
+	var cutoff time.Time
+	if r.MaxAgeDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -r.MaxAgeDays)
+	}
+	remove := func(path string) {
+		logf("remove %s", path)
+		if dryRun {
+			return
+		}
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+	}
+
+	// archived audio: by age, then oldest first until under the cap
+	type file struct {
+		path string
+		info os.FileInfo
+	}
+	var audio []file
+	var total int64
+	entries, _ := os.ReadDir(audioArchiveDir(cfg))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() {
+			continue
+		}
+		path := filepath.Join(audioArchiveDir(cfg), e.Name())
+		if !cutoff.IsZero() && info.ModTime().Before(cutoff) {
+			remove(path)
+			continue
+		}
+		audio = append(audio, file{path, info})
+		total += info.Size()
+	}
+	if r.MaxAudioMB > 0 {
+		sort.Slice(audio, func(i, j int) bool { return audio[i].info.ModTime().Before(audio[j].info.ModTime()) })
+		for _, f := range audio {
+			if total <= int64(r.MaxAudioMB)<<20 {
+				break
+			}
+			remove(f.path)
+			total -= f.info.Size()
+		}
+	}
+
+	if !cutoff.IsZero() {
+		// batch progress only matters for reruns of recent batches
+		entries, _ = os.ReadDir(resumeDir())
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && info.ModTime().Before(cutoff) {
+				remove(filepath.Join(resumeDir(), e.Name()))
+			}
+		}
+
+		entries, _ = os.ReadDir(quarantineDir())
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && info.ModTime().Before(cutoff) {
+				remove(filepath.Join(quarantineDir(), e.Name()))
+			}
+		}
+
+		old := func(e historyEntry) bool { return e.Time.Before(cutoff) }
+		if dryRun {
+			h, err := loadHistory()
+			if err != nil {
+				return err
+			}
+			for _, e := range h {
+				if old(e) {
+					logf("remove history entry %s (%s)", e.ID, e.Time.Format("2006-01-02"))
+				}
+			}
+		} else {
+			removed, err := pruneHistory(old)
+			if err != nil {
+				return err
+			}
+			for _, id := range removed {
+				logf("remove history entry %s", id)
+			}
+		}
+	}
+	if dryRun {
+		return nil
+	}
+	return writeFileAtomic(gcStampPath(), []byte(time.Now().Format(time.RFC3339)+"\n"), 0600)
+}
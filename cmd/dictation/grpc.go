@@ -0,0 +1,248 @@
+//go:build grpc
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pb "github.com/user/dictation/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// The gRPC control API (api/dictation.proto), served next to the HTTP one
+// when grpc_listen is set. Like the HTTP API, it acts under the daemon's
+// lock, so its recordings take turns with the hotkeys'.
+
+type grpcServer struct {
+	pb.UnimplementedDictationServer
+	d *daemon
+}
+
+// serveGRPC starts the gRPC server and returns the function that stops it.
+func (d *daemon) serveGRPC(addr string) (func(), error) {
+	l, err := listenGRPC(addr)
+	if err != nil {
+		return nil, fmt.Errorf("grpc_listen: %v", err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterDictationServer(s, &grpcServer{d: d})
+	go s.Serve(l)
+	fmt.Fprintln(os.Stderr, "gRPC API listening on", addr)
+	return s.Stop, nil
+}
+
+// listenGRPC listens on "unix:PATH" or on a loopback "host:port": the API
+// types into the focused window, so it is not offered to the network.
+func listenGRPC(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		path = expandHome(os.ExpandEnv(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+		// stale socket from a previous run
+		_ = os.Remove(path)
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			l.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("%s is not a loopback address", host)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// grpcError gives err the status code a client can act on.
+func grpcError(err error) error {
+	var ee exitError
+	switch {
+	case errors.As(err, &ee) && ee.code == exitEmpty:
+		return grpcstatus.Error(codes.NotFound, err.Error())
+	case errors.As(err, &ee) && ee.code == exitUsage:
+		return grpcstatus.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errContinuous):
+		return grpcstatus.Error(codes.FailedPrecondition, err.Error())
+	}
+	return grpcstatus.Error(codes.Internal, err.Error())
+}
+
+func pbStatus(s status) *pb.Status {
+	state := pb.Status_IDLE
+	switch s.State {
+	case stateRecording:
+		state = pb.Status_RECORDING
+	case stateTranscribing:
+		state = pb.Status_TRANSCRIBING
+	}
+	return &pb.Status{State: state, ElapsedMs: s.elapsed().Milliseconds()}
+}
+
+func pbTranscript(t Transcript) *pb.Transcript {
+	return &pb.Transcript{
+		Id:         t.ID,
+		Text:       t.FinalText(),
+		Provider:   t.Provider,
+		Model:      t.Model,
+		TimeUnixMs: t.Time.UnixMilli(),
+	}
+}
+
+func (g *grpcServer) Start(ctx context.Context, req *pb.StartRequest) (*pb.Status, error) {
+	d := g.d
+	if req.Profile != "" {
+		if _, ok := d.cfg.Profiles[req.Profile]; !ok {
+			return nil, grpcstatus.Errorf(codes.InvalidArgument, "unknown profile %q", req.Profile)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.continuous != nil {
+		return nil, grpcError(errContinuous)
+	}
+	if _, recording := recordingSince(); recording {
+		return nil, grpcstatus.Error(codes.FailedPrecondition, "already recording")
+	}
+	mode := ""
+	if req.Translate {
+		mode = modeTranslate
+	}
+	if err := toggleWith(d.cfg, mode, req.Profile); err != nil {
+		return nil, grpcError(err)
+	}
+	return pbStatus(currentStatus()), nil
+}
+
+func (g *grpcServer) Stop(ctx context.Context, req *pb.StopRequest) (*pb.Transcript, error) {
+	d := g.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, recording := recordingSince(); !recording {
+		return nil, grpcstatus.Error(codes.FailedPrecondition, "not recording")
+	}
+	before := lastTranscriptID()
+	defer d.showResult(before)
+	if err := toggle(d.cfg); err != nil {
+		return nil, grpcError(err)
+	}
+	t, err := loadLastTranscript()
+	if err != nil || t.ID == before {
+		// a note, an edit or a voice command
+		return nil, grpcstatus.Error(codes.NotFound, "the recording made no transcript")
+	}
+	return pbTranscript(t), nil
+}
+
+func (g *grpcServer) Cancel(ctx context.Context, req *pb.CancelRequest) (*pb.Status, error) {
+	d := g.d
+	// a Stop that is still transcribing holds the lock
+	if abortInProcess(errCancelled) {
+		return pbStatus(currentStatus()), nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	if d.continuous != nil {
+		err = d.toggleContinuous("")
+	} else {
+		err = cancelRecording()
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return pbStatus(currentStatus()), nil
+}
+
+func (g *grpcServer) GetStatus(ctx context.Context, req *pb.StatusRequest) (*pb.Status, error) {
+	return pbStatus(currentStatus()), nil
+}
+
+func (g *grpcServer) TranscribeFile(ctx context.Context, req *pb.TranscribeFileRequest) (*pb.Transcript, error) {
+	cfg := g.d.cfg
+	if req.Profile != "" {
+		if err := applyProfile(&cfg, req.Profile); err != nil {
+			return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	path := req.GetPath()
+	if audio := req.GetAudio(); audio != nil {
+		name := req.Name
+		if name == "" {
+			name = "audio.wav"
+		}
+		f, err := os.CreateTemp(runtimeDir(), "dictation-grpc-*"+filepath.Ext(name))
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		defer os.Remove(f.Name())
+		_, err = f.Write(audio)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		path = f.Name()
+	}
+	if path == "" {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "no path or audio")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, grpcstatus.Error(codes.NotFound, err.Error())
+	}
+	res, err := transcribeFile(ctx, cfg, path)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Transcript{
+		Text:       postProcess(cfg, res.Text),
+		Provider:   res.Provider,
+		Model:      res.Model,
+		Language:   res.Language,
+		TimeUnixMs: time.Now().UnixMilli(),
+	}, nil
+}
+
+// Watch polls the status and the last transcript, as `dictate status
+// --follow` does.
+func (g *grpcServer) Watch(req *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.Event]) error {
+	lastState, lastID := "", lastTranscriptID()
+	tick := time.NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if s := currentStatus(); s.State != lastState {
+			lastState = s.State
+			if err := stream.Send(&pb.Event{Event: &pb.Event_Status{Status: pbStatus(s)}}); err != nil {
+				return err
+			}
+		}
+		if t, err := loadLastTranscript(); err == nil && t.ID != lastID {
+			lastID = t.ID
+			if err := stream.Send(&pb.Event{Event: &pb.Event_Transcript{Transcript: pbTranscript(t)}}); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
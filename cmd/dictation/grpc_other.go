@@ -0,0 +1,10 @@
+//go:build !grpc
+
+package main
+
+import "errors"
+
+// serveGRPC is only built in with -tags grpc; see grpc.go.
+func (d *daemon) serveGRPC(addr string) (func(), error) {
+	return nil, errors.New("grpc_listen: built without gRPC (build with -tags grpc)")
+}
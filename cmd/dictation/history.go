@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// historyEntry is one line of the transcript history.
+type historyEntry struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"audio_duration,omitempty"`
+	Provider string    `json:"backend,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Text     string    `json:"text"`
+}
+
+func historyPath() string {
+	return filepath.Join(dataDir(), "history.jsonl")
+}
+
+// appendHistory adds e to the append-only history. It is written before
+// the text is inserted, so a paste into the wrong window loses nothing.
+func appendHistory(e historyEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return appendLine(historyPath(), b)
+}
+
+// appendLine adds b as a line to the JSONL file at path, under its lock.
+func appendLine(path string, b []byte) error {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadHistory reads all entries, oldest first. Lines that do not parse
+// (e.g. cut short by a crash) are skipped.
+func loadHistory() ([]historyEntry, error) {
+	f, err := os.Open(historyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []historyEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var e historyEntry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, sc.Err()
+}
+
+// runHistory implements `dictate history [-n N] [--json]`: the most recent
+// transcripts, newest last. `history delete <id>...` and `history export
+// [--json] <id>...` act on single entries.
+func runHistory(args []string) error {
+	if len(args) > 0 && (args[0] == "delete" || args[0] == "export") {
+		sub := flag.NewFlagSet("history "+args[0], flag.ContinueOnError)
+		asJSON := sub.Bool("json", false, "export the entries as JSON lines")
+		if err := sub.Parse(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil
+			}
+			return exitError{exitUsage, err}
+		}
+		if sub.NArg() == 0 {
+			return exitError{exitUsage, fmt.Errorf("usage: dictate history %s <id>...", args[0])}
+		}
+		if args[0] == "delete" {
+			return deleteHistory(sub.Args())
+		}
+		return exportHistory(sub.Args(), *asJSON)
+	}
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	n := fs.Int("n", 20, "number of entries to show (0 for all)")
+	asJSON := fs.Bool("json", false, "print the entries as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	if *n > 0 && len(entries) > *n {
+		entries = entries[len(entries)-*n:]
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "ID\tTIME\tDURATION\tBACKEND\tTEXT")
+	for _, e := range entries {
+		text := strings.Join(strings.Fields(e.Text), " ")
+		if r := []rune(text); len(r) > 80 {
+			text = string(r[:79]) + "…"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.1fs\t%s\t%s\n", e.ID, e.Time.Format("2006-01-02 15:04"), e.Duration, e.Provider, text)
+	}
+	return nil
+}
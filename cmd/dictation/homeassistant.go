@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// The "assist" output mode sends the transcript to Home Assistant's
+// conversation API instead of typing it, so a dictated "turn off the
+// kitchen lights" is carried out by Assist (or whichever conversation
+// agent is picked). The answer is shown as a notification, or read aloud.
+// A profile with "output": "assist" on its own hotkey keeps this apart from
+// ordinary dictation.
+
+// HomeAssistantConfig says which Home Assistant to talk to.
+type HomeAssistantConfig struct {
+	// URL is the instance, e.g. http://homeassistant.local:8123.
+	URL string `json:"url"`
+	// TokenEnv names the environment variable holding a long-lived access
+	// token (HASS_TOKEN by default); TokenCmd is a shell command printing
+	// it instead, as api_key_cmd.
+	TokenEnv string `json:"token_env"`
+	TokenCmd string `json:"token_cmd"`
+	// AgentID picks the conversation agent, Home Assistant's default one
+	// when empty.
+	AgentID string `json:"agent_id"`
+	// Speak reads the answer aloud (see tts) instead of notifying.
+	Speak bool `json:"speak"`
+}
+
+func (h *HomeAssistantConfig) token() (string, error) {
+	if h.TokenCmd != "" {
+		return keyFromConfig("", h.TokenCmd)
+	}
+	env := h.TokenEnv
+	if env == "" {
+		env = "HASS_TOKEN"
+	}
+	if t := os.Getenv(env); t != "" {
+		return t, nil
+	}
+	return "", fmt.Errorf("%s not set; create a long-lived access token in your Home Assistant profile", env)
+}
+
+type conversationResponse struct {
+	Response struct {
+		ResponseType string `json:"response_type"`
+		Speech       struct {
+			Plain struct {
+				Speech string `json:"speech"`
+			} `json:"plain"`
+		} `json:"speech"`
+		Data struct {
+			Code string `json:"code"`
+		} `json:"data"`
+	} `json:"response"`
+}
+
+// assist asks Home Assistant's conversation agent to act on text and
+// returns its answer.
+func assist(cfg Config, text string) (string, error) {
+	h := cfg.HomeAssistant
+	if h == nil || h.URL == "" {
+		return "", errors.New(`the assist output needs "home_assistant" with a "url"`)
+	}
+	token, err := h.token()
+	if err != nil {
+		return "", err
+	}
+	language := cfg.sink.Language
+	if language == "" {
+		language = cfg.Language
+	}
+	body, _ := json.Marshal(struct {
+		Text     string `json:"text"`
+		Language string `json:"language,omitempty"`
+		AgentID  string `json:"agent_id,omitempty"`
+	}{text, language, h.AgentID})
+	req, err := http.NewRequest("POST", strings.TrimSuffix(h.URL, "/")+"/api/conversation/process", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	cli := &http.Client{Timeout: 30 * time.Second}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "", errors.New("Home Assistant rejected the access token")
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("Home Assistant error: %s", strings.TrimSpace(string(b)))
+	}
+	var cr conversationResponse
+	if err := json.Unmarshal(b, &cr); err != nil {
+		return "", fmt.Errorf("Home Assistant: bad response: %v", err)
+	}
+	answer := cr.Response.Speech.Plain.Speech
+	if cr.Response.ResponseType == "error" {
+		if answer == "" {
+			answer = cr.Response.Data.Code
+		}
+		return "", errors.New("Home Assistant: " + answer)
+	}
+	return answer, nil
+}
+
+// assistOutput is the assist output mode: the answer goes to the speaker
+// or a notification.
+func assistOutput(cfg Config, text string) error {
+	answer, err := assist(cfg, text)
+	if err != nil {
+		return err
+	}
+	if answer == "" {
+		return nil
+	}
+	if cfg.HomeAssistant.Speak {
+		if err := speak(cfg, answer); err == nil {
+			return nil
+		}
+	}
+	notifyUser("Home Assistant", answer)
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Hooks are shell commands run around the insertion of every transcript,
+// for filtering, logging or setting off automations. Both get the text on
+// stdin and its context in the environment: $DICTATION_PROFILE,
+// $DICTATION_OUTPUT, $DICTATION_LANGUAGE and, when the window was looked
+// up, $DICTATION_WINDOW_CLASS and $DICTATION_WINDOW_TITLE.
+type Hooks struct {
+	// BeforeInsert prints the text to insert instead, after the app rules
+	// and routes and before the output template. Printing nothing drops
+	// the transcript; a failing hook is skipped.
+	BeforeInsert string `json:"before_insert"`
+	// AfterInsert runs in the background once the text is in place.
+	AfterInsert string `json:"after_insert"`
+	// BeforeUpload processes the recording before it is transcribed: it
+	// reads $DICTATION_AUDIO and writes $DICTATION_AUDIO_OUT, or another
+	// file whose path it prints (for a different format). If it fails,
+	// the recording is sent as it was.
+	BeforeUpload string `json:"before_upload"`
+}
+
+var errHookDropped = errors.New("the before_insert hook dropped the transcript")
+
+// shellCommand runs cmd through the platform's shell.
+func shellCommand(cmd string) *exec.Cmd {
+	if isWindows {
+		return exec.Command("cmd", "/C", cmd)
+	}
+	return exec.Command("sh", "-c", cmd)
+}
+
+// hookCommand prepares a hook with text on its stdin.
+func hookCommand(cfg Config, hook, text string) *exec.Cmd {
+	cmd := shellCommand(hook)
+	cmd.Stdin = strings.NewReader(text)
+	language := cfg.sink.Language
+	if language == "" {
+		language = cfg.Language
+	}
+	cmd.Env = append(os.Environ(),
+		"DICTATION_PROFILE="+cfg.Profile,
+		"DICTATION_OUTPUT="+cfg.Output,
+		"DICTATION_LANGUAGE="+language,
+		"DICTATION_WINDOW_CLASS="+cfg.sink.Window.Class,
+		"DICTATION_WINDOW_TITLE="+cfg.sink.Window.Title,
+	)
+	return cmd
+}
+
+// runBeforeInsert passes text through the before_insert hook.
+func runBeforeInsert(cfg Config, text string) (string, error) {
+	cmd := hookCommand(cfg, cfg.Hooks.BeforeInsert, text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		fmt.Fprintln(os.Stderr, "warning: before_insert hook failed:", err)
+		notifyUser("Dictation", "The before_insert hook failed, inserting the transcript as it was: "+err.Error())
+		return text, nil
+	}
+	// most programs end their output with a newline the user did not say
+	text = strings.TrimSuffix(strings.TrimSuffix(string(out), "\n"), "\r")
+	if strings.TrimSpace(text) == "" {
+		return "", errHookDropped
+	}
+	return text, nil
+}
+
+// runAfterInsert starts the after_insert hook and does not wait for it.
+// The text is handed over in a file rather than a pipe so that the hook
+// still gets it when dictate exits first.
+func runAfterInsert(cfg Config, text string) {
+	cmd := hookCommand(cfg, cfg.Hooks.AfterInsert, "")
+	f, err := os.CreateTemp("", "dictation-hook-*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: after_insert hook:", err)
+		return
+	}
+	defer f.Close()
+	_, err = f.WriteString(text)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		cmd.Stdin = f
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		err = cmd.Start()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		fmt.Fprintln(os.Stderr, "warning: after_insert hook:", err)
+		return
+	}
+	// Unix lets the file go while the hook reads it, Windows only once the
+	// hook has exited
+	removed := os.Remove(f.Name()) == nil
+	go func() {
+		cmd.Wait()
+		if !removed {
+			os.Remove(f.Name())
+		}
+	}()
+}
+
+// runBeforeUpload passes the recording through the before_upload hook and
+// returns the file to send, and a function removing what was created for
+// it. A file the hook printed is its own and is never removed.
+func runBeforeUpload(cfg Config, wavPath string) (string, func(), error) {
+	out, err := os.CreateTemp("", "dictation-upload-*.wav")
+	if err != nil {
+		return "", nil, err
+	}
+	out.Close()
+	cleanup := func() { os.Remove(out.Name()) }
+	cmd := hookCommand(cfg, cfg.Hooks.BeforeUpload, "")
+	cmd.Env = append(cmd.Env, "DICTATION_AUDIO="+wavPath, "DICTATION_AUDIO_OUT="+out.Name())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	printed, err := cmd.Output()
+	if err != nil {
+		cleanup()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", nil, fmt.Errorf("before_upload hook: %v: %s", err, msg)
+		}
+		return "", nil, fmt.Errorf("before_upload hook: %v", err)
+	}
+	// a hook that wrote a file of its own, or wants the input sent as it
+	// is, prints its path last
+	path := out.Name()
+	lines := strings.Split(strings.TrimSpace(string(printed)), "\n")
+	if p := expandHome(strings.TrimSpace(lines[len(lines)-1])); p != "" && p != path {
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() && fi.Size() > 0 {
+			cleanup()
+			return p, func() {}, nil
+		}
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+		cleanup()
+		return "", nil, errors.New("before_upload hook wrote no audio")
+	}
+	return path, cleanup, nil
+}
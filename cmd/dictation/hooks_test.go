@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func beforeUploadConfig(t *testing.T, hook string) (Config, string) {
+	t.Helper()
+	if isWindows {
+		t.Skip("hooks run through sh")
+	}
+	wav := filepath.Join(t.TempDir(), "input.wav")
+	if err := os.WriteFile(wav, []byte("RIFF input"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	cfg.Hooks.BeforeUpload = hook
+	return cfg, wav
+}
+
+func TestBeforeUploadKeepsPrintedInput(t *testing.T) {
+	cfg, wav := beforeUploadConfig(t, `echo "$DICTATION_AUDIO"`)
+	path, cleanup, err := runBeforeUpload(cfg, wav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != wav {
+		t.Errorf("path = %q, want the input %q", path, wav)
+	}
+	cleanup()
+	if _, err := os.Stat(wav); err != nil {
+		t.Errorf("the input is gone after cleanup: %v", err)
+	}
+}
+
+func TestBeforeUploadKeepsPrintedFile(t *testing.T) {
+	own := filepath.Join(t.TempDir(), "kept.wav")
+	cfg, wav := beforeUploadConfig(t, `printf 'RIFF own' > "`+own+`"; echo converting; echo "`+own+`"`)
+	path, cleanup, err := runBeforeUpload(cfg, wav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != own {
+		t.Errorf("path = %q, want %q", path, own)
+	}
+	cleanup()
+	for _, p := range []string{wav, own} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s is gone after cleanup: %v", p, err)
+		}
+	}
+}
+
+func TestBeforeUploadRemovesOutput(t *testing.T) {
+	cfg, wav := beforeUploadConfig(t, `cp "$DICTATION_AUDIO" "$DICTATION_AUDIO_OUT"`)
+	path, cleanup, err := runBeforeUpload(cfg, wav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path == wav {
+		t.Fatal("the hook's output was not used")
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "RIFF input" {
+		t.Errorf("output = %q, %v", b, err)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the output file was not removed: %v", err)
+	}
+	if _, err := os.Stat(wav); err != nil {
+		t.Errorf("the input is gone after cleanup: %v", err)
+	}
+}
+
+func TestBeforeUploadErrors(t *testing.T) {
+	for _, hook := range []string{"true", "echo /nonexistent/file.wav", "echo oops >&2; exit 3"} {
+		cfg, wav := beforeUploadConfig(t, hook)
+		if _, _, err := runBeforeUpload(cfg, wav); err == nil {
+			t.Errorf("%s: no error", hook)
+		}
+		if _, err := os.Stat(wav); err != nil {
+			t.Errorf("%s: the input is gone: %v", hook, err)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With hotkey_listener set, the daemon listens for the hotkeys map itself,
+// so no key binder needs to be set up: through an X11 key grab, or by
+// reading the keyboards' evdev nodes, which works under Wayland too.
+
+// Hotkey listeners.
+const (
+	listenAuto  = "auto"
+	listenX11   = "x11"
+	listenEvdev = "evdev"
+)
+
+// hotkey is a parsed combo: the modifiers, in canonical names, and the
+// key.
+type hotkey struct {
+	mods   map[string]bool
+	key    string
+	action string
+}
+
+// canonicalMod names a modifier the way the listeners do.
+func canonicalMod(m string) (string, error) {
+	switch m {
+	case "super", "win", "mod4", "meta", "logo":
+		return "super", nil
+	case "ctrl", "control":
+		return "ctrl", nil
+	case "alt", "mod1":
+		return "alt", nil
+	case "shift":
+		return "shift", nil
+	}
+	return "", fmt.Errorf("unknown modifier %q", m)
+}
+
+func parseHotkey(combo, action string) (hotkey, error) {
+	mods, key := splitCombo(combo)
+	h := hotkey{mods: map[string]bool{}, key: key, action: action}
+	if key == "" {
+		return h, errors.New("no key")
+	}
+	for _, m := range mods {
+		c, err := canonicalMod(m)
+		if err != nil {
+			return h, err
+		}
+		h.mods[c] = true
+	}
+	return h, nil
+}
+
+func validListener(l string) bool {
+	switch l {
+	case "", listenAuto, listenX11, listenEvdev:
+		return true
+	}
+	return false
+}
+
+// validHotkey checks that combo can be listened for.
+func validHotkey(cfg Config, combo string) error {
+	h, err := parseHotkey(combo, "")
+	if err != nil {
+		return err
+	}
+	switch cfg.HotkeyListener {
+	case listenX11:
+		_, err = keysym(h.key)
+	case listenEvdev:
+		_, err = evdevKey(h.key)
+	}
+	return err
+}
+
+// listenHotkeys runs the listener until the daemon exits; run is how the
+// daemon performs an action.
+func listenHotkeys(cfg Config, run func(action, profile string) error) {
+	var keys []hotkey
+	for combo, action := range cfg.Hotkeys {
+		h, err := parseHotkey(combo, action)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hotkey %s: %v\n", combo, err)
+			continue
+		}
+		keys = append(keys, h)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	listener := cfg.HotkeyListener
+	if listener == listenAuto {
+		listener = listenEvdev
+		if os.Getenv("WAYLAND_DISPLAY") == "" && os.Getenv("DISPLAY") != "" {
+			listener = listenX11
+		}
+	}
+	// actions run one after another, in the order the keys were pressed,
+	// while the listener goes on reading keys
+	queue := make(chan string, 16)
+	go func() {
+		for action := range queue {
+			if err := run(action, ""); err != nil {
+				fmt.Fprintf(os.Stderr, "hotkey %s: %v\n", action, err)
+			}
+		}
+	}()
+	fire := func(h hotkey, pressed bool) {
+		action := h.action
+		switch {
+		case action == actionPushToTalk && pressed:
+			action += pttPress
+		case action == actionPushToTalk:
+			action += pttRelease
+		case !pressed:
+			return
+		}
+		select {
+		case queue <- action:
+		default:
+			fmt.Fprintln(os.Stderr, "hotkey", action, "dropped: too many pending")
+		}
+	}
+	var err error
+	if listener == listenX11 {
+		err = listenX11Keys(keys, fire)
+	} else {
+		err = listenEvdevKeys(keys, fire)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "hotkeys:", err)
+		notifyFailure("Dictation", "Hotkeys are not available: "+err.Error())
+	}
+}
+
+// keysym is the X11 keysym for a key name as written in combos.
+func keysym(key string) (uint32, error) {
+	k := strings.ToLower(key)
+	if len([]rune(k)) == 1 && k[0] >= 0x20 && k[0] < 0x7f {
+		return uint32(k[0]), nil
+	}
+	var n int
+	if _, err := fmt.Sscanf(k, "f%d", &n); err == nil && n >= 1 && n <= 35 {
+		return 0xffbe + uint32(n-1), nil
+	}
+	syms := map[string]uint32{
+		"escape": 0xff1b, "esc": 0xff1b, "return": 0xff0d, "enter": 0xff0d,
+		"tab": 0xff09, "space": 0x20, "backspace": 0xff08, "pause": 0xff13,
+		"scroll_lock": 0xff14, "print": 0xff61, "insert": 0xff63, "delete": 0xffff,
+		"home": 0xff50, "end": 0xff57, "prior": 0xff55, "next": 0xff56,
+		"menu": 0xff67,
+	}
+	if s, ok := syms[k]; ok {
+		return s, nil
+	}
+	return 0, fmt.Errorf("unknown key %q", key)
+}
+
+func listenX11Keys(keys []hotkey, fire func(hotkey, bool)) error {
+	x, err := dialX11()
+	if err != nil {
+		return err
+	}
+	defer x.Close()
+	codes, err := x.keycodes()
+	if err != nil {
+		return err
+	}
+	type grab struct {
+		keycode byte
+		mods    uint16
+	}
+	grabs := map[grab]hotkey{}
+	for _, h := range keys {
+		sym, err := keysym(h.key)
+		if err != nil {
+			return err
+		}
+		kc := codes[sym]
+		if len(kc) == 0 {
+			return fmt.Errorf("no key on this keyboard produces %q", h.key)
+		}
+		var mods uint16
+		for m := range h.mods {
+			mods |= map[string]uint16{"shift": x11Shift, "ctrl": x11Control, "alt": x11Mod1, "super": x11Mod4}[m]
+		}
+		if err := x.grabKey(kc[0], mods); err != nil {
+			return err
+		}
+		grabs[grab{kc[0], mods}] = h
+	}
+
+	events := make(chan x11Event)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			ev, err := x.next()
+			if err != nil {
+				errc <- err
+				return
+			}
+			events <- ev
+		}
+	}()
+	fmt.Fprintln(os.Stderr, "listening for hotkeys (X11)")
+	held := map[byte]hotkey{}
+	var queued []x11Event
+	for {
+		var ev x11Event
+		if len(queued) > 0 {
+			ev, queued = queued[0], queued[1:]
+		} else {
+			select {
+			case ev = <-events:
+			case err := <-errc:
+				return err
+			}
+		}
+		switch ev.code {
+		case x11Error:
+			// 10 is BadAccess: another client holds the grab
+			if ev.detail == 10 {
+				return errors.New("another program already grabs one of the hotkeys")
+			}
+			return fmt.Errorf("X11 error %d", ev.detail)
+		case x11KeyPress:
+			if _, ok := held[ev.detail]; ok {
+				continue
+			}
+			if h, ok := grabs[grab{ev.detail, ev.state &^ (x11Lock | x11Mod2)}]; ok {
+				held[ev.detail] = h
+				fire(h, true)
+			}
+		case x11KeyRelease:
+			// a release followed at once by a press of the same key at the
+			// same time is auto-repeat
+			select {
+			case next := <-events:
+				if next.code == x11KeyPress && next.detail == ev.detail && next.time == ev.time {
+					continue
+				}
+				queued = append(queued, next)
+			case <-time.After(30 * time.Millisecond):
+			case err := <-errc:
+				return err
+			}
+			if h, ok := held[ev.detail]; ok {
+				delete(held, ev.detail)
+				fire(h, false)
+			}
+		}
+	}
+}
+
+// evdev modifier keys.
+var evdevMods = map[uint16]string{
+	29: "ctrl", 97: "ctrl",
+	42: "shift", 54: "shift",
+	56: "alt", 100: "alt",
+	125: "super", 126: "super",
+}
+
+// evdevKey is the evdev code for a key name as written in combos.
+func evdevKey(key string) (uint16, error) {
+	k := strings.ToUpper(key)
+	alias := map[string]string{
+		"ESCAPE": "ESC", "RETURN": "ENTER", "PRIOR": "PAGEUP", "NEXT": "PAGEDOWN",
+		"PRINT": "SYSRQ", "SCROLL_LOCK": "SCROLLLOCK", "MENU": "COMPOSE",
+	}
+	if a, ok := alias[k]; ok {
+		k = a
+	}
+	if c, ok := keyCodes["KEY_"+k]; ok {
+		return c, nil
+	}
+	return 0, fmt.Errorf("unknown key %q", key)
+}
+
+// inputDevices lists the evdev nodes of the given kinds ("kbd",
+// "mouse").
+func inputDevices(kinds ...string) []string {
+	var list []string
+	seen := map[string]bool{}
+	for _, dir := range []string{"/dev/input/by-path", inputByID} {
+		for _, kind := range kinds {
+			names, _ := filepath.Glob(filepath.Join(dir, "*-event-"+kind))
+			for _, n := range names {
+				real, err := filepath.EvalSymlinks(n)
+				if err != nil || seen[real] {
+					continue
+				}
+				seen[real] = true
+				list = append(list, real)
+			}
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// readInputDevices reads key events from every device of the given kinds,
+// picking up ones plugged in later. onKey is called from one goroutine per
+// device. It returns only when no device can be read at the start.
+func readInputDevices(kinds []string, onKey func(code uint16, value int32)) error {
+	var mu sync.Mutex
+	open := map[string]bool{}
+	for started := false; ; time.Sleep(5 * time.Second) {
+		devs := inputDevices(kinds...)
+		if len(devs) == 0 && !started {
+			return fmt.Errorf("no %s devices in /dev/input", strings.Join(kinds, " or "))
+		}
+		for _, dev := range devs {
+			mu.Lock()
+			busy := open[dev]
+			open[dev] = true
+			mu.Unlock()
+			if busy {
+				continue
+			}
+			f, err := os.Open(dev)
+			if err != nil {
+				if errors.Is(err, os.ErrPermission) && !started {
+					return fmt.Errorf("%v (add yourself to the input group)", err)
+				}
+				mu.Lock()
+				delete(open, dev)
+				mu.Unlock()
+				continue
+			}
+			go func() {
+				readKeys(f, onKey)
+				f.Close()
+				mu.Lock()
+				delete(open, dev)
+				mu.Unlock()
+			}()
+		}
+		started = true
+	}
+}
+
+// listenEvdevKeys reads every keyboard, picking up ones plugged in later,
+// and fires a hotkey when its key is pressed with exactly its modifiers
+// held.
+func listenEvdevKeys(keys []hotkey, fire func(hotkey, bool)) error {
+	byCode := map[uint16][]hotkey{}
+	for _, h := range keys {
+		c, err := evdevKey(h.key)
+		if err != nil {
+			return err
+		}
+		byCode[c] = append(byCode[c], h)
+	}
+	var mu sync.Mutex
+	held := map[string]int{} // modifiers held, across keyboards
+	active := map[uint16]hotkey{}
+	onKey := func(code uint16, value int32) {
+		mu.Lock()
+		defer mu.Unlock()
+		if m, ok := evdevMods[code]; ok {
+			switch value {
+			case keyPressed:
+				held[m]++
+			case keyReleased:
+				held[m] = max(held[m]-1, 0)
+			}
+			return
+		}
+		switch value {
+		case keyPressed:
+			for _, h := range byCode[code] {
+				if sameMods(h.mods, held) {
+					active[code] = h
+					fire(h, true)
+					return
+				}
+			}
+		case keyReleased:
+			if h, ok := active[code]; ok {
+				delete(active, code)
+				fire(h, false)
+			}
+		}
+	}
+
+	return readInputDevices([]string{"kbd"}, onKey)
+}
+
+func sameMods(want map[string]bool, held map[string]int) bool {
+	for _, m := range []string{"ctrl", "shift", "alt", "super"} {
+		if want[m] != (held[m] > 0) {
+			return false
+		}
+	}
+	return true
+}
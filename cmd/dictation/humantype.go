@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+	"unicode"
+
+	"github.com/user/dictation/insert"
+)
+
+// HumanTyping sets the pace of the "human" output mode.
+type HumanTyping struct {
+	// WPM is the average speed in words (five characters) per minute,
+	// default 60.
+	WPM int `json:"wpm"`
+}
+
+// humanDelay is the pause before the character after prev: around the average
+// character time with a spread, longer after a word and after a sentence.
+func humanDelay(avg time.Duration, prev rune) time.Duration {
+	d := time.Duration(float64(avg) * (0.5 + rand.Float64()))
+	switch {
+	case prev == '.' || prev == '!' || prev == '?' || prev == '\n':
+		d *= 3
+	case unicode.IsSpace(prev) || unicode.IsPunct(prev):
+		d = d * 3 / 2
+	}
+	// now and then a longer hesitation
+	if rand.IntN(40) == 0 {
+		d += time.Duration(rand.IntN(600)) * time.Millisecond
+	}
+	return d
+}
+
+// humanType types text one character at a time with randomised pauses,
+// for web apps and proctoring tools that reject pasted or instant input.
+func humanType(cfg Config, text string) error {
+	var typeChunk func(string) error
+	switch {
+	case isMac, isWindows:
+		typeChunk = insert.Type
+	default:
+		t, restore, err := xdotoolTyper(cfg)
+		if err != nil {
+			return err
+		}
+		defer restore()
+		typeChunk = t
+	}
+	wpm := cfg.HumanTyping.WPM
+	if wpm <= 0 {
+		wpm = 60
+	}
+	avg := time.Minute / time.Duration(wpm*5)
+	prev := ' '
+	for _, r := range text {
+		start := time.Now()
+		if err := typeChunk(string(r)); err != nil {
+			return err
+		}
+		// the time spent typing counts towards the pause
+		time.Sleep(humanDelay(avg, prev) - time.Since(start))
+		prev = r
+	}
+	return nil
+}
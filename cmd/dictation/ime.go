@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/user/dictation/insert"
+)
+
+// detectInputMethod returns "ibus", "fcitx" or "" depending on which input
+// method framework the session is configured for.
+func detectInputMethod() string {
+	for _, v := range []string{os.Getenv("GTK_IM_MODULE"), os.Getenv("QT_IM_MODULE"), os.Getenv("XMODIFIERS")} {
+		v = strings.ToLower(v)
+		switch {
+		case strings.Contains(v, "ibus"):
+			return "ibus"
+		case strings.Contains(v, "fcitx"):
+			return "fcitx"
+		}
+	}
+	return ""
+}
+
+// hexEntryType types runs of characters for which typeable reports true
+// with xdotool and sends every other character as Ctrl+Shift+U <hex> Space.
+func hexEntryType(text string, typeable func(rune) bool) error {
+	var run strings.Builder
+	flush := func() error {
+		if run.Len() == 0 {
+			return nil
+		}
+		s := run.String()
+		run.Reset()
+		return insert.Xdotool("type", "--clearmodifiers", s)
+	}
+	for _, r := range text {
+		if r == '\n' || r == '\t' || typeable(r) {
+			run.WriteRune(r)
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if err := insert.Xdotool("key", "--clearmodifiers", "ctrl+shift+u"); err != nil {
+			return err
+		}
+		if err := insert.Xdotool("type", strconv.FormatInt(int64(r), 16)); err != nil {
+			return err
+		}
+		if err := insert.Xdotool("key", "space"); err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// layoutRunes returns the characters the current X keymap can produce,
+// read from xmodmap. Keysyms outside Latin-1 and the Unicode keysym range
+// are left out, so those characters take the hex entry path.
+func layoutRunes() (map[rune]bool, error) {
+	out, err := exec.Command("xmodmap", "-pk").Output()
+	if err != nil {
+		return nil, err
+	}
+	runes := map[rune]bool{}
+	for _, m := range keysymRe.FindAllStringSubmatch(string(out), -1) {
+		ks, err := strconv.ParseUint(m[1], 16, 32)
+		if err != nil {
+			continue
+		}
+		switch {
+		case ks >= 0x20 && ks <= 0x7e, ks >= 0xa0 && ks <= 0xff:
+			runes[rune(ks)] = true
+		case ks >= 0x1000100 && ks <= 0x110ffff:
+			runes[rune(ks-0x1000000)] = true
+		}
+	}
+	return runes, nil
+}
+
+var keysymRe = regexp.MustCompile(`0x([0-9a-fA-F]+) \(`)
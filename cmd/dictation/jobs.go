@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Job file states.
+const (
+	jobPending = "pending"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// job is one batch run over several files, kept so failures can be looked
+// at afterwards with `dictate jobs`.
+type job struct {
+	ID       string    `json:"id"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	Files    []jobFile `json:"files"`
+}
+
+type jobFile struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (j job) count(status string) int {
+	n := 0
+	for _, f := range j.Files {
+		if f.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// maxJobs is how many batch runs are remembered.
+const maxJobs = 20
+
+func jobsPath() string {
+	return filepath.Join(stateDir(), "jobs.json")
+}
+
+func loadJobs() ([]job, error) {
+	var jobs []job
+	b, err := os.ReadFile(jobsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// updateJobs applies fn to the job list under the state lock, dropping the
+// oldest jobs beyond maxJobs.
+func updateJobs(fn func([]job) []job) error {
+	unlock, err := lockFile(jobsPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	jobs, err := loadJobs()
+	if err != nil {
+		return err
+	}
+	jobs = fn(jobs)
+	if len(jobs) > maxJobs {
+		jobs = jobs[len(jobs)-maxJobs:]
+	}
+	b, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(jobsPath(), b, 0600)
+}
+
+// startJob records a new batch over paths, all pending.
+func startJob(paths []string) (job, error) {
+	now := time.Now()
+	j := job{ID: strconv.FormatInt(now.UnixNano(), 36), Started: now}
+	for _, p := range paths {
+		j.Files = append(j.Files, jobFile{Path: p, Status: jobPending})
+	}
+	return j, updateJobs(func(jobs []job) []job { return append(jobs, j) })
+}
+
+// saveJob replaces the stored copy of j.
+func saveJob(j job) error {
+	return updateJobs(func(jobs []job) []job {
+		for i := range jobs {
+			if jobs[i].ID == j.ID {
+				jobs[i] = j
+			}
+		}
+		return jobs
+	})
+}
+
+// runJobs implements `dictate jobs [id]`: a table of recent batch runs, or
+// the files of one run with the error of every failed one. The id "last"
+// shows the most recent run.
+func runJobs(args []string) error {
+	jobs, err := loadJobs()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	if len(args) == 0 {
+		fmt.Fprintln(w, "ID\tSTARTED\tDONE\tFAILED\tPENDING\tFILES")
+		for i := len(jobs) - 1; i >= 0; i-- {
+			j := jobs[i]
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", j.ID, j.Started.Format("2006-01-02 15:04:05"),
+				j.count(jobDone), j.count(jobFailed), j.count(jobPending), len(j.Files))
+		}
+		return nil
+	}
+	for i := len(jobs) - 1; i >= 0; i-- {
+		j := jobs[i]
+		if j.ID != args[0] && args[0] != "last" {
+			continue
+		}
+		fmt.Fprintln(w, "STATUS\tFILE\tERROR")
+		for _, f := range j.Files {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Status, f.Path, f.Error)
+		}
+		return nil
+	}
+	return exitError{exitUsage, fmt.Errorf("no job %q", args[0])}
+}
+
+// digest coalesces per-file batch notifications into one summary ("12 files
+// transcribed, 1 failed") at most every interval, instead of a popup per
+// file.
+type digest struct {
+	interval time.Duration
+	mu       sync.Mutex
+	done     int
+	failed   int
+	timer    *time.Timer
+}
+
+func newDigest(interval time.Duration) *digest {
+	return &digest{interval: interval}
+}
+
+// add counts one processed file and schedules a summary if none is
+// pending.
+func (d *digest) add(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.failed++
+	} else {
+		d.done++
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.interval, d.flush)
+	}
+}
+
+// flush sends the pending summary now.
+func (d *digest) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.done == 0 && d.failed == 0 {
+		return
+	}
+	msg := fmt.Sprintf("%d files transcribed", d.done)
+	if d.done == 1 {
+		msg = "1 file transcribed"
+	}
+	if d.failed > 0 {
+		msg += fmt.Sprintf(", %d failed — see `dictate jobs last`", d.failed)
+	}
+	notifyUser("Dictation", msg)
+	d.done, d.failed = 0, 0
+}
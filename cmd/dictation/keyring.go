@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// API keys can live in the system keyring instead of the environment of
+// whatever launches the hotkey: the Secret Service (via secret-tool from
+// libsecret) on Linux, the login keychain on macOS. Keys are stored under
+// the service "dictation", named after the variable they stand in for
+// (OPENAI_API_KEY, or a provider's api_key_env).
+
+const keyringService = "dictation"
+
+var errNoKeyring = errors.New("no keyring available: install secret-tool (libsecret-tools)")
+
+// keyringLookup returns the key stored as name, or "" if there is none.
+func keyringLookup(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch {
+	case isMac:
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", name, "-w")
+	case isWindows:
+		return "", errNoKeyring
+	case pathExists("secret-tool"):
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "key", name)
+	default:
+		return "", errNoKeyring
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		// secret-tool fails silently when nothing matches, security with 44
+		if errors.As(err, &ee) && (isMac && ee.ExitCode() == 44 || !isMac && stderr.Len() == 0) {
+			return "", nil
+		}
+		return "", fmt.Errorf("keyring: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// keyringStore saves key as name, replacing any earlier one.
+func keyringStore(name, key string) error {
+	var cmd *exec.Cmd
+	switch {
+	case isMac:
+		// security -i reads the command from stdin, which keeps the key
+		// out of the argument list that every local user can see in ps
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader("add-generic-password -U -s " + securityQuote(keyringService) +
+			" -a " + securityQuote(name) + " -w " + securityQuote(key) + "\n")
+	case isWindows:
+		return errNoKeyring
+	case pathExists("secret-tool"):
+		cmd = exec.Command("secret-tool", "store", "--label=Dictation "+name, "service", keyringService, "key", name)
+		cmd.Stdin = strings.NewReader(key)
+	default:
+		return errNoKeyring
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("keyring: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if isMac {
+		// security -i reports a failed command only in its output
+		if k, err := keyringLookup(name); err != nil || k != key {
+			return fmt.Errorf("keyring: %s", strings.TrimSpace(strings.ReplaceAll(string(out), "security> ", "")))
+		}
+	}
+	return nil
+}
+
+// securityQuote quotes s as an argument in a `security -i` command line.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// keyFromKeyring returns the key stored as name, or "" when there is none
+// or in portable mode. Keyring failures other than having no keyring at
+// all are reported on stderr.
+func keyFromKeyring(cfg Config, name string) string {
+	if cfg.Portable {
+		return ""
+	}
+	k, err := keyringLookup(name)
+	if err != nil && !errors.Is(err, errNoKeyring) {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+	return k
+}
+
+// runSetKey implements `dictate set-key [provider]`: it asks for the API key
+// of the provider (the active one by default) and stores it in the keyring.
+// A key piped on stdin is stored without asking.
+func runSetKey(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("set-key", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if cfg.Portable {
+		return exitError{exitUsage, errors.New("portable mode keeps its key in the encrypted key file next to the binary, not the keyring")}
+	}
+	name := defaultProviderName(cfg)
+	if fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	p, err := findProvider(cfg, name)
+	if err != nil {
+		return exitError{exitUsage, err}
+	}
+	if p.APIKeyEnv == "" {
+		return exitError{exitUsage, fmt.Errorf("provider %s does not use an API key", p.Name)}
+	}
+
+	var key string
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice == 0 {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		key = strings.TrimSpace(line)
+	} else {
+		key, err = promptInput("Dictation", "API key for "+p.Name+":", true)
+		if err != nil {
+			return err
+		}
+	}
+	if key == "" {
+		return exitError{exitUsage, errors.New("no API key given")}
+	}
+	if err := keyringStore(p.APIKeyEnv, key); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "stored %s in the keyring\n", p.APIKeyEnv)
+	return nil
+}
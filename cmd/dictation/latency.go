@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Latency is tracked in stages, to tell where the delay of a dictation
+// comes from:
+//
+//	stop         from the second press until the upload is done, less the
+//	             round trip: stopping the recorder, checks, encoding
+//	request      the API round trip, for every transcription request
+//	postprocess  replacements, casing, the LLM stage and post_processor
+//	insert       typing or otherwise delivering the text
+//	total        from the second press until the text is in place
+//
+// Each dictation's breakdown is logged, and every process adds it to
+// histograms in latency.json in the state directory, which the daemon
+// (metrics_listen) and `dictate serve` (/metrics) expose to Prometheus
+// together with the per-provider counters of `dictate stats`.
+
+// Latency stages.
+const (
+	latencyStop    = "stop"
+	latencyRequest = "request"
+	latencyPost    = "postprocess"
+	latencyInsert  = "insert"
+	latencyTotal   = "total"
+)
+
+// latencyBuckets are the histogram bounds in seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30}
+
+type latencyHistogram struct {
+	// Counts holds one count per bucket, the last one for slower than the
+	// largest bound; they are not cumulative.
+	Counts []int64   `json:"counts"`
+	Count  int64     `json:"count"`
+	Sum    float64   `json:"sum_seconds"`
+	Bounds []float64 `json:"bounds"`
+}
+
+func latencyPath() string {
+	return filepath.Join(stateDir(), "latency.json")
+}
+
+func loadLatency() (map[string]*latencyHistogram, error) {
+	h := map[string]*latencyHistogram{}
+	b, err := os.ReadFile(latencyPath())
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// observeLatency adds the durations, by stage, to the histograms.
+func observeLatency(stages map[string]time.Duration) {
+	err := func() error {
+		unlock, err := lockFile(latencyPath())
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		hs, err := loadLatency()
+		if err != nil {
+			return err
+		}
+		for stage, d := range stages {
+			h := hs[stage]
+			if h == nil || len(h.Counts) != len(latencyBuckets)+1 {
+				// new, or from a build with other buckets
+				h = &latencyHistogram{Counts: make([]int64, len(latencyBuckets)+1), Bounds: latencyBuckets}
+				hs[stage] = h
+			}
+			i := sort.SearchFloat64s(latencyBuckets, d.Seconds())
+			h.Counts[i]++
+			h.Count++
+			h.Sum += d.Seconds()
+		}
+		b, err := json.MarshalIndent(hs, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(latencyPath(), b, 0600)
+	}()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not record latency:", err)
+	}
+}
+
+// logDictationLatency logs and records the breakdown of one dictation.
+func logDictationLatency(stop, request, post, insert, total time.Duration) {
+	slog.Info("dictation latency",
+		"stop_ms", stop.Milliseconds(),
+		"request_ms", request.Milliseconds(),
+		"postprocess_ms", post.Milliseconds(),
+		"insert_ms", insert.Milliseconds(),
+		"total_ms", total.Milliseconds())
+	observeLatency(map[string]time.Duration{
+		latencyStop:   stop,
+		latencyPost:   post,
+		latencyInsert: insert,
+		latencyTotal:  total,
+	})
+}
+
+// writePrometheus writes the latency histograms and provider counters in
+// the Prometheus text format.
+func writePrometheus(w io.Writer) error {
+	hs, err := loadLatency()
+	if err != nil {
+		return err
+	}
+	stats, err := loadStats()
+	if err != nil {
+		return err
+	}
+	stages := make([]string, 0, len(hs))
+	for s := range hs {
+		stages = append(stages, s)
+	}
+	sort.Strings(stages)
+	fmt.Fprintln(w, "# HELP dictation_latency_seconds Time spent in each stage of a dictation.")
+	fmt.Fprintln(w, "# TYPE dictation_latency_seconds histogram")
+	for _, s := range stages {
+		h := hs[s]
+		var cum int64
+		for i, c := range h.Counts {
+			cum += c
+			le := "+Inf"
+			if i < len(h.Bounds) {
+				le = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "dictation_latency_seconds_bucket{stage=%q,le=%q} %d\n", s, le, cum)
+		}
+		fmt.Fprintf(w, "dictation_latency_seconds_sum{stage=%q} %g\n", s, h.Sum)
+		fmt.Fprintf(w, "dictation_latency_seconds_count{stage=%q} %d\n", s, h.Count)
+	}
+
+	providers := make([]string, 0, len(stats))
+	for p := range stats {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	counters := []struct {
+		name, help string
+		value      func(*providerStats) int64
+	}{
+		{"dictation_requests_total", "Transcription requests.", func(s *providerStats) int64 { return int64(s.Requests) }},
+		{"dictation_request_failures_total", "Transcription requests that failed.", func(s *providerStats) int64 { return int64(s.Failures) }},
+		{"dictation_words_total", "Words transcribed.", func(s *providerStats) int64 { return int64(s.Words) }},
+		{"dictation_corrections_total", "Transcripts corrected afterwards.", func(s *providerStats) int64 { return int64(s.Corrections) }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, p := range providers {
+			fmt.Fprintf(w, "%s{provider=%q} %d\n", c.name, p, c.value(stats[p]))
+		}
+	}
+	recording := 0
+	if currentStatus().State == stateRecording {
+		recording = 1
+	}
+	_, err = fmt.Fprintf(w, "# HELP dictation_recording Whether a recording is in progress.\n# TYPE dictation_recording gauge\ndictation_recording %d\n", recording)
+	return err
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	var b bytes.Buffer
+	if err := writePrometheus(&b); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(b.Bytes())
+}
+
+// serveMetrics serves /metrics on addr for the daemon and returns the
+// function that stops it.
+func serveMetrics(addr string) (func(), error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics_listen: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handleMetrics)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(l)
+	fmt.Fprintln(os.Stderr, "serving metrics on", l.Addr())
+	return func() { srv.Close() }, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/user/dictation/postprocess"
+	"github.com/user/dictation/provider"
+)
+
+// llmProvider is the chat completions endpoint used for post-processing,
+// with OpenAI defaults for whatever "llm" leaves empty.
+func llmProvider(cfg Config) provider.Provider {
+	p := cfg.LLM
+	if p.Name == "" {
+		p.Name = "llm"
+	}
+	return p.WithDefaults(provider.Provider{
+		URL:       postprocess.OpenAIChatURL,
+		Model:     "gpt-4o-mini",
+		APIKeyEnv: "OPENAI_API_KEY",
+	})
+}
+
+// systemPrompt resolves cfg.Prompt: the name of an entry in Prompts, or the
+// prompt text itself. Empty disables the LLM stage.
+func systemPrompt(cfg Config) string {
+	if p, ok := cfg.Prompts[cfg.Prompt]; ok {
+		return p
+	}
+	return cfg.Prompt
+}
+
+// llmRewrite sends the transcript to the "llm" chat model with the
+// configured system prompt and returns the model's answer.
+func llmRewrite(cfg Config, prompt, text string) (string, error) {
+	p := llmProvider(cfg)
+	key, err := providerKey(cfg, p)
+	if err != nil {
+		return "", err
+	}
+	url, err := p.Endpoint()
+	if err != nil {
+		return "", err
+	}
+	return postprocess.Rewrite(postprocess.RewriteRequest{
+		URL:    url,
+		Name:   p.Name,
+		Header: p.Header(key),
+		Model:  p.Model,
+		Prompt: prompt,
+		Text:   text,
+	})
+}
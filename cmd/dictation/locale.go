@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/dictation/postprocess"
+)
+
+// localeDefaults are the settings derived from the environment on first
+// run.
+type localeDefaults struct {
+	Language     string `json:"language,omitempty"`
+	Punctuation  string `json:"punctuation,omitempty"`
+	TypingLayout string `json:"typing_layout,omitempty"`
+}
+
+// systemLanguage returns the ISO 639-1 code of the user's locale
+// (LC_ALL > LC_MESSAGES > LANG), or "" for C/POSIX.
+func systemLanguage() string {
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		l := os.Getenv(v)
+		if l == "" {
+			continue
+		}
+		if l == "C" || l == "POSIX" || strings.HasPrefix(l, "C.") {
+			return ""
+		}
+		l = strings.ToLower(l)
+		if i := strings.IndexAny(l, "_.@-"); i >= 0 {
+			l = l[:i]
+		}
+		if len(l) == 2 {
+			return l
+		}
+		return ""
+	}
+	return ""
+}
+
+// xkbState is the X keyboard configuration as reported by setxkbmap. Layout
+// and Variant are comma-separated lists with one entry per group.
+type xkbState struct {
+	Layout, Variant string
+}
+
+func queryXkb() (xkbState, bool) {
+	var st xkbState
+	if !pathExists("setxkbmap") {
+		return st, false
+	}
+	out, err := exec.Command("setxkbmap", "-query").Output()
+	if err != nil {
+		return st, false
+	}
+	for _, l := range strings.Split(string(out), "\n") {
+		k, v, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "layout":
+			st.Layout = strings.TrimSpace(v)
+		case "variant":
+			st.Variant = strings.TrimSpace(v)
+		}
+	}
+	return st, st.Layout != ""
+}
+
+// group returns layout and variant of the i-th group.
+func (st xkbState) group(i int) (layout, variant string) {
+	layouts := strings.Split(st.Layout, ",")
+	variants := strings.Split(st.Variant, ",")
+	if i < len(variants) {
+		variant = variants[i]
+	}
+	return layouts[i], variant
+}
+
+// activeLayout returns the first configured X keyboard layout, e.g. "de".
+func activeLayout() string {
+	st, _ := queryXkb()
+	return strings.Split(st.Layout, ",")[0]
+}
+
+// nonLatinLayouts cannot type ASCII, so typing needs a switch to "us".
+var nonLatinLayouts = map[string]bool{
+	"ru": true, "ua": true, "by": true, "bg": true, "rs": true, "mk": true,
+	"gr": true, "il": true, "ara": true, "ir": true, "th": true, "kr": true,
+	"jp": true, "cn": true, "in": true, "am": true, "ge": true, "kz": true,
+}
+
+// autoTypingLayout decides what xdotool should type with. A single Latin
+// group is kept: xdotool maps each character through it, so AZERTY, QWERTZ
+// and Dvorak type correctly. With several groups xdotool may pick keys from
+// the wrong one, and non-Latin layouts cannot type ASCII, so typing switches
+// to the first Latin group alone, or to "us" when there is none.
+func autoTypingLayout(st xkbState) (layout, variant string, keep bool) {
+	n := len(strings.Split(st.Layout, ","))
+	for i := 0; i < n; i++ {
+		l, v := st.group(i)
+		if nonLatinLayouts[l] {
+			continue
+		}
+		return l, v, n == 1
+	}
+	return "us", "", false
+}
+
+// detectLocaleDefaults derives language, punctuation style and typing
+// layout from LANG and the active keyboard layout. Anything but a plain
+// "us" layout gets "auto", which keeps Latin layouts (so accented
+// characters type natively) and only switches away from non-Latin ones.
+func detectLocaleDefaults() localeDefaults {
+	d := localeDefaults{Language: systemLanguage()}
+	if d.Language == "fr" {
+		d.Punctuation = postprocess.PunctuationFrench
+	}
+	if st, ok := queryXkb(); ok && st.Layout != "us" {
+		d.TypingLayout = layoutAuto
+	}
+	return d
+}
+
+// writeFirstRunConfig stores the detected defaults as the initial config
+// file so they are visible and editable.
+func writeFirstRunConfig(path string, d localeDefaults) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "created %s with defaults for your locale\n", path)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const errSharingViolation = syscall.Errno(32)
+
+// lockFile takes an exclusive lock on path+".lock", blocking until it is
+// available. Windows has no flock; opening the file without any share mode
+// gives the same guarantee and the lock goes away with the handle, even if
+// the process dies. The returned function releases the lock.
+func lockFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	name, err := syscall.UTF16PtrFromString(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+			syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+		if err == nil {
+			return func() { _ = syscall.CloseHandle(h) }, nil
+		}
+		if err != errSharingViolation {
+			return nil, err
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// tryLockFile is lockFile without the wait: ok is false when someone else
+// holds the lock.
+func tryLockFile(path string) (unlock func(), ok bool, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, err
+	}
+	name, err := syscall.UTF16PtrFromString(path + ".lock")
+	if err != nil {
+		return nil, false, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errSharingViolation {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return func() { _ = syscall.CloseHandle(h) }, true, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Every run logs to dictation.log in the state directory, one JSON object
+// per line, so a failed dictation can be traced after the fact: recorder
+// command lines, API status codes and timings, which insertion backend was
+// used. log_level sets how much ("info" by default; "off" disables the
+// file) and --verbose switches to debug and copies the log to stderr. The
+// packages under the module log at debug level through the default slog
+// logger, which this installs.
+
+const (
+	maxLogSize = 1 << 20
+	// logBackups is how many rotated files (dictation.log.1 ...) are kept.
+	logBackups = 3
+)
+
+// Until setupLogging has run (and with logging off) records go nowhere;
+// slog's own default would print them on stderr.
+func init() {
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+}
+
+func logPath() string {
+	return filepath.Join(stateDir(), "dictation.log")
+}
+
+func parseLogLevel(s string) (slog.Level, bool, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, true, nil
+	case "", "info":
+		return slog.LevelInfo, true, nil
+	case "warn", "warning":
+		return slog.LevelWarn, true, nil
+	case "error":
+		return slog.LevelError, true, nil
+	case "off", "none":
+		return 0, false, nil
+	}
+	return 0, false, fmt.Errorf("invalid log_level %q (debug, info, warn, error or off)", s)
+}
+
+// setupLogging installs the default logger for this run.
+func setupLogging(cfg Config, verbose bool) error {
+	level, toFile, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	var handlers teeHandler
+	if toFile || verbose {
+		if verbose {
+			level = slog.LevelDebug
+		}
+		handlers = append(handlers, slog.NewJSONHandler(&logWriter{path: logPath()}, &slog.HandlerOptions{Level: level}))
+	}
+	if verbose {
+		handlers = append(handlers, slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	if len(handlers) == 0 {
+		return nil
+	}
+	// several processes (the two presses of a toggle, the daemon) share
+	// the file
+	slog.SetDefault(slog.New(handlers).With("process", os.Getpid()))
+	return nil
+}
+
+// logWriter appends to the log file, rotating it when it gets too big.
+// Writes that fail are dropped: logging must not break a dictation.
+type logWriter struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f != nil && w.size+int64(len(p)) > maxLogSize {
+		w.f.Close()
+		w.f = nil
+		w.rotate()
+	}
+	if w.f == nil {
+		if err := os.MkdirAll(filepath.Dir(w.path), 0700); err != nil {
+			return len(p), nil
+		}
+		f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return len(p), nil
+		}
+		w.f = f
+		if fi, err := f.Stat(); err == nil {
+			w.size = fi.Size()
+		}
+	}
+	n, _ := w.f.Write(p)
+	w.size += int64(n)
+	return len(p), nil
+}
+
+// rotate shifts dictation.log to dictation.log.1 and so on, unless another
+// process has done it already.
+func (w *logWriter) rotate() {
+	unlock, err := lockFile(w.path)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	if fi, err := os.Stat(w.path); err != nil || fi.Size() <= maxLogSize {
+		return
+	}
+	for i := logBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	os.Rename(w.path, w.path+".1")
+}
+
+// teeHandler sends every record to each of its handlers.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			h.Handle(ctx, r.Clone())
+		}
+	}
+	return nil
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/user/dictation/insert"
+)
+
+// macOS counterparts of the Linux output modes and dialogs. Recording,
+// sounds, notifications and keystrokes have their macOS side in the
+// recorder, audio, notify and insert packages.
+
+const isMac = runtime.GOOS == "darwin"
+
+// macInsert handles the window-targeting output modes. Typing through
+// System Events mangles non-ASCII text, so everything except "type" goes
+// through the clipboard. It reports whether the text went into the window.
+func macInsert(mode, text string) (bool, error) {
+	switch mode {
+	case outputType:
+		err := insert.Type(text)
+		return err == nil, err
+	case outputClipboard:
+		if err := insert.Copy(text); err != nil {
+			return false, err
+		}
+		notifyUser("Dictation", "Transcribed text copied to clipboard — please paste into target app")
+		return false, nil
+	case outputAuto, outputPaste, "":
+		if err := insert.Copy(text); err != nil {
+			return false, err
+		}
+		if err := insert.Paste(); err != nil {
+			notifyUser("Dictation", "Transcribed text copied to clipboard — please paste into target app")
+			return false, nil
+		}
+		return true, nil
+	}
+	return false, errors.New("unsupported output mode on macOS: " + mode)
+}
+
+// macConfirm shows an OK/Cancel dialog and reports whether OK was chosen.
+func macConfirm(msg string) bool {
+	script := "display dialog " + appleScriptString(msg) +
+		` with title "Dictation" buttons {"Cancel", "Type"} default button "Type"`
+	return exec.Command("osascript", "-e", script).Run() == nil
+}
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,823 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/audio"
+	"github.com/user/dictation/insert"
+	"github.com/user/dictation/postprocess"
+	"github.com/user/dictation/recorder"
+)
+
+func main() {
+	output := flag.String("output", "", "how to deliver the transcript: auto, type, paste, clipboard, stdout, file, human, assist, nvim, emacs or obsidian")
+	review := flag.Bool("review", false, "show the transcript in an editable dialog before inserting it")
+	clipboardOnly := flag.Bool("clipboard-only", false, "always copy the transcript to the clipboard and never type it")
+	outputFile := flag.String("output-file", "", "file to append transcripts to when --output=file")
+	profile := flag.String("profile", "", "name of the config profile to use")
+	casing := flag.String("casing", "", "casing policy: none, sentence, lower, upper or title (overrides the profile)")
+	prompt := flag.String("prompt", "", "LLM post-processing prompt (name from \"prompts\" or text); \"none\" disables it")
+	spoken := flag.String("spoken-punctuation", "", "on or off: turn spoken \"comma\", \"new line\" etc. into punctuation")
+	translate := flag.Bool("translate", false, "translate speech to English instead of transcribing it")
+	dryRun := flag.Bool("dry-run", false, "record and transcribe, but print the result instead of inserting it and keep the recording")
+	verbose := flag.Bool("verbose", false, "log at debug level, to stderr as well as the log file")
+	portable := flag.Bool("portable", false, "keep config, state and recordings next to the binary and store the API key encrypted there")
+	flag.Parse()
+	listenForAbort()
+
+	if *portable {
+		if err := enablePortable(); err != nil {
+			fatal(err)
+		}
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal(err)
+	}
+	cfg.Portable = *portable
+	if err := setupLogging(cfg, *verbose); err != nil {
+		fatal(err)
+	}
+	slog.Debug("started", "args", os.Args[1:])
+	// --profile, else the one picked with `dictate profile`, else the
+	// config file's
+	if *profile == "" {
+		if name := activeProfile(); name != "" {
+			if _, ok := cfg.Profiles[name]; ok {
+				*profile = name
+			} else {
+				fmt.Fprintf(os.Stderr, "warning: active profile %q no longer exists\n", name)
+			}
+		}
+	}
+	if *profile == "" {
+		*profile = cfg.Profile
+	}
+	if err := applyProfile(&cfg, *profile); err != nil {
+		fatal(err)
+	}
+	// flags win over the config file and the profile
+	if *output != "" {
+		cfg.Output = *output
+		cfg.outputFromFlag = true
+	}
+	if *clipboardOnly || cfg.ClipboardOnly {
+		if *output != "" && *output != outputClipboard {
+			fatal(fmt.Errorf("--output %s contradicts clipboard-only mode", *output))
+		}
+		cfg.Output = outputClipboard
+		cfg.outputFromFlag = true
+	}
+	if *review {
+		cfg.Review = true
+	}
+	if *outputFile != "" {
+		cfg.OutputFile = *outputFile
+	}
+	if *casing != "" {
+		cfg.Casing = *casing
+	}
+	if *translate {
+		cfg.Translate = true
+	}
+	cfg.dryRun = *dryRun
+	switch *spoken {
+	case "":
+	case "on", "true":
+		cfg.SpokenPunctuation = true
+	case "off", "false":
+		cfg.SpokenPunctuation = false
+	default:
+		fatal(fmt.Errorf("--spoken-punctuation: want on or off, got %q", *spoken))
+	}
+	if *prompt == "none" {
+		cfg.Prompt = ""
+	} else if *prompt != "" {
+		cfg.Prompt = *prompt
+	}
+	resolveBackend(&cfg)
+	if !validOutput(cfg.Output) {
+		fatal(fmt.Errorf("invalid output mode %q", cfg.Output))
+	}
+	if cfg.MinConfidence < 0 || cfg.MinConfidence >= 1 {
+		fatal(fmt.Errorf("min_confidence must be between 0 and 1, got %g", cfg.MinConfidence))
+	}
+	if !validLowConfidence(cfg.LowConfidence) {
+		fatal(fmt.Errorf("invalid low_confidence %q (review or clipboard)", cfg.LowConfidence))
+	}
+	if !validReviewDialog(cfg.ReviewDialog) {
+		fatal(fmt.Errorf("invalid review_dialog %q (zenity, yad or rofi)", cfg.ReviewDialog))
+	}
+	if !postprocess.ValidCasing(cfg.Casing) {
+		fatal(fmt.Errorf("invalid casing %q", cfg.Casing))
+	}
+	for _, r := range cfg.AppRules {
+		if r.Output != "" && !validOutput(r.Output) {
+			fatal(fmt.Errorf("invalid output mode %q in app rule", r.Output))
+		}
+	}
+	if !validListener(cfg.HotkeyListener) {
+		fatal(fmt.Errorf("invalid hotkey_listener %q (x11, evdev or auto)", cfg.HotkeyListener))
+	}
+	for combo, action := range cfg.Hotkeys {
+		if err := validAction(cfg, action); err != nil {
+			fatal(fmt.Errorf("hotkey %s: %v", combo, err))
+		}
+		if cfg.HotkeyListener != "" {
+			if err := validHotkey(cfg, combo); err != nil {
+				fatal(fmt.Errorf("hotkey %s: %v", combo, err))
+			}
+		}
+	}
+	if cfg.PushToTalk != "" {
+		if _, err := keyCode(cfg.PushToTalk); err != nil {
+			fatal(fmt.Errorf("push_to_talk: %v", err))
+		}
+	}
+	if cfg.WakeWord != nil {
+		if err := validWakeWord(cfg, cfg.WakeWord); err != nil {
+			fatal(fmt.Errorf("wake_word: %v", err))
+		}
+	}
+	if cfg.HomeAssistant != nil && cfg.HomeAssistant.URL == "" {
+		fatal(errors.New(`home_assistant: needs a "url"`))
+	}
+	if cfg.MQTT != nil {
+		if err := validMQTT(cfg.MQTT); err != nil {
+			fatal(fmt.Errorf("mqtt: %v", err))
+		}
+	}
+	if cfg.PostProcessor != "" && !isExec(cfg.PostProcessor) {
+		fatal(fmt.Errorf("post_processor %q: expected exec:/path/to/program", cfg.PostProcessor))
+	}
+	for _, p := range cfg.Pedals {
+		if err := validPedal(cfg, p); err != nil {
+			fatal(fmt.Errorf("pedal %s: %v", p.Device, err))
+		}
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		err = toggle(cfg)
+	case "daemon":
+		err = runDaemon(cfg)
+	case "serve":
+		err = runServe(cfg, flag.Args()[1:])
+	case "doctor":
+		err = runDoctor(cfg, flag.Args()[1:])
+	case "stats":
+		err = runStats(cfg)
+	case "report":
+		err = runReport(cfg, flag.Args()[1:])
+	case "tune":
+		err = runTune(cfg, flag.Args()[1:])
+	case "bench":
+		err = runBench(cfg, flag.Args()[1:])
+	case "transcribe":
+		err = runTranscribe(cfg, flag.Args()[1:])
+	case "jobs":
+		err = runJobs(flag.Args()[1:])
+	case "profile":
+		err = runProfile(cfg, flag.Args()[1:])
+	case "watch":
+		err = runWatch(cfg)
+	case "history":
+		err = runHistory(flag.Args()[1:])
+	case "search":
+		err = runSearch(flag.Args()[1:])
+	case "again":
+		err = runAgain(cfg, flag.Args()[1:])
+	case "edit":
+		err = runEdit(cfg, flag.Args()[1:])
+	case "retranscribe":
+		err = runRetranscribe(cfg, flag.Args()[1:])
+	case "speak-selection":
+		err = runSpeakSelection(cfg)
+	case "gc":
+		err = runGC(cfg, flag.Args()[1:])
+	case "retry":
+		err = runRetry(cfg, flag.Args()[1:])
+	case "set-key":
+		err = runSetKey(cfg, flag.Args()[1:])
+	case "calibrate":
+		err = runCalibrate(flag.Args()[1:])
+	case "cancel":
+		err = cancelRecording()
+	case "action":
+		err = runAction(cfg, flag.Args()[1:])
+	case "hotkeys":
+		err = runHotkeys(cfg, flag.Args()[1:])
+	case "pedal":
+		err = runPedal(flag.Args()[1:])
+	case "start-session":
+		err = runStartSession(flag.Args()[1:])
+	case "end-session":
+		err = runEndSession(cfg, flag.Args()[1:])
+	case "status":
+		err = runStatus(flag.Args()[1:])
+	case "tray":
+		err = runTray(cfg)
+	case "vad-watch":
+		// started by toggle for auto-stop
+		err = runVADWatch(cfg, flag.Args()[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// toggle starts a recording when there is no WAV in the recordings
+// directory, otherwise it stops the recorder, transcribes the newest WAV and
+// inserts the text.
+func toggle(cfg Config) error {
+	// Two quick presses must not both start a recorder or both transcribe
+	// the same wav; the second one is ignored or waits its turn.
+	lock := toggleLockPath()
+	unlock, ok, err := tryLockFile(lock)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		switch {
+		case cfg.WhenBusy == busyAbort && abortTranscription(errInterrupted):
+			// the other toggle keeps its recording for `dictate retry`
+			// and lets go; this press then starts the next one
+		case cfg.WhenBusy == busyQueue:
+			notifyUser("Dictation", "Still busy with the last recording — will continue after it")
+		default:
+			notifyUser("Dictation", "Still busy with the last recording — press ignored")
+			return nil
+		}
+		if unlock, err = lockFile(lock); err != nil {
+			return err
+		}
+	}
+	defer unlock()
+
+	dir := recordingsDir()
+	wavs, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if err != nil {
+		return err
+	}
+	// If no wav exists, start recording into a fixed file and write pidfile
+	recordFile := filepath.Join(dir, "dictation_recording.wav")
+	pidFile := recorderPidPath()
+
+	if len(wavs) == 0 {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		// Start-recording action
+		if err := recorder.Start(recordFile, pidFile); err != nil {
+			notifyFailure("Dictation", "Could not start recorder: "+err.Error())
+			recordFailure(cfg, checkRecorder)
+			return err
+		}
+		// play "on" sound when recording starts
+		playPip(cfg, true)
+		startVADWatch(cfg, recordFile, pidFile)
+		return nil
+	}
+
+	// There is at least one wav. If pidfile exists, stop the recorder first.
+	stopped := time.Now()
+	if _, err := os.Stat(pidFile); err == nil {
+		if err := stopRecording(pidFile); err != nil {
+			notifyFailure("Dictation", "Could not stop recorder: "+err.Error())
+			recordFailure(cfg, checkRecorder)
+			return err
+		}
+		// small pause to ensure the WAV is flushed to disk
+		time.Sleep(300 * time.Millisecond)
+		if isWindows {
+			// the recorder was killed before it could write the sizes
+			if err := audio.RepairWAVHeader(recordFile); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: could not repair wav header:", err)
+			}
+		}
+	}
+
+	// Stop/transcribe action: pick newest wav
+	sort.Slice(wavs, func(i, j int) bool {
+		iInfo, _ := os.Stat(wavs[i])
+		jInfo, _ := os.Stat(wavs[j])
+		return iInfo.ModTime().After(jInfo.ModTime())
+	})
+
+	wav := wavs[0]
+	// play "off" sound when recording stops / before transcribing
+	playPip(cfg, false)
+	defer markBusy()()
+
+	// a recording started by `dictate edit` is an instruction; one started
+	// by a hotkey action may be a translation or a private note, or have
+	// its own profile
+	mode, _ := os.ReadFile(modeFile())
+	os.Remove(modeFile())
+	if p, err := os.ReadFile(recordingProfilePath()); err == nil {
+		os.Remove(recordingProfilePath())
+		if err := applyProfile(&cfg, string(p)); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+	}
+	if string(mode) == modeTranslate {
+		cfg.Translate = true
+	}
+
+	warning, err := checkLevels(wav)
+	if err != nil {
+		playCue(cfg, cueNothing)
+		notifyUser("Dictation", "Nothing heard — "+err.Error())
+		finishWAV(cfg, wav, "")
+		return exitError{exitEmpty, err}
+	}
+	if warning != "" {
+		notifyUser("Dictation", warning)
+	}
+
+	stopTicks := startTicks(cfg)
+	ctx, done := abortable()
+	res, err := transcribeFile(ctx, cfg, wav)
+	aborted := context.Cause(ctx)
+	done()
+	markInserting()
+	stopTicks()
+	uploaded := time.Since(stopped) - res.RequestTime
+	if err != nil && aborted != nil {
+		if errors.Is(aborted, errInterrupted) {
+			playCue(cfg, cueFailed)
+			notifyUser("Dictation", "Transcription interrupted — the recording is kept for `dictate retry`")
+			quarantine(wav, stageTranscribe, string(mode), "", errInterrupted)
+			return nil
+		}
+		notifyUser("Dictation", "Transcription cancelled — recording discarded")
+		finishWAV(cfg, wav, "")
+		return nil
+	}
+	if err != nil {
+		playCue(cfg, cueFailed)
+		notifyFailure("Dictation", "Transcription failed: "+err.Error())
+		quarantine(wav, stageTranscribe, string(mode), "", err)
+		recordFailure(cfg, checkTranscription)
+		return err
+	}
+	if strings.TrimSpace(res.Text) == "" {
+		playCue(cfg, cueNothing)
+		notifyUser("Dictation", "Nothing recognised")
+		finishWAV(cfg, wav, "")
+		return exitError{exitEmpty, errors.New("no speech recognised")}
+	}
+	if cfg.dryRun {
+		return dryRun(cfg, wav, res)
+	}
+	if string(mode) == modeEdit {
+		err := editSelection(cfg, res.Text)
+		if err != nil {
+			notifyUser("Dictation", "Edit failed: "+err.Error())
+		}
+		finishWAV(cfg, wav, "")
+		return err
+	}
+	if string(mode) == modeNote {
+		err := saveNote(cfg, postProcess(cfg, res.Text))
+		if err != nil {
+			notifyUser("Dictation", "Could not save note: "+err.Error())
+		} else {
+			notifyUser("Dictation", "Note saved")
+		}
+		finishWAV(cfg, wav, "")
+		return err
+	}
+	if _, ok := activeSession(); ok {
+		// in a session every dictation goes to its document instead
+		t := keepTranscript(cfg, res, wav)
+		s, err := addToSession(cfg, t.Text)
+		if err != nil {
+			playCue(cfg, cueFailed)
+			notifyFailure("Dictation", "Could not add to the session: "+err.Error())
+			finishWAV(cfg, wav, t.ID)
+			return err
+		}
+		playCue(cfg, cueInserted)
+		notifyUser("Dictation", fmt.Sprintf("Added to the session (%d)", s.Pieces))
+		finishWAV(cfg, wav, t.ID)
+		return nil
+	}
+	if cmd, ok := voiceCommand(res.Text); ok && cfg.VoiceCommands && typesIntoWindow(cfg.Output) {
+		err := runVoiceCommand(cfg, cmd)
+		if err != nil {
+			notifyUser("Dictation", "Voice command failed: "+err.Error())
+		}
+		finishWAV(cfg, wav, "")
+		return err
+	}
+	postStart := time.Now()
+	text := postProcess(cfg, res.Text)
+	postTime := time.Since(postStart)
+	t := newTranscript(text)
+	t.Provider = res.Provider
+	t.Model = res.Model
+	t.Words = res.Words
+	if err := saveLastTranscript(t); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save transcript:", err)
+	}
+	h := historyEntry{ID: t.ID, Time: t.Time, Provider: t.Provider, Model: t.Model, Text: redact(cfg.Redact, text)}
+	if d, err := audio.WAVDuration(wav); err == nil {
+		h.Duration = d
+	}
+	if err := appendHistory(h); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save history:", err)
+	}
+
+	low := ""
+	if typesIntoWindow(cfg.Output) {
+		low = lowConfidence(cfg, res)
+	}
+	if low == lowConfidenceReview {
+		cfg.Review = true
+	}
+	reviewed := false
+	if cfg.Review {
+		edited, ok, err := reviewDictation(cfg, t, text)
+		if err != nil {
+			// nothing can be typed unreviewed, but the text must not be lost
+			if cerr := insert.Copy(text); cerr != nil {
+				notifyFailure("Dictation", "Review failed ("+err.Error()+"), and the transcript could not be copied: "+cerr.Error())
+				quarantine(wav, stageInsert, "", text, err)
+				return err
+			}
+			notifyFailure("Dictation", "Review failed: "+err.Error()+" — transcript copied to clipboard")
+			finishWAV(cfg, wav, t.ID)
+			return err
+		}
+		if !ok {
+			notifyUser("Dictation", "Insertion cancelled — nothing was typed")
+			finishWAV(cfg, wav, t.ID)
+			return nil
+		}
+		text, reviewed = edited, true
+	}
+
+	// a retry of a failed insert goes through the rules again
+	transcript := text
+	text, err = prepareOutput(&cfg, text, res.Language)
+	if errors.Is(err, errTypingDisabled) {
+		notifyUser("Dictation", "Typing is disabled for this window — transcript not inserted")
+		finishWAV(cfg, wav, t.ID)
+		return nil
+	}
+	if errors.Is(err, errHookDropped) {
+		finishWAV(cfg, wav, t.ID)
+		return nil
+	}
+	if err != nil {
+		notifyFailure("Dictation", "Insert failed: "+err.Error())
+		finishWAV(cfg, wav, t.ID)
+		return err
+	}
+
+	if low == lowConfidenceClipboard && typesIntoWindow(cfg.Output) {
+		if err := insert.Copy(text); err != nil {
+			notifyFailure("Dictation", "Insert failed: "+err.Error())
+			finishWAV(cfg, wav, t.ID)
+			return err
+		}
+		notifyUser("Dictation", fmt.Sprintf("Low confidence (%.0f%%) — transcript copied to clipboard instead of typed", res.Confidence*100))
+		finishWAV(cfg, wav, t.ID)
+		return nil
+	}
+
+	// Very long transcripts are hard to undo if they land in the wrong
+	// field, so ask first, unless the user has just reviewed it.
+	if !reviewed && typesIntoWindow(cfg.Output) && cfg.MaxTypeLength > 0 && len([]rune(text)) > cfg.MaxTypeLength {
+		if !confirmInsert(text) {
+			// keep the text around instead of losing it
+			if err := insert.Copy(text); err != nil {
+				notifyFailure("Dictation", "Insertion cancelled, and the transcript could not be copied: "+err.Error())
+				quarantine(wav, stageInsert, "", transcript, err)
+				return err
+			}
+			notifyUser("Dictation", "Insertion cancelled — transcript copied to clipboard")
+			finishWAV(cfg, wav, t.ID)
+			return nil
+		}
+	}
+
+	// Insert text at cursor
+	insertStart := time.Now()
+	typed, err := insertText(cfg, text)
+	if err != nil {
+		playCue(cfg, cueFailed)
+		notifyFailure("Dictation", "Insert failed: "+err.Error())
+		quarantine(wav, stageInsert, "", transcript, err)
+		recordFailure(cfg, checkTyping)
+		return err
+	}
+	playCue(cfg, cueInserted)
+	logDictationLatency(uploaded, res.RequestTime, postTime, time.Since(insertStart), time.Since(stopped))
+	recordSuccess()
+	recordMetric(cfg, metric{Kind: metricDictation, LatencyMS: time.Since(stopped).Milliseconds(), Words: len(strings.Fields(text))})
+	if typed {
+		markInserted(t.ID, text)
+	}
+
+	finishWAV(cfg, wav, t.ID)
+	autoGC(cfg)
+	return nil
+}
+
+// finishWAV gets the processed recording out of the recordings directory so the
+// next invocation sees no wav. With keep_audio it is archived, named after
+// the transcript id; with word timestamps enabled, or result notifications
+// in the daemon, it is (also) kept as the last recording; otherwise it is
+// deleted. A dry run keeps it in dryRunDir.
+func finishWAV(cfg Config, wav, id string) {
+	if cfg.dryRun {
+		keepDryRunWAV(wav)
+		return
+	}
+	if cfg.KeepAudio {
+		if keepLastRecording(cfg) {
+			if b, err := os.ReadFile(wav); err == nil {
+				if err := writeFileAtomic(lastAudioPath(), b, 0600); err != nil {
+					fmt.Fprintln(os.Stderr, "warning: could not keep wav:", err)
+				}
+			}
+		}
+		name := ""
+		if id != "" {
+			name = id + filepath.Ext(wav)
+		}
+		err := moveProcessed(wav, audioArchiveDir(cfg), name)
+		if err == nil {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "warning: could not archive wav:", err)
+	}
+	if keepLastRecording(cfg) {
+		err := keepLastAudio(wav)
+		if err == nil {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "warning: could not keep wav:", err)
+	}
+	if err := os.Remove(wav); err != nil {
+		// deletion is non-fatal; log to stderr only
+		fmt.Fprintln(os.Stderr, "warning: could not delete wav:", err)
+	}
+}
+
+func fatal(err error) {
+	slog.Error("fatal", "err", err)
+	fmt.Fprintln(os.Stderr, err)
+	var ee exitError
+	if errors.As(err, &ee) {
+		os.Exit(ee.code)
+	}
+	os.Exit(exitFailure)
+}
+
+// playPip plays the start (on) or stop sound: the configured file, else
+// the built-in one, else a beep.
+func playPip(cfg Config, on bool) {
+	snd := cfg.Sounds
+	if snd.Volume <= 0 {
+		return
+	}
+	name, builtin := snd.Stop, "off.mp3"
+	if on {
+		name, builtin = snd.Start, "on.mp3"
+	}
+	if p := soundPath(name); p != "" && playSoundFile(snd, p) == nil {
+		return
+	}
+	if name == "" {
+		if p, err := defaultSoundPath(builtin); err == nil && playSoundFile(snd, p) == nil {
+			return
+		}
+	}
+
+	hz, ms := snd.ToneHz, snd.ToneMS
+	if hz <= 0 {
+		hz = 220
+	}
+	if ms <= 0 {
+		ms = 90
+	}
+	if audio.Play(snd.tone(hz, float64(ms)/1000)) == nil {
+		return
+	}
+	// fallback: bell
+	fmt.Print("\a")
+}
+
+// typeText types text into the focused window with xdotool, falling back
+// to the clipboard: pasted with a simulated Ctrl+V on X11, left for the
+// user to paste on Wayland, where native windows take no synthetic keys.
+// It reports whether the text went into the window.
+func typeText(cfg Config, text string) (bool, error) {
+	wayland := os.Getenv("WAYLAND_DISPLAY") != ""
+	if !wayland && os.Getenv("DISPLAY") == "" {
+		return false, errors.New("no X11 DISPLAY found; run under an X11 session or set DISPLAY")
+	}
+
+	err := xdotoolType(cfg, text)
+	if err == nil {
+		slog.Debug("typed", "backend", "xdotool")
+		return true, nil
+	}
+	slog.Info("xdotool typing failed, trying the clipboard", "err", err)
+
+	if err := insert.Copy(text); err != nil {
+		if wayland {
+			return false, errors.New("no Wayland typing tools found; install wl-clipboard (wl-copy) or xdotool")
+		}
+		return false, errors.New("no X11 typing tools found; install xdotool, xclip (or xsel), or wl-clipboard")
+	}
+	if !wayland {
+		err := insert.Paste()
+		if err == nil {
+			slog.Debug("pasted")
+			return true, nil
+		}
+		slog.Info("simulated paste failed", "err", err)
+	}
+	notifyUser("Dictation", "Transcribed text copied to clipboard — please paste into target app")
+	return false, nil
+}
+
+// moveProcessed moves a handled recording into procDir as name (by default
+// its own), prefixed with the time so repeated names do not collide.
+func moveProcessed(path, procDir, name string) error {
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		return err
+	}
+	base := filepath.Base(path)
+	if name != "" {
+		base = name
+	}
+	dst := filepath.Join(procDir, fmt.Sprintf("%d_%s", time.Now().Unix(), base))
+	return os.Rename(path, dst)
+}
+
+// recordingSince reports whether toggle's recorder is running and since
+// when.
+func recordingSince() (time.Time, bool) {
+	fi, err := os.Stat(recorderPidPath())
+	if err != nil {
+		return time.Time{}, false
+	}
+	if !recorder.Running(recorderPidPath()) {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
+
+// stopRecording stops toggle's recorder. One that has died since is only
+// worth a warning.
+func stopRecording(pidFile string) error {
+	err := recorder.Stop(pidFile)
+	if errors.Is(err, recorder.ErrStale) {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+		return nil
+	}
+	return err
+}
+
+// cancelRecording implements `dictate cancel`: it stops the recorder and
+// deletes the recording without transcribing it.
+func cancelRecording() error {
+	// the toggle discards the recording itself
+	if abortTranscription(errCancelled) {
+		return nil
+	}
+	unlock, err := lockFile(toggleLockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := os.Stat(recorderPidPath()); err == nil {
+		if err := stopRecording(recorderPidPath()); err != nil {
+			return err
+		}
+	}
+	wavs, err := filepath.Glob(filepath.Join(recordingsDir(), "*.wav"))
+	if err != nil {
+		return err
+	}
+	os.Remove(modeFile())
+	os.Remove(recordingProfilePath())
+	if len(wavs) == 0 {
+		return exitError{exitEmpty, errors.New("nothing to cancel")}
+	}
+	for _, w := range wavs {
+		if err := os.Remove(w); err != nil {
+			return err
+		}
+	}
+	notifyUser("Dictation", "Recording discarded")
+	return nil
+}
+
+func pathExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// What a toggle does while another one holds the lock (when_busy).
+const (
+	busyIgnore = "ignore"
+	busyQueue  = "queue"
+	busyAbort  = "abort"
+)
+
+// Special typing layouts: layoutKeep leaves the keyboard layout and input
+// method untouched, layoutAuto derives the layout from the active one.
+const (
+	layoutKeep = "keep"
+	layoutAuto = "auto"
+)
+
+// setTypingLayout attempts to temporarily switch the input method/layout to
+// the given layout ("us" when empty) so tools like xdotool type what they
+// are told instead of going through e.g. a CJK input engine. "auto" picks
+// the layout from the user's own configuration, see autoTypingLayout. It
+// returns a restore function (or nil) and an error. The restore function
+// should be called to restore the previous input state.
+func setTypingLayout(layout string) (func(), error) {
+	if layout == layoutKeep {
+		return nil, nil
+	}
+	st, haveXkb := queryXkb()
+	variant := ""
+	switch layout {
+	case "":
+		layout = "us"
+	case layoutAuto:
+		if !haveXkb {
+			return nil, nil
+		}
+		var keep bool
+		layout, variant, keep = autoTypingLayout(st)
+		if keep {
+			return nil, nil
+		}
+	}
+	// Switch with setxkbmap and restore the full layout and variant
+	// lists afterwards, so e.g. "us" with the dvorak variant or a
+	// "fr,ru" pair comes back as it was.
+	var restoreCmd []string
+	if haveXkb {
+		if st.Layout != layout || st.Variant != variant {
+			restoreCmd = []string{"setxkbmap", "-layout", st.Layout, "-variant", st.Variant}
+		}
+		// set the typing layout (best-effort)
+		_ = exec.Command("setxkbmap", "-layout", layout, "-variant", variant).Run()
+	}
+
+	// If ibus is present, try switching engine to 'xkb:us::eng' or a variant
+	// Capture current engine so we can restore it.
+	var restoreIBus string
+	if pathExists("ibus") && layout == "us" {
+		cur, err := exec.Command("ibus", "engine").Output()
+		if err == nil {
+			restoreIBus = strings.TrimSpace(string(cur))
+		}
+		// Try common English engine names
+		// Note: this is best-effort; not all systems will have these engines.
+		engines := []string{"xkb:us::eng", "m17n:en:us", "xkb:us:eng"}
+		for _, e := range engines {
+			if exec.Command("ibus", "engine", e).Run() == nil {
+				// success
+				break
+			}
+		}
+	}
+
+	// If we didn't change anything, return nil restore
+	if restoreCmd == nil && restoreIBus == "" {
+		return nil, nil
+	}
+
+	// build restore function
+	return func() {
+		if len(restoreCmd) > 0 {
+			_ = exec.Command(restoreCmd[0], restoreCmd[1:]...).Run()
+		}
+		if restoreIBus != "" {
+			_ = exec.Command("ibus", "engine", restoreIBus).Run()
+		}
+	}, nil
+}
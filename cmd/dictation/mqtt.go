@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With mqtt set, the daemon publishes the dictation state and every new
+// transcript to an MQTT broker, so home-automation setups can react to
+// them. Under the topic prefix ("dictation" by default):
+//
+//	dictation/state       idle, recording or transcribing (retained)
+//	dictation/transcript  {"id": "...", "time": "...", "text": "...", "provider": "...", "model": "..."}
+//	dictation/available   online, or offline once the daemon is gone (retained)
+//
+// The client is a minimal MQTT 3.1.1 one: QoS 0 publishes over TCP or TLS,
+// a will for the availability topic and keep-alive pings. It subscribes
+// to nothing.
+
+// MQTTConfig configures publishing to a broker.
+type MQTTConfig struct {
+	// Broker is "host[:port]" or a URL: tcp:// or mqtt:// (port 1883 by
+	// default), ssl://, tls:// or mqtts:// for TLS (port 8883).
+	Broker string `json:"broker"`
+	// TopicPrefix goes before every topic, "dictation" by default.
+	TopicPrefix string `json:"topic_prefix"`
+	// ClientID is how the daemon identifies itself to the broker,
+	// dictation-<hostname> by default.
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	// PasswordEnv names the environment variable holding the password;
+	// PasswordCmd is a shell command printing it instead, as api_key_cmd.
+	PasswordEnv string `json:"password_env"`
+	PasswordCmd string `json:"password_cmd"`
+}
+
+// MQTT control packet types, shifted into the fixed header.
+const (
+	mqttConnect    = 1 << 4
+	mqttConnack    = 2 << 4
+	mqttPublish    = 3 << 4
+	mqttPingreq    = 12 << 4
+	mqttDisconnect = 14 << 4
+)
+
+// mqttKeepAlive is the keep-alive announced to the broker; a ping goes out
+// when nothing else was sent for half of it.
+const mqttKeepAlive = 60 * time.Second
+
+// mqttRetry is how long to wait before connecting again after a failure.
+const mqttRetry = 10 * time.Second
+
+func validMQTT(m *MQTTConfig) error {
+	if m.Broker == "" {
+		return errors.New(`needs a "broker"`)
+	}
+	if _, _, err := mqttAddr(m.Broker); err != nil {
+		return err
+	}
+	if strings.ContainsAny(m.TopicPrefix, "+#") {
+		return fmt.Errorf("topic_prefix %q cannot contain the wildcards + and #", m.TopicPrefix)
+	}
+	return nil
+}
+
+// mqttAddr splits a broker setting into a dial address and whether to use
+// TLS.
+func mqttAddr(broker string) (addr string, useTLS bool, err error) {
+	addr, port := broker, "1883"
+	if scheme, rest, ok := strings.Cut(broker, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "tcp", "mqtt":
+		case "ssl", "tls", "mqtts":
+			useTLS, port = true, "8883"
+		default:
+			return "", false, fmt.Errorf("broker %q: unknown scheme %s://", broker, scheme)
+		}
+		addr = strings.TrimSuffix(rest, "/")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+	}
+	return addr, useTLS, nil
+}
+
+func (m *MQTTConfig) topic(name string) string {
+	prefix := strings.TrimSuffix(m.TopicPrefix, "/")
+	if prefix == "" {
+		prefix = "dictation"
+	}
+	return prefix + "/" + name
+}
+
+func (m *MQTTConfig) clientID() string {
+	if m.ClientID != "" {
+		return m.ClientID
+	}
+	host, _ := os.Hostname()
+	return "dictation-" + host
+}
+
+func (m *MQTTConfig) password() (string, error) {
+	if m.PasswordCmd != "" {
+		return keyFromConfig("", m.PasswordCmd)
+	}
+	if m.PasswordEnv != "" {
+		p := os.Getenv(m.PasswordEnv)
+		if p == "" {
+			return "", fmt.Errorf("%s not set", m.PasswordEnv)
+		}
+		return p, nil
+	}
+	return "", nil
+}
+
+type mqttConn struct {
+	c net.Conn
+	// mu serialises writes, which come from the publisher and the pinger.
+	mu   sync.Mutex
+	sent time.Time
+	// done is closed when the broker hung up or a read failed.
+	done chan struct{}
+}
+
+// mqttString appends s with its two-byte length.
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttPacket frames body behind the fixed header.
+func mqttPacket(header byte, body []byte) []byte {
+	p := []byte{header}
+	n := len(body)
+	for {
+		c := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			c |= 0x80
+		}
+		p = append(p, c)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+// dialMQTT connects and logs in, with a will marking the daemon offline.
+func dialMQTT(m *MQTTConfig) (*mqttConn, error) {
+	addr, useTLS, err := mqttAddr(m.Broker)
+	if err != nil {
+		return nil, err
+	}
+	password, err := m.password()
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	var c net.Conn
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		c, err = tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		c, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// clean session, will retained at QoS 0
+	flags := byte(0x02 | 0x04 | 0x20)
+	if m.Username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = mqttString(body, m.clientID())
+	body = mqttString(body, m.topic("available"))
+	body = mqttString(body, "offline")
+	if m.Username != "" {
+		body = mqttString(body, m.Username)
+		if password != "" {
+			body = mqttString(body, password)
+		}
+	}
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.Write(mqttPacket(mqttConnect, body)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	var ack [4]byte
+	if _, err := io.ReadFull(c, ack[:]); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("no answer from the broker: %v", err)
+	}
+	if ack[0] != mqttConnack || ack[1] != 2 {
+		c.Close()
+		return nil, errors.New("the broker does not speak MQTT 3.1.1")
+	}
+	if code := ack[3]; code != 0 {
+		c.Close()
+		reasons := map[byte]string{
+			1: "unsupported protocol version",
+			2: "client id rejected",
+			3: "server unavailable",
+			4: "bad username or password",
+			5: "not authorised",
+		}
+		if r, ok := reasons[code]; ok {
+			return nil, errors.New("the broker refused the connection: " + r)
+		}
+		return nil, fmt.Errorf("the broker refused the connection (code %d)", code)
+	}
+	c.SetDeadline(time.Time{})
+
+	mc := &mqttConn{c: c, sent: time.Now(), done: make(chan struct{})}
+	go mc.drain()
+	return mc, nil
+}
+
+// drain reads what the broker sends, ping responses only since nothing is
+// subscribed to, until the connection goes.
+func (mc *mqttConn) drain() {
+	defer close(mc.done)
+	r := bufio.NewReader(mc.c)
+	for {
+		if _, err := r.ReadByte(); err != nil {
+			return
+		}
+		var n, shift int
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			n |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		if _, err := r.Discard(n); err != nil {
+			return
+		}
+	}
+}
+
+func (mc *mqttConn) write(p []byte) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.c.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := mc.c.Write(p)
+	mc.sent = time.Now()
+	return err
+}
+
+func (mc *mqttConn) publish(topic string, payload []byte, retain bool) error {
+	header := byte(mqttPublish)
+	if retain {
+		header |= 0x01
+	}
+	return mc.write(mqttPacket(header, append(mqttString(nil, topic), payload...)))
+}
+
+// ping keeps an idle connection alive.
+func (mc *mqttConn) ping() error {
+	mc.mu.Lock()
+	idle := time.Since(mc.sent)
+	mc.mu.Unlock()
+	if idle < mqttKeepAlive/2 {
+		return nil
+	}
+	return mc.write(mqttPacket(mqttPingreq, nil))
+}
+
+// disconnect says goodbye, so the broker drops the will.
+func (mc *mqttConn) disconnect() {
+	mc.write(mqttPacket(mqttDisconnect, nil))
+	mc.c.Close()
+}
+
+// mqttTranscript is the payload of the transcript topic.
+type mqttTranscript struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Text     string    `json:"text"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+}
+
+// startMQTT publishes state changes and transcripts in the background,
+// polling them as `dictate status --follow` does, and returns the function
+// that marks the daemon offline and disconnects.
+func startMQTT(m *MQTTConfig) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		var mc *mqttConn
+		var failed bool
+		var retryAt time.Time
+		lastState, lastID := "", lastTranscriptID()
+		tick := time.NewTicker(250 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			if mc == nil && time.Now().After(retryAt) {
+				var err error
+				if mc, err = dialMQTT(m); err != nil {
+					if !failed {
+						fmt.Fprintln(os.Stderr, "warning: mqtt:", err)
+					}
+					failed, retryAt = true, time.Now().Add(mqttRetry)
+				} else {
+					if failed {
+						fmt.Fprintln(os.Stderr, "mqtt: connected to", m.Broker)
+					}
+					failed, lastState = false, ""
+					err = mc.publish(m.topic("available"), []byte("online"), true)
+					if err != nil {
+						mc.c.Close()
+						mc = nil
+					}
+				}
+			}
+			t, terr := loadLastTranscript()
+			if mc != nil {
+				err := mc.ping()
+				if s := currentStatus(); err == nil && s.State != lastState {
+					if err = mc.publish(m.topic("state"), []byte(s.State), true); err == nil {
+						lastState = s.State
+					}
+				}
+				if err == nil && terr == nil && t.ID != lastID {
+					b, _ := json.Marshal(mqttTranscript{ID: t.ID, Time: t.Time, Text: t.Text, Provider: t.Provider, Model: t.Model})
+					err = mc.publish(m.topic("transcript"), b, false)
+				}
+				select {
+				case <-mc.done:
+					err = errors.New("the broker closed the connection")
+				default:
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "warning: mqtt:", err)
+					mc.c.Close()
+					mc, failed, retryAt = nil, true, time.Now().Add(time.Second)
+				}
+			}
+			// transcripts made while the broker is unreachable are not
+			// sent later
+			if terr == nil {
+				lastID = t.ID
+			}
+			select {
+			case <-stop:
+				if mc != nil {
+					mc.publish(m.topic("available"), []byte("offline"), true)
+					mc.disconnect()
+				}
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
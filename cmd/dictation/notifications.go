@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/user/dictation/notify"
+)
+
+// Desktop notifications go through the notify package. Without a
+// notification daemon they are printed on stderr instead, so nothing hangs
+// and nothing is lost.
+
+func notifyUser(title, body string) {
+	notifyUrgency(notify.Normal, title, body)
+}
+
+// notifyFailure is notifyUser for things that went wrong: the daemon shows
+// it as critical, which usually means it stays up until dismissed.
+func notifyFailure(title, body string) {
+	slog.Error(body)
+	notifyUrgency(notify.Critical, title, body)
+}
+
+func notifyUrgency(urgency notify.Urgency, title, body string) {
+	if err := notify.Post(urgency, title, body); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", title, body)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The "obsidian" output mode appends each transcript to a note in an
+// Obsidian vault (or any folder of markdown files), under a heading, as a
+// list item or a task. It is meant for voice-captured notes and TODOs: bind
+// a profile with "output": "obsidian" to its own hotkey.
+
+// ObsidianConfig says where in the vault transcripts go.
+type ObsidianConfig struct {
+	// Vault is the vault's folder.
+	Vault string `json:"vault"`
+	// Note is the note's path inside the vault, "Inbox.md" by default. It
+	// may use strftime directives and template fields like output_file,
+	// e.g. "Daily/%Y-%m-%d.md"; ".md" is added when missing.
+	Note string `json:"note"`
+	// Heading is the line, e.g. "## Voice notes", at the end of whose
+	// section the entry goes; it is added when the note lacks it. Without
+	// one, entries go at the end of the note.
+	Heading string `json:"heading"`
+	// Prefix starts every entry, "- " by default; "- [ ] " makes tasks.
+	Prefix *string `json:"prefix"`
+	// Timestamp goes before the text (strftime, e.g. "%H:%M").
+	Timestamp string `json:"timestamp"`
+	// Frontmatter is written to notes the mode creates. Values may use
+	// strftime directives, e.g. {"created": "%Y-%m-%d", "tags": "voice"}.
+	Frontmatter map[string]string `json:"frontmatter"`
+}
+
+// obsidianEntry formats text as one entry.
+func obsidianEntry(o *ObsidianConfig, text string, now time.Time) string {
+	prefix := "- "
+	if o.Prefix != nil {
+		prefix = *o.Prefix
+	}
+	if o.Timestamp != "" {
+		text = strftime(o.Timestamp, now) + " " + text
+	}
+	if prefix != "" {
+		// further lines continue the list item
+		text = strings.ReplaceAll(text, "\n", "\n  ")
+	}
+	return prefix + text
+}
+
+// headingLevel is the number of #s of a markdown heading, or 0.
+func headingLevel(line string) int {
+	n := len(line) - len(strings.TrimLeft(line, "#"))
+	if n == 0 || n > 6 || (len(line) > n && line[n] != ' ') {
+		return 0
+	}
+	return n
+}
+
+// insertUnderHeading adds entry at the end of heading's section of note,
+// creating the heading when there is none.
+func insertUnderHeading(note, heading, entry string) string {
+	lines := strings.Split(strings.TrimRight(note, "\n"), "\n")
+	if note == "" {
+		lines = nil
+	}
+	start, level := -1, headingLevel(heading)
+	inFence := false
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if start < 0 {
+			if strings.TrimSpace(l) == strings.TrimSpace(heading) {
+				start = i
+			}
+			continue
+		}
+		if n := headingLevel(l); n > 0 && (level == 0 || n <= level) {
+			// back up over the blank lines that end the section
+			end := i
+			for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			out := append(append(append([]string{}, lines[:end]...), entry), lines[end:]...)
+			return strings.Join(out, "\n") + "\n"
+		}
+	}
+	if start < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, heading)
+	}
+	return strings.Join(append(lines, entry), "\n") + "\n"
+}
+
+// obsidianOutput is the obsidian output mode.
+func obsidianOutput(cfg Config, text string) error {
+	o := cfg.Obsidian
+	if o == nil || o.Vault == "" {
+		return errors.New(`the obsidian output needs "obsidian" with a "vault"`)
+	}
+	now := time.Now()
+	note := o.Note
+	if note == "" {
+		note = "Inbox.md"
+	}
+	d := cfg.sink
+	d.Text, d.Time = text, now
+	name, err := expandSink("obsidian note", note, d)
+	if err != nil {
+		return err
+	}
+	name = strftime(name, now)
+	if !strings.HasSuffix(strings.ToLower(name), ".md") {
+		name += ".md"
+	}
+	path := filepath.Join(expandHome(o.Vault), filepath.FromSlash(name))
+
+	unlock, err := lockFile(filepath.Join(runtimeDir(), "dictation-obsidian"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	content := string(b)
+	if errors.Is(err, os.ErrNotExist) && len(o.Frontmatter) > 0 {
+		keys := make([]string, 0, len(o.Frontmatter))
+		for k := range o.Frontmatter {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var fm strings.Builder
+		fm.WriteString("---\n")
+		for _, k := range keys {
+			fm.WriteString(k + ": " + strftime(o.Frontmatter[k], now) + "\n")
+		}
+		fm.WriteString("---\n")
+		content = fm.String()
+	}
+	entry := obsidianEntry(o, text, now)
+	if o.Heading == "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += entry + "\n"
+	} else {
+		content = insertUnderHeading(content, o.Heading, entry)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(content), 0644)
+}
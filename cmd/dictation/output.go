@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/insert"
+)
+
+// Output modes accepted by --output / "output".
+const (
+	outputAuto      = "auto"      // try typing, then clipboard + paste, then clipboard
+	outputType      = "type"      // xdotool type only
+	outputPaste     = "paste"     // copy to clipboard and simulate Ctrl+V
+	outputClipboard = "clipboard" // copy to clipboard and notify
+	outputStdout    = "stdout"    // print to stdout
+	outputFile      = "file"      // append to output_file
+	outputHuman     = "human"     // type slowly with human-like pauses
+	outputAssist    = "assist"    // hand to Home Assistant's conversation agent
+	outputNvim      = "nvim"      // paste into a running Neovim over its socket
+	outputEmacs     = "emacs"     // insert through emacsclient
+	outputObsidian  = "obsidian"  // append under a heading of a vault note
+)
+
+func validOutput(mode string) bool {
+	switch mode {
+	case outputAuto, outputType, outputPaste, outputClipboard, outputStdout, outputFile, outputHuman, outputAssist,
+		outputNvim, outputEmacs, outputObsidian:
+		return true
+	}
+	return false
+}
+
+// typesIntoWindow reports whether mode sends keystrokes to the focused window.
+func typesIntoWindow(mode string) bool {
+	return mode == outputAuto || mode == outputType || mode == outputPaste || mode == outputHuman || mode == ""
+}
+
+// insertText delivers text using the configured output mode, then starts
+// the after_insert hook. It reports whether the text was typed or pasted
+// into the focused window, rather than only copied to the clipboard or sent
+// elsewhere: only then can voice commands take it back.
+func insertText(cfg Config, text string) (bool, error) {
+	typed, err := deliverText(cfg, text)
+	if err != nil {
+		slog.Error("insert failed", "output", cfg.Output, "err", err)
+		return false, err
+	}
+	slog.Info("inserted", "output", cfg.Output, "chars", len([]rune(text)), "typed", typed, "window", cfg.sink.Window.Class)
+	if cfg.Hooks.AfterInsert != "" {
+		runAfterInsert(cfg, text)
+	}
+	return typed, nil
+}
+
+func deliverText(cfg Config, text string) (bool, error) {
+	switch cfg.Output {
+	case outputStdout:
+		_, err := fmt.Fprintln(os.Stdout, text)
+		return false, err
+	case outputFile:
+		return false, appendToFile(cfg, text)
+	case outputHuman:
+		err := humanType(cfg, text)
+		return err == nil, err
+	case outputAssist:
+		return false, assistOutput(cfg, text)
+	case outputNvim:
+		return false, nvimInsert(cfg, text)
+	case outputEmacs:
+		return false, emacsInsert(cfg, text)
+	case outputObsidian:
+		return false, obsidianOutput(cfg, text)
+	}
+	if isMac {
+		return macInsert(cfg.Output, text)
+	}
+	if isWindows {
+		return winInsert(cfg.Output, text)
+	}
+
+	switch cfg.Output {
+	case outputAuto, "":
+		return typeText(cfg, text)
+	case outputType:
+		err := xdotoolType(cfg, text)
+		return err == nil, err
+	case outputPaste:
+		if err := insert.Copy(text); err != nil {
+			return false, err
+		}
+		err := insert.Paste()
+		return err == nil, err
+	case outputClipboard:
+		if err := insert.Copy(text); err != nil {
+			return false, err
+		}
+		notifyUser("Dictation", "Transcribed text copied to clipboard — please paste into target app")
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown output mode %q", cfg.Output)
+}
+
+// xdotoolType types text into the focused window with xdotool, switching to
+// the typing layout for the duration.
+func xdotoolType(cfg Config, text string) error {
+	typeChunk, restore, err := xdotoolTyper(cfg)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	return typeChunk(text)
+}
+
+// xdotoolTyper switches to the typing layout and returns a function that
+// types with xdotool, and one that switches back.
+func xdotoolTyper(cfg Config) (func(string) error, func(), error) {
+	if !pathExists("xdotool") {
+		return nil, nil, errors.New("xdotool not found")
+	}
+	restore, err := setTypingLayout(cfg.TypingLayout)
+	if err != nil || restore == nil {
+		restore = func() {}
+	}
+	// Characters the layout has no key for are sent through IBus's
+	// Unicode hex entry. Without IBus the Ctrl+Shift+U would reach the
+	// application as is, and xdotool type remaps a spare keycode for
+	// missing keysyms itself.
+	if detectInputMethod() != "ibus" {
+		return func(text string) error {
+			return insert.Xdotool("type", "--clearmodifiers", text)
+		}, restore, nil
+	}
+	if runes, err := layoutRunes(); err == nil {
+		return func(text string) error {
+			return hexEntryType(text, func(r rune) bool { return runes[r] })
+		}, restore, nil
+	}
+	return func(text string) error {
+		return insert.Xdotool("type", "--clearmodifiers", text)
+	}, restore, nil
+}
+
+// appendToFile appends text as one timestamped entry to the output file.
+// The file name may start with ~ and contain strftime-style date fields, e.g.
+// "~/notes/%Y-%m-%d.md" for a daily journal.
+func appendToFile(cfg Config, text string) error {
+	if cfg.OutputFile == "" {
+		return errors.New("output mode \"file\" needs output_file to be set")
+	}
+	now := time.Now()
+	d := cfg.sink
+	d.Text, d.Time = text, now
+	name, err := expandSink("output_file", cfg.OutputFile, d)
+	if err != nil {
+		return err
+	}
+	path := expandHome(strftime(name, now))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	entry := text
+	if cfg.OutputTimestamp != "" {
+		entry = strftime(cfg.OutputTimestamp, now) + " " + text
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, path[1:])
+	}
+	return path
+}
+
+// strftime formats t using the common % directives (%Y %m %d %H %M %S %a %b
+// %j %%). Unknown directives are kept as is.
+func strftime(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			b.WriteString(t.Format("2006"))
+		case 'm':
+			b.WriteString(t.Format("01"))
+		case 'd':
+			b.WriteString(t.Format("02"))
+		case 'H':
+			b.WriteString(t.Format("15"))
+		case 'M':
+			b.WriteString(t.Format("04"))
+		case 'S':
+			b.WriteString(t.Format("05"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'b':
+			b.WriteString(t.Format("Jan"))
+		case 'j':
+			b.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Foot pedals and other HID buttons are read from their evdev nodes by the
+// daemon. Holding a push-to-talk button records; letting go transcribes.
+// Other buttons act when pressed, or, with a long_press action, when let go
+// quickly, so that one button can toggle when tapped and cancel when held.
+
+// Pedal is one input device and what its buttons do.
+type Pedal struct {
+	// Device is the device's name under /dev/input/by-id (the ones ending
+	// in -event-*), or a full path.
+	Device string `json:"device"`
+	// Buttons binds key names (BTN_0, KEY_F13, KEY_B) or numeric codes to
+	// actions; without any, every button is push-to-talk.
+	Buttons map[string]string `json:"buttons"`
+	// LongPress binds buttons to what holding them for longPressTime does,
+	// e.g. {"BTN_0": "cancel"} with "buttons": {"BTN_0": "toggle"}.
+	LongPress map[string]string `json:"long_press"`
+	// Profile is applied to recordings started from this device.
+	Profile string `json:"profile"`
+	// Share lets other programs see the presses too. By default the
+	// device is grabbed, so a pedal that pretends to be a keyboard does not
+	// also type.
+	Share bool `json:"share"`
+}
+
+const inputByID = "/dev/input/by-id"
+
+// longPressTime is how long a button with a long_press action has to be
+// held for it to run.
+const longPressTime = 600 * time.Millisecond
+
+// evdev event types and key values.
+const (
+	evKey       = 1
+	keyReleased = 0
+	keyPressed  = 1
+	keyRepeated = 2
+)
+
+// inputEventSize is sizeof(struct input_event): a struct timeval of two
+// longs, then type, code and value.
+var inputEventSize = 2*strconv.IntSize/8 + 8
+
+func (p Pedal) path() string {
+	if strings.ContainsRune(p.Device, '/') {
+		return expandHome(p.Device)
+	}
+	return filepath.Join(inputByID, p.Device)
+}
+
+// keyCodes maps the names of the keys pedals usually send, and those
+// hotkeys usually use, to their evdev codes (linux/input-event-codes.h).
+var keyCodes = func() map[string]uint16 {
+	m := map[string]uint16{
+		"KEY_ESC": 1, "KEY_BACKSPACE": 14, "KEY_TAB": 15, "KEY_ENTER": 28, "KEY_SPACE": 57,
+		"KEY_F11": 87, "KEY_F12": 88, "KEY_SCROLLLOCK": 70, "KEY_SYSRQ": 99, "KEY_PAUSE": 119,
+		"KEY_HOME": 102, "KEY_END": 107, "KEY_INSERT": 110, "KEY_DELETE": 111,
+		"KEY_PAGEUP": 104, "KEY_PAGEDOWN": 109, "KEY_COMPOSE": 127,
+		"KEY_LEFTCTRL": 29, "KEY_RIGHTCTRL": 97, "KEY_LEFTSHIFT": 42, "KEY_RIGHTSHIFT": 54,
+		"KEY_LEFTALT": 56, "KEY_RIGHTALT": 100, "KEY_LEFTMETA": 125, "KEY_RIGHTMETA": 126,
+		"KEY_CAPSLOCK":  58,
+		"KEY_PLAYPAUSE": 164, "KEY_NEXTSONG": 163, "KEY_PREVIOUSSONG": 165,
+		"BTN_LEFT": 0x110, "BTN_RIGHT": 0x111, "BTN_MIDDLE": 0x112, "BTN_SIDE": 0x113, "BTN_EXTRA": 0x114,
+		"BTN_TRIGGER": 0x120, "BTN_THUMB": 0x121, "BTN_THUMB2": 0x122,
+		"BTN_TOP": 0x123, "BTN_TOP2": 0x124, "BTN_PINKIE": 0x125,
+	}
+	for i := 0; i < 10; i++ {
+		m[fmt.Sprintf("BTN_%d", i)] = uint16(0x100 + i)
+		m[fmt.Sprintf("KEY_%d", (i+1)%10)] = uint16(2 + i)
+		m[fmt.Sprintf("KEY_F%d", i+1)] = uint16(59 + i)
+	}
+	for i := 0; i < 12; i++ {
+		m[fmt.Sprintf("KEY_F%d", 13+i)] = uint16(183 + i)
+	}
+	for start, row := range map[uint16]string{16: "QWERTYUIOP", 30: "ASDFGHJKL", 44: "ZXCVBNM"} {
+		for i, c := range row {
+			m["KEY_"+string(c)] = start + uint16(i)
+		}
+	}
+	return m
+}()
+
+// keyCode parses a key name or number.
+func keyCode(name string) (uint16, error) {
+	if c, ok := keyCodes[strings.ToUpper(name)]; ok {
+		return c, nil
+	}
+	if n, err := strconv.ParseUint(name, 0, 16); err == nil {
+		return uint16(n), nil
+	}
+	return 0, fmt.Errorf("unknown key %q (a name like BTN_0 or KEY_F13, or the code `dictate pedal` prints)", name)
+}
+
+// keyName names code for `dictate pedal`.
+func keyName(code uint16) string {
+	var names []string
+	for n, c := range keyCodes {
+		if c == code {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return strconv.Itoa(int(code))
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%s (%d)", names[0], code)
+}
+
+// validPedal checks a pedal's keys, actions and profile.
+func validPedal(cfg Config, p Pedal) error {
+	if p.Device == "" {
+		return errors.New(`no "device"`)
+	}
+	if p.Profile != "" {
+		if _, ok := cfg.Profiles[p.Profile]; !ok {
+			return fmt.Errorf("unknown profile %q", p.Profile)
+		}
+	}
+	for key, action := range p.Buttons {
+		if _, err := keyCode(key); err != nil {
+			return err
+		}
+		if err := validAction(cfg, action); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	for key, action := range p.LongPress {
+		code, err := keyCode(key)
+		if err != nil {
+			return err
+		}
+		if err := validAction(cfg, action); err != nil {
+			return fmt.Errorf("long_press: %s: %v", key, err)
+		}
+		short := ""
+		for k, a := range p.Buttons {
+			if c, _ := keyCode(k); c == code {
+				short = a
+			}
+		}
+		switch {
+		case short == "":
+			return fmt.Errorf("long_press: %s is not in buttons", key)
+		case isPushToTalk(short) || isPushToTalk(action):
+			// a push-to-talk button is always held
+			return fmt.Errorf("long_press: %s: push-to-talk cannot have a long press", key)
+		}
+	}
+	return nil
+}
+
+func isPushToTalk(action string) bool {
+	return strings.HasPrefix(action, actionPushToTalk)
+}
+
+// pedalActions resolves a buttons or long_press map to codes.
+func pedalActions(buttons map[string]string) map[uint16]string {
+	m := make(map[uint16]string, len(buttons))
+	for key, action := range buttons {
+		if c, err := keyCode(key); err == nil {
+			m[c] = action
+		}
+	}
+	return m
+}
+
+// readKeys sends the code and value of each key event read from f.
+func readKeys(f io.Reader, keys func(code uint16, value int32)) error {
+	buf := make([]byte, inputEventSize)
+	off := inputEventSize - 8
+	for {
+		if _, err := io.ReadFull(f, buf); err != nil {
+			return err
+		}
+		if binary.LittleEndian.Uint16(buf[off:]) != evKey {
+			continue
+		}
+		keys(binary.LittleEndian.Uint16(buf[off+2:]), int32(binary.LittleEndian.Uint32(buf[off+4:])))
+	}
+}
+
+// watchPedal runs the pedal's actions for as long as the daemon runs,
+// reopening the device when it is unplugged and plugged back in. run
+// serializes the actions with the daemon's other toggles.
+func watchPedal(p Pedal, run func(action, profile string) error) {
+	actions, long := pedalActions(p.Buttons), pedalActions(p.LongPress)
+	// held has the timers of the long-press buttons that are down
+	held := map[uint16]*time.Timer{}
+	do := func(action string) {
+		if err := run(action, p.Profile); err != nil {
+			fmt.Fprintf(os.Stderr, "pedal %s: %s: %v\n", p.Device, action, err)
+		}
+	}
+	warned := false
+	for {
+		f, err := os.Open(p.path())
+		if err != nil {
+			if !warned {
+				fmt.Fprintf(os.Stderr, "pedal %s: %v (waiting for it)\n", p.Device, err)
+				warned = true
+			}
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		warned = false
+		if !p.Share {
+			if err := grabInput(f); err != nil {
+				fmt.Fprintf(os.Stderr, "pedal %s: could not grab it: %v\n", p.Device, err)
+			}
+		}
+		fmt.Fprintln(os.Stderr, "pedal", p.Device, "ready")
+		err = readKeys(f, func(code uint16, value int32) {
+			if value == keyRepeated {
+				return
+			}
+			action, ok := actions[code]
+			if !ok {
+				if len(actions) > 0 {
+					return
+				}
+				action = actionPushToTalk
+			}
+			if longAction, ok := long[code]; ok {
+				switch t := held[code]; {
+				case value == keyPressed && t == nil:
+					held[code] = time.AfterFunc(longPressTime, func() { do(longAction) })
+				case value == keyReleased && t != nil:
+					delete(held, code)
+					// Stop reports whether the long press has not run
+					if t.Stop() {
+						do(action)
+					}
+				}
+				return
+			}
+			switch {
+			case action == actionPushToTalk && value == keyPressed:
+				action += pttPress
+			case action == actionPushToTalk:
+				action += pttRelease
+			case value != keyPressed:
+				return
+			}
+			do(action)
+		})
+		for code, t := range held {
+			t.Stop()
+			delete(held, code)
+		}
+		f.Close()
+		fmt.Fprintf(os.Stderr, "pedal %s: %v\n", p.Device, err)
+		time.Sleep(time.Second)
+	}
+}
+
+// runPedal implements `dictate pedal [device]`: without a device it lists
+// the input devices; with one it prints the keys pressed on it, to find
+// what to put in "buttons".
+func runPedal(args []string) error {
+	if len(args) > 1 {
+		return exitError{exitUsage, errors.New("usage: dictate pedal [device]")}
+	}
+	if len(args) == 0 {
+		names, err := filepath.Glob(filepath.Join(inputByID, "*-event-*"))
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return exitError{exitEmpty, fmt.Errorf("no input devices in %s", inputByID)}
+		}
+		for _, n := range names {
+			fmt.Println(filepath.Base(n))
+		}
+		return nil
+	}
+	p := Pedal{Device: args[0], Share: true}
+	f, err := os.Open(p.path())
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%v (add yourself to the input group or give the device a udev rule)", err)
+		}
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintln(os.Stderr, "press the buttons; Ctrl+C to stop")
+	return readKeys(f, func(code uint16, value int32) {
+		switch value {
+		case keyPressed:
+			fmt.Println(keyName(code), "pressed")
+		case keyReleased:
+			fmt.Println(keyName(code), "released")
+		}
+	})
+}
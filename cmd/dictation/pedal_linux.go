@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// evIOCGrab is EVIOCGRAB, _IOW('E', 0x90, int).
+const evIOCGrab = 0x40044590
+
+// grabInput takes the device for this process alone; it is let go when f
+// is closed.
+func grabInput(f *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), evIOCGrab, 1); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func grabInput(f *os.File) error {
+	return errors.New("evdev devices exist only on Linux")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/provider"
+	"github.com/user/dictation/transcribe"
+)
+
+// Exec plugins are programs that transcribe or post-process on dictation's
+// behalf, configured as "exec:/path/to/program [args]": as a provider's
+// url (or directly as "provider"), or as "post_processor". Each call runs
+// the program once, writes one JSON request on its stdin and reads one
+// JSON response from its stdout:
+//
+//	{"type": "transcribe", "audio": "/tmp/rec.wav", "model": "whisper-1",
+//	 "language": "en", "prompt": "", "translate": false, "word_timestamps": false}
+//	-> {"text": "...", "language": "en", "duration": 3.2, "words": [...], "segments": [...]}
+//
+//	{"type": "post_process", "text": "...", "language": "en", "profile": "code"}
+//	-> {"text": "..."}
+//
+// A response with "error", or a non-zero exit, fails the call; what the
+// program printed on stderr goes into the message. A provider's API key,
+// when it has one, is in $DICTATION_API_KEY.
+
+const execPrefix = "exec:"
+
+// Request types of the exec protocol.
+const (
+	pluginTranscribe  = "transcribe"
+	pluginPostProcess = "post_process"
+)
+
+// pluginTimeout bounds one call of a post-processing plugin; transcription
+// plugins get the provider's request timeout.
+const pluginTimeout = 120 * time.Second
+
+type pluginRequest struct {
+	Type           string `json:"type"`
+	Audio          string `json:"audio,omitempty"`
+	Text           string `json:"text,omitempty"`
+	Model          string `json:"model,omitempty"`
+	Language       string `json:"language,omitempty"`
+	Prompt         string `json:"prompt,omitempty"`
+	Translate      bool   `json:"translate,omitempty"`
+	WordTimestamps bool   `json:"word_timestamps,omitempty"`
+	Profile        string `json:"profile,omitempty"`
+}
+
+type pluginResponse struct {
+	Text     string    `json:"text"`
+	Language string    `json:"language"`
+	Duration float64   `json:"duration"`
+	Words    []Word    `json:"words"`
+	Segments []Segment `json:"segments"`
+	Error    string    `json:"error"`
+}
+
+// isExec reports whether spec names an exec plugin.
+func isExec(spec string) bool {
+	return strings.HasPrefix(spec, execPrefix)
+}
+
+// runPlugin sends req to the plugin spec and returns its response. The
+// program is killed when ctx is cancelled or after timeout.
+func runPlugin(ctx context.Context, spec string, timeout time.Duration, req pluginRequest, env ...string) (pluginResponse, error) {
+	var resp pluginResponse
+	args := strings.Fields(strings.TrimPrefix(spec, execPrefix))
+	if len(args) == 0 {
+		return resp, fmt.Errorf("%q names no program", spec)
+	}
+	args[0] = expandHome(args[0])
+	in, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(append(in, '\n'))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	name := filepath.Base(args[0])
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return resp, fmt.Errorf("%s: no answer within %v", name, timeout)
+		}
+		if ctx.Err() != nil {
+			return resp, fmt.Errorf("%s: %w", name, ctx.Err())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return resp, fmt.Errorf("%s: %v: %s", name, err, msg)
+		}
+		return resp, fmt.Errorf("%s: %v", name, err)
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return resp, fmt.Errorf("%s: bad response: %v", name, err)
+	}
+	if resp.Error != "" {
+		return resp, errors.New(name + ": " + resp.Error)
+	}
+	return resp, nil
+}
+
+// execTranscribe transcribes with a provider whose url is an exec plugin.
+func execTranscribe(ctx context.Context, cfg Config, p provider.Provider, wavPath, apiKey string) (transcription, error) {
+	res := transcription{Provider: p.Name, Model: p.Model}
+	abs, err := filepath.Abs(wavPath)
+	if err != nil {
+		return res, err
+	}
+	req := pluginRequest{
+		Type:           pluginTranscribe,
+		Audio:          abs,
+		Model:          p.Model,
+		Prompt:         cfg.TranscriptionPrompt,
+		Translate:      cfg.Translate,
+		WordTimestamps: cfg.WordTimestamps,
+	}
+	if !cfg.Translate {
+		req.Language = cfg.Language
+	}
+	var env []string
+	if apiKey != "" {
+		env = append(env, "DICTATION_API_KEY="+apiKey)
+	}
+	start := time.Now()
+	resp, err := runPlugin(ctx, p.URL, requestTimeout(cfg, p), req, env...)
+	res.RequestTime = time.Since(start)
+	if err != nil {
+		return res, err
+	}
+	res.Result = transcribe.Result{
+		Text:        strings.TrimSpace(resp.Text),
+		Words:       resp.Words,
+		Segments:    resp.Segments,
+		Language:    resp.Language,
+		Duration:    resp.Duration,
+		Confidence:  transcribe.SegmentConfidence(resp.Segments),
+		RequestTime: res.RequestTime,
+	}
+	return res, nil
+}
+
+// execPostProcess runs the transcript through the post_processor plugin.
+func execPostProcess(cfg Config, text string) (string, error) {
+	resp, err := runPlugin(context.Background(), cfg.PostProcessor, pluginTimeout, pluginRequest{
+		Type:     pluginPostProcess,
+		Text:     text,
+		Language: cfg.Language,
+		Profile:  cfg.Profile,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
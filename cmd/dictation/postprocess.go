@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/user/dictation/postprocess"
+)
+
+// postProcess runs the transcript through the configured clean-up stages.
+// Casing is always applied last so it sees the final text. A failing LLM
+// stage is reported (on stderr only when cfg.quiet) and skipped rather than
+// losing the transcript.
+func postProcess(cfg Config, text string) string {
+	text = strings.TrimSpace(text)
+	if cfg.ReplacementsFile != "" {
+		rules, err := postprocess.LoadReplacements(expandHome(cfg.ReplacementsFile))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: replacements not applied:", err)
+		}
+		text = postprocess.ApplyReplacements(rules, text)
+	}
+	switch {
+	case cfg.CodeMode:
+		text = postprocess.ApplyCodeMode(text)
+	case cfg.SpokenPunctuation:
+		text = postprocess.ApplySpokenPunctuation(text)
+	}
+	if prompt := systemPrompt(cfg); prompt != "" && text != "" {
+		out, err := llmRewrite(cfg, prompt, text)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: llm post-processing failed:", err)
+			if !cfg.quiet {
+				notifyUser("Dictation", "Post-processing failed, using the raw transcript: "+err.Error())
+			}
+		} else {
+			text = out
+		}
+	}
+	if cfg.PostProcessor != "" && text != "" {
+		out, err := execPostProcess(cfg, text)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: post_processor failed:", err)
+			if !cfg.quiet {
+				notifyUser("Dictation", "Post-processing failed, using the transcript as it was: "+err.Error())
+			}
+		} else {
+			text = out
+		}
+	}
+	if !cfg.CodeMode {
+		text = postprocess.ApplyPunctuation(cfg.Punctuation, text)
+	}
+	text = postprocess.ApplyCasing(cfg.Casing, text)
+	return text
+}
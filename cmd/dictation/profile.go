@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// profileNone as a profile choice clears the active profile.
+const profileNone = "none"
+
+// activeProfilePath holds the profile chosen with `dictate profile`, so the
+// hotkey (which runs dictate without flags) picks it up.
+func activeProfilePath() string {
+	return filepath.Join(stateDir(), "profile")
+}
+
+// activeProfile returns the profile chosen with `dictate profile`, or "".
+func activeProfile() string {
+	b, err := os.ReadFile(activeProfilePath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func setActiveProfile(name string) error {
+	if name == "" || name == profileNone {
+		err := os.Remove(activeProfilePath())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return writeFileAtomic(activeProfilePath(), []byte(name+"\n"), 0600)
+}
+
+// runProfile implements `dictate profile [name|none]`: it sets the active
+// profile, or shows a picker when no name is given.
+func runProfile(cfg Config, args []string) error {
+	names := make([]string, 0, len(cfg.Profiles))
+	for n := range cfg.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return errors.New("no profiles configured")
+	}
+
+	var name string
+	switch len(args) {
+	case 0:
+		choice, err := promptChoice("Dictation profile", "Profile for the next dictations:", append(names, profileNone))
+		if err != nil {
+			return err
+		}
+		name = choice
+	case 1:
+		name = args[0]
+	default:
+		return exitError{exitUsage, errors.New("usage: dictate profile [name|none]")}
+	}
+	if _, ok := cfg.Profiles[name]; !ok && name != profileNone {
+		return exitError{exitUsage, fmt.Errorf("unknown profile %q (have %s)", name, strings.Join(names, ", "))}
+	}
+	if err := setActiveProfile(name); err != nil {
+		return err
+	}
+	if name == profileNone {
+		notifyUser("Dictation", "Profile cleared")
+	} else {
+		notifyUser("Dictation", "Profile: "+name)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var errNoPrompt = errors.New("no way to ask for input: install zenity or run from a terminal")
+
+// promptInput asks the user for a line of text, hiding it when secret is
+// set. It uses a desktop dialog when one is available (hotkey launches have
+// no terminal) and falls back to the terminal.
+func promptInput(title, text string, secret bool) (string, error) {
+	var cmd *exec.Cmd
+	switch {
+	case isMac:
+		script := "text returned of (display dialog " + appleScriptString(text) +
+			` default answer "" with title ` + appleScriptString(title)
+		if secret {
+			script += " with hidden answer"
+		}
+		cmd = exec.Command("osascript", "-e", script+")")
+	case isWindows && secret:
+		// InputBox cannot mask what is typed
+		cmd = powershell(winPasswordForm, "DICTATION_MSG="+text, "DICTATION_TITLE="+title)
+	case isWindows:
+		cmd = powershell(`Add-Type -AssemblyName Microsoft.VisualBasic; [Microsoft.VisualBasic.Interaction]::InputBox($env:DICTATION_MSG, $env:DICTATION_TITLE)`,
+			"DICTATION_MSG="+text, "DICTATION_TITLE="+title)
+	case pathExists("zenity"):
+		args := []string{"--entry", "--title", title, "--text", text}
+		if secret {
+			args = append(args, "--hide-text")
+		}
+		cmd = exec.Command("zenity", args...)
+	case pathExists("kdialog"):
+		if secret {
+			cmd = exec.Command("kdialog", "--title", title, "--password", text)
+		} else {
+			cmd = exec.Command("kdialog", "--title", title, "--inputbox", text)
+		}
+	}
+	if cmd != nil {
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: cancelled", title)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+	return promptTerminal(text, secret)
+}
+
+// winPasswordForm asks for a secret in a form whose text box shows
+// bullets; it prints the answer, or exits 1 when cancelled.
+const winPasswordForm = `Add-Type -AssemblyName System.Windows.Forms
+$f = New-Object Windows.Forms.Form -Property @{Text=$env:DICTATION_TITLE; Width=400; Height=150; StartPosition='CenterScreen'; TopMost=$true; FormBorderStyle='FixedDialog'; MaximizeBox=$false; MinimizeBox=$false}
+$l = New-Object Windows.Forms.Label -Property @{Text=$env:DICTATION_MSG; Left=10; Top=10; Width=360}
+$t = New-Object Windows.Forms.TextBox -Property @{Left=10; Top=35; Width=360; UseSystemPasswordChar=$true}
+$ok = New-Object Windows.Forms.Button -Property @{Text='OK'; Left=210; Top=70; DialogResult='OK'}
+$no = New-Object Windows.Forms.Button -Property @{Text='Cancel'; Left=295; Top=70; DialogResult='Cancel'}
+$f.AcceptButton = $ok; $f.CancelButton = $no
+$f.Controls.AddRange(@($l, $t, $ok, $no))
+if ($f.ShowDialog() -ne 'OK') { exit 1 }
+$t.Text`
+
+func promptTerminal(text string, secret bool) (string, error) {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return "", errNoPrompt
+	}
+	fmt.Fprint(os.Stderr, text+" ")
+	if secret && pathExists("stty") {
+		echoOff := exec.Command("stty", "-echo")
+		echoOff.Stdin = os.Stdin
+		if echoOff.Run() == nil {
+			defer func() {
+				echoOn := exec.Command("stty", "echo")
+				echoOn.Stdin = os.Stdin
+				_ = echoOn.Run()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptChoice asks the user to pick one of options, with a desktop list
+// dialog when available and a numbered list on the terminal otherwise.
+func promptChoice(title, text string, options []string) (string, error) {
+	var cmd *exec.Cmd
+	switch {
+	case isMac:
+		list := make([]string, len(options))
+		for i, o := range options {
+			list[i] = appleScriptString(o)
+		}
+		cmd = exec.Command("osascript", "-e", "choose from list {"+strings.Join(list, ", ")+
+			"} with title "+appleScriptString(title)+" with prompt "+appleScriptString(text))
+	case isWindows:
+		cmd = powershell(`$env:DICTATION_OPTIONS -split "`+"`"+`n" | Out-GridView -Title $env:DICTATION_TITLE -OutputMode Single`,
+			"DICTATION_OPTIONS="+strings.Join(options, "\n"), "DICTATION_TITLE="+title)
+	case pathExists("zenity"):
+		args := []string{"--list", "--title", title, "--text", text, "--column", title}
+		cmd = exec.Command("zenity", append(args, options...)...)
+	case pathExists("kdialog"):
+		args := []string{"--title", title, "--menu", text}
+		for _, o := range options {
+			args = append(args, o, o)
+		}
+		cmd = exec.Command("kdialog", args...)
+	}
+	if cmd != nil {
+		out, err := cmd.Output()
+		choice := strings.TrimRight(string(out), "\r\n")
+		// osascript prints "false" when cancelled
+		if err != nil || choice == "" || (isMac && choice == "false") {
+			return "", fmt.Errorf("%s: cancelled", title)
+		}
+		return choice, nil
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return "", errNoPrompt
+	}
+	for i, o := range options {
+		fmt.Fprintf(os.Stderr, "%d) %s\n", i+1, o)
+	}
+	line, err := promptTerminal(text, false)
+	if err != nil {
+		return "", err
+	}
+	var n int
+	if _, err := fmt.Sscan(line, &n); err != nil || n < 1 || n > len(options) {
+		return "", fmt.Errorf("%s: no such choice %q", title, line)
+	}
+	return options[n-1], nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/user/dictation/provider"
+	"github.com/user/dictation/transcribe"
+)
+
+// builtinProviders are the providers that always exist: "openai", whose
+// organization and project default to $OPENAI_ORG_ID and
+// $OPENAI_PROJECT_ID, and with a worker configured "worker". More can be
+// added under "providers".
+func builtinProviders(cfg Config) []provider.Provider {
+	list := []provider.Provider{{
+		Name:         "openai",
+		URL:          transcribe.OpenAIURL,
+		Model:        cfg.Model,
+		APIKeyEnv:    "OPENAI_API_KEY",
+		Organization: os.Getenv("OPENAI_ORG_ID"),
+		Project:      os.Getenv("OPENAI_PROJECT_ID"),
+	}}
+	if cfg.Worker != nil {
+		list = append(list, provider.Provider{Name: "worker", URL: cfg.Worker.baseURL() + "/v1/audio/transcriptions", Model: cfg.Worker.Model})
+	}
+	return list
+}
+
+// providerList returns the configured providers plus the built-in ones.
+func providerList(cfg Config) []provider.Provider {
+	return provider.Merge(cfg.Providers, builtinProviders(cfg), cfg.Model)
+}
+
+func findProvider(cfg Config, name string) (provider.Provider, error) {
+	if isExec(name) {
+		return provider.Provider{Name: name, URL: name, Model: cfg.Model}, nil
+	}
+	return provider.Find(providerList(cfg), name)
+}
+
+// defaultProviderName is the provider used when neither "provider" nor
+// routing picks one: the worker if there is one, else OpenAI.
+func defaultProviderName(cfg Config) string {
+	if cfg.Provider != "" {
+		return cfg.Provider
+	}
+	if cfg.Worker != nil {
+		return "worker"
+	}
+	return "openai"
+}
+
+// selectProvider picks the provider for the next request, letting the
+// routing stats decide when auto_route is on.
+func selectProvider(cfg Config) (provider.Provider, error) {
+	if cfg.AutoRoute {
+		if name := routeProvider(cfg); name != "" {
+			return findProvider(cfg, name)
+		}
+	}
+	return findProvider(cfg, defaultProviderName(cfg))
+}
+
+// providerKey resolves p's API key: its key file or command, else the
+// environment variable it names, else the keyring.
+func providerKey(cfg Config, p provider.Provider) (string, error) {
+	if p.APIKeyFile != "" || p.APIKeyCmd != "" {
+		return keyFromConfig(p.APIKeyFile, p.APIKeyCmd)
+	}
+	if p.APIKeyEnv == "" {
+		return "", nil
+	}
+	if p.APIKeyEnv == "OPENAI_API_KEY" {
+		return openAIKey(cfg)
+	}
+	if k := os.Getenv(p.APIKeyEnv); k != "" {
+		return k, nil
+	}
+	if k := keyFromKeyring(cfg, p.APIKeyEnv); k != "" {
+		return k, nil
+	}
+	return "", fmt.Errorf("%s not set (or store it with `dictate set-key %s`)", p.APIKeyEnv, p.Name)
+}
+
+// providerHasKey reports whether the provider's key is configured, without
+// resolving it: key commands and the portable passphrase prompt are left
+// for when the key is actually needed.
+func providerHasKey(cfg Config, p provider.Provider) bool {
+	fileExists := func(path string) bool {
+		_, err := os.Stat(expandHome(path))
+		return err == nil
+	}
+	if p.APIKeyFile != "" || p.APIKeyCmd != "" {
+		return p.APIKeyCmd != "" || fileExists(p.APIKeyFile)
+	}
+	switch {
+	case p.APIKeyEnv == "":
+		return true
+	case os.Getenv(p.APIKeyEnv) != "":
+		return true
+	case p.APIKeyEnv == "OPENAI_API_KEY" && (cfg.APIKeyFile != "" || cfg.APIKeyCmd != ""):
+		return cfg.APIKeyCmd != "" || fileExists(cfg.APIKeyFile)
+	case p.APIKeyEnv == "OPENAI_API_KEY" && cfg.Portable:
+		// the key is asked for on first use when there is no file yet
+		return true
+	}
+	return keyFromKeyring(cfg, p.APIKeyEnv) != ""
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// With push_to_talk set to a key or mouse button, the daemon records while
+// it is held on any keyboard or mouse and transcribes when it is let go.
+
+// minHold is how long the key has to be held for the recording to be
+// transcribed; shorter presses are taken as slips and discarded.
+const minHold = 300 * time.Millisecond
+
+// watchPushToTalk runs push-to-talk on key until the daemon exits.
+func watchPushToTalk(key string, run func(action, profile string) error) {
+	code, err := keyCode(key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "push_to_talk:", err)
+		return
+	}
+	queue := make(chan string, 16)
+	go func() {
+		for action := range queue {
+			if err := run(action, ""); err != nil && action != actionCancel {
+				fmt.Fprintf(os.Stderr, "push-to-talk: %s: %v\n", action, err)
+			}
+		}
+	}()
+	var pressed time.Time
+	held := 0 // the key may be down on more than one device
+	onKey := func(c uint16, value int32) {
+		if c != code {
+			return
+		}
+		switch value {
+		case keyPressed:
+			if held++; held == 1 {
+				pressed = time.Now()
+				queue <- actionPushToTalk + pttPress
+			}
+		case keyReleased:
+			if held == 0 {
+				return
+			}
+			if held--; held == 0 {
+				if time.Since(pressed) < minHold {
+					queue <- actionCancel
+				} else {
+					queue <- actionPushToTalk + pttRelease
+				}
+			}
+		}
+	}
+	var mu sync.Mutex
+	err = readInputDevices([]string{"kbd", "mouse"}, func(c uint16, value int32) {
+		mu.Lock()
+		defer mu.Unlock()
+		onKey(c, value)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "push-to-talk:", err)
+		notifyFailure("Dictation", "Push-to-talk is not available: "+err.Error())
+	}
+}
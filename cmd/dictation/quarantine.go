@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/user/dictation/audio"
+)
+
+// A dictation that fails is moved to the quarantine directory instead of
+// being left as a wav in the recordings directory, where the next toggle
+// would mistake it for a finished recording. `dictate retry` resubmits it.
+
+// Stages a quarantined dictation failed at.
+const (
+	stageTranscribe = "transcribe"
+	stageInsert     = "insert"
+)
+
+// quarantined describes one failed dictation; its recording sits next to
+// it as <id>.wav.
+type quarantined struct {
+	ID    string    `json:"id"`
+	Time  time.Time `json:"time"`
+	Stage string    `json:"stage"`
+	Error string    `json:"error"`
+	// Mode is the recording mode (edit) the dictation was made in.
+	Mode string `json:"mode,omitempty"`
+	// Text is the finished transcript when only inserting it failed.
+	Text string `json:"text,omitempty"`
+}
+
+func quarantineDir() string {
+	return filepath.Join(dataDir(), "quarantine")
+}
+
+func (q quarantined) wavPath() string {
+	return filepath.Join(quarantineDir(), q.ID+".wav")
+}
+
+func (q quarantined) metaPath() string {
+	return filepath.Join(quarantineDir(), q.ID+".json")
+}
+
+// quarantine moves wav out of the way with a record of why it failed.
+// The recording is deleted only if it cannot be moved.
+func quarantine(wav, stage, mode, text string, failure error) {
+	now := time.Now()
+	q := quarantined{ID: strconv.FormatInt(now.UnixNano(), 36), Time: now, Stage: stage,
+		Error: failure.Error(), Mode: mode, Text: text}
+	err := saveQuarantined(q)
+	if err == nil {
+		err = os.Rename(wav, q.wavPath())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not quarantine wav:", err)
+		os.Remove(q.metaPath())
+		os.Remove(wav)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "recording kept; resubmit with `dictate retry %s`\n", q.ID)
+}
+
+func saveQuarantined(q quarantined) error {
+	if err := os.MkdirAll(quarantineDir(), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(q.metaPath(), b, 0600)
+}
+
+// loadQuarantine returns the quarantined dictations, oldest first.
+func loadQuarantine() ([]quarantined, error) {
+	paths, err := filepath.Glob(filepath.Join(quarantineDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var qs []quarantined
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var q quarantined
+		if err := json.Unmarshal(b, &q); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", p, err)
+			continue
+		}
+		qs = append(qs, q)
+	}
+	sort.Slice(qs, func(i, j int) bool { return qs[i].Time.Before(qs[j].Time) })
+	return qs, nil
+}
+
+// runRetry implements `dictate retry [id|last|all]`: without an argument it
+// lists the quarantined dictations, otherwise it resubmits them. A
+// dictation that fails again stays quarantined with the new error.
+func runRetry(cfg Config, args []string) error {
+	qs, err := loadQuarantine()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		if len(qs) == 0 {
+			fmt.Fprintln(os.Stderr, "nothing quarantined")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+		fmt.Fprintln(w, "ID\tTIME\tSTAGE\tERROR")
+		for _, q := range qs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", q.ID, q.Time.Format("2006-01-02 15:04:05"), q.Stage, q.Error)
+		}
+		return nil
+	}
+	if len(args) > 1 {
+		return exitError{exitUsage, errors.New("usage: dictate retry [id|last|all]")}
+	}
+	var todo []quarantined
+	for _, q := range qs {
+		if args[0] == "all" || q.ID == args[0] {
+			todo = append(todo, q)
+		}
+	}
+	if args[0] == "last" && len(qs) > 0 {
+		todo = qs[len(qs)-1:]
+	}
+	if len(todo) == 0 {
+		return exitError{exitUsage, fmt.Errorf("no quarantined dictation %q", args[0])}
+	}
+	var failed []string
+	for _, q := range todo {
+		if err := resubmit(cfg, q); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", q.ID, err)
+			q.Error = err.Error()
+			if err := saveQuarantined(q); err != nil {
+				fmt.Fprintln(os.Stderr, "warning:", err)
+			}
+			failed = append(failed, q.ID)
+			continue
+		}
+		os.Remove(q.metaPath())
+		finishWAV(cfg, q.wavPath(), "")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("still failing: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// resubmit runs a quarantined dictation through the rest of the pipeline,
+// from the stage it failed at. The text goes where it would have gone
+// then, into the window focused now.
+func resubmit(cfg Config, q quarantined) error {
+	text, language := q.Text, ""
+	if q.Stage == stageTranscribe {
+		if q.Mode == modeTranslate {
+			cfg.Translate = true
+		}
+		res, err := transcribeFile(context.Background(), cfg, q.wavPath())
+		if err != nil {
+			return err
+		}
+		switch q.Mode {
+		case modeEdit:
+			return editSelection(cfg, res.Text)
+		case modeNote:
+			return saveNote(cfg, postProcess(cfg, res.Text))
+		}
+		t := keepTranscript(cfg, res, q.wavPath())
+		text, language = t.Text, res.Language
+	}
+	text, err := prepareOutput(&cfg, text, language)
+	if err != nil {
+		return err
+	}
+	_, err = insertText(cfg, text)
+	return err
+}
+
+// keepTranscript post-processes a transcription of wav and saves it as the
+// last transcript and in the history.
+func keepTranscript(cfg Config, res transcription, wav string) Transcript {
+	text := postProcess(cfg, res.Text)
+	t := newTranscript(text)
+	t.Provider = res.Provider
+	t.Model = res.Model
+	if err := saveLastTranscript(t); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save transcript:", err)
+	}
+	h := historyEntry{ID: t.ID, Time: t.Time, Provider: t.Provider, Model: t.Model, Text: redact(cfg.Redact, text)}
+	if d, err := audio.WAVDuration(wav); err == nil {
+		h.Duration = d
+	}
+	if err := appendHistory(h); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save history:", err)
+	}
+	return t
+}
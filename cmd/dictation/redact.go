@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedactRule hides matching text in what is kept on disk (the history and
+// its search index) while the full text is still typed. Name alone selects
+// a built-in pattern; with Pattern it labels a custom one.
+type RedactRule struct {
+	Name string `json:"name"`
+	// Pattern is a Go regular expression.
+	Pattern string `json:"pattern"`
+	// Replace is what a match becomes, by default the upper-cased name in
+	// brackets ("[EMAIL]").
+	Replace string `json:"replace"`
+
+	re    *regexp.Regexp
+	check func(string) bool
+}
+
+// builtinRedactions are the patterns available by name. check, when set,
+// must also accept a match before it is redacted.
+var builtinRedactions = map[string]struct {
+	pattern string
+	check   func(string) bool
+}{
+	"email":       {`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`, nil},
+	"credit_card": {`\b(?:\d[ -]?){12,18}\d\b`, luhnValid},
+	"phone":       {`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)[ .-]?|\b\d{2,4}[.-])?\b\d{3,4}[.-]\d{4}\b`, nil},
+	"iban":        {`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`, nil},
+}
+
+// compileRedactions resolves built-in names and compiles the patterns.
+func compileRedactions(rules []RedactRule) error {
+	for i := range rules {
+		r := &rules[i]
+		if r.Pattern == "" {
+			b, ok := builtinRedactions[r.Name]
+			if !ok {
+				return fmt.Errorf("redact: unknown pattern %q (email, credit_card, phone, iban, or give \"pattern\")", r.Name)
+			}
+			r.Pattern, r.check = b.pattern, b.check
+		}
+		var err error
+		if r.re, err = regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("redact %q: %v", r.Name, err)
+		}
+		if r.Replace == "" {
+			r.Replace = "[REDACTED]"
+			if r.Name != "" {
+				r.Replace = "[" + strings.ToUpper(r.Name) + "]"
+			}
+		}
+	}
+	return nil
+}
+
+// redact applies the rules in order.
+func redact(rules []RedactRule, text string) string {
+	for _, r := range rules {
+		text = r.re.ReplaceAllStringFunc(text, func(m string) string {
+			if r.check != nil && !r.check(m) {
+				return m
+			}
+			return r.Replace
+		})
+	}
+	return text
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum card
+// numbers carry, so other long numbers are left alone.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"4111-1111-1111-1111", true},
+		{"5500005555555559", true},
+		{"378282246310005", true},
+		{"4111111111111112", false},
+		// too short to be a card, even with a valid checksum
+		{"18", false},
+		{"000000000000", false},
+		{"0000000000000", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := luhnValid(tt.in); got != tt.want {
+			t.Errorf("luhnValid(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	rules := []RedactRule{
+		{Name: "email"},
+		{Name: "credit_card"},
+		{Name: "phone"},
+		{Name: "iban"},
+		{Name: "ticket", Pattern: `\bJIRA-\d+\b`, Replace: "[T]"},
+		{Pattern: `secret\w*`},
+	}
+	if err := compileRedactions(rules); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"mail jane.doe+x@example.co.uk now", "mail [EMAIL] now"},
+		{"card 4111 1111 1111 1111 ok", "card [CREDIT_CARD] ok"},
+		// long numbers failing the checksum are not cards
+		{"order 4111 1111 1111 1112 ok", "order 4111 1111 1111 1112 ok"},
+		{"call 555-123-4567 or +1 (555) 123-4567", "call [PHONE] or [PHONE]"},
+		{"pay to DE89 3704 0044 0532 0130 00", "pay to [IBAN]"},
+		{"see JIRA-123 and JIRA-x", "see [T] and JIRA-x"},
+		{"the secretword is", "the [REDACTED] is"},
+		{"nothing to hide", "nothing to hide"},
+	}
+	for _, tt := range tests {
+		if got := redact(rules, tt.in); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompileRedactionsErrors(t *testing.T) {
+	for _, rules := range [][]RedactRule{
+		{{Name: "ssn"}},
+		{{Name: "bad", Pattern: `(`}},
+	} {
+		if err := compileRedactions(rules); err == nil || !strings.Contains(err.Error(), rules[0].Name) {
+			t.Errorf("compileRedactions(%+v) = %v, want an error naming the rule", rules, err)
+		}
+	}
+}
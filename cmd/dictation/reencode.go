@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/dictation/transcribe"
+)
+
+// unsupportedFormat reports whether err is a provider complaining about the
+// audio format (OpenAI: "Invalid file format", others similar).
+func unsupportedFormat(err error) bool {
+	var ae *transcribe.APIError
+	if !errors.As(err, &ae) || ae.Status != 400 && ae.Status != 415 {
+		return false
+	}
+	body := strings.ToLower(ae.Body)
+	for _, s := range []string{"invalid file format", "unsupported", "could not be decoded", "format is not supported", "decode"} {
+		if strings.Contains(body, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// reencodeAudio converts path to 16kHz mono in the given container format
+// (flac by default) with ffmpeg, or sox as a fallback. This also repairs
+// WAVs whose header was never finalised. The caller removes the returned
+// file.
+func reencodeAudio(path, format string) (string, error) {
+	if format == "" {
+		format = "flac"
+	}
+	dst, err := os.CreateTemp("", "dictation-*."+format)
+	if err != nil {
+		return "", err
+	}
+	dst.Close()
+
+	var cmd *exec.Cmd
+	switch {
+	case pathExists("ffmpeg"):
+		cmd = exec.Command("ffmpeg", "-y", "-loglevel", "error", "-i", path, "-ar", "16000", "-ac", "1", dst.Name())
+	case pathExists("sox"):
+		cmd = exec.Command("sox", "-q", "--ignore-length", path, "-r", "16000", "-c", "1", dst.Name())
+	default:
+		os.Remove(dst.Name())
+		return "", errors.New("cannot re-encode audio: install ffmpeg or sox")
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("re-encoding %s failed: %v: %s", filepath.Base(path), err, strings.TrimSpace(string(out)))
+	}
+	return dst.Name(), nil
+}
+
+// denoiseAudio writes a copy of path with low rumble and steady background
+// noise filtered out by ffmpeg. The caller removes the returned file.
+func denoiseAudio(path string) (string, error) {
+	if !pathExists("ffmpeg") {
+		return "", errors.New("denoise needs ffmpeg")
+	}
+	dst, err := os.CreateTemp("", "dictation-denoised-*.wav")
+	if err != nil {
+		return "", err
+	}
+	dst.Close()
+	cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error", "-i", path, "-af", "highpass=f=80,afftdn", "-ar", "16000", "-ac", "1", dst.Name())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("denoising %s failed: %v: %s", filepath.Base(path), err, strings.TrimSpace(string(out)))
+	}
+	return dst.Name(), nil
+}
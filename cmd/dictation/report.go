@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// With "metrics": true, every dictation leaves a line in metrics.jsonl in
+// the state directory: the request and its latency, corrections made
+// through the daemon and failures by subsystem. Nothing leaves the
+// machine; `dictate report` sums it up per period so the effect of a
+// config change shows in the numbers.
+
+// Kinds of metric events.
+const (
+	metricRequest    = "request"
+	metricCorrection = "correction"
+	metricFailure    = "failure"
+	metricDictation  = "dictation"
+)
+
+type metric struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	// Provider is set for requests and corrections.
+	Provider  string `json:"provider,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	// Words is the length of the transcript (the original one for a
+	// correction); Edits the word edits a correction needed.
+	Words  int  `json:"words,omitempty"`
+	Edits  int  `json:"edits,omitempty"`
+	Failed bool `json:"failed,omitempty"`
+	// Class is the doctor section a failure belongs to.
+	Class string `json:"class,omitempty"`
+}
+
+func metricsPath() string {
+	return filepath.Join(stateDir(), "metrics.jsonl")
+}
+
+// recordMetric appends m when metrics are enabled.
+func recordMetric(cfg Config, m metric) {
+	if !cfg.Metrics {
+		return
+	}
+	m.Time = time.Now()
+	b, err := json.Marshal(m)
+	if err == nil {
+		err = appendLine(metricsPath(), b)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not record metrics:", err)
+	}
+}
+
+// loadMetrics returns the events since from, oldest first.
+func loadMetrics(from time.Time) ([]metric, error) {
+	f, err := os.Open(metricsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ms []metric
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m metric
+		if json.Unmarshal(sc.Bytes(), &m) == nil && !m.Time.Before(from) {
+			ms = append(ms, m)
+		}
+	}
+	return ms, sc.Err()
+}
+
+// periodStats sums up the metrics of one period.
+type periodStats struct {
+	From, To   time.Time
+	Dictations int
+	Words      int
+	// Providers is keyed by provider name.
+	Providers map[string]*periodProvider
+	// Failures is keyed by doctor section.
+	Failures map[string]int
+}
+
+type periodProvider struct {
+	Requests  int
+	Failures  int
+	Words     int
+	Edited    int
+	latencies []int64
+}
+
+func (p *periodProvider) latency(q float64) time.Duration {
+	if len(p.latencies) == 0 {
+		return 0
+	}
+	return time.Duration(p.latencies[int(q*float64(len(p.latencies)-1))]) * time.Millisecond
+}
+
+// werProxy is edited words per transcribed word.
+func (p *periodProvider) werProxy() float64 {
+	if p.Words == 0 {
+		return 0
+	}
+	return float64(p.Edited) / float64(p.Words)
+}
+
+func summarize(ms []metric, from, to time.Time) periodStats {
+	s := periodStats{From: from, To: to, Providers: map[string]*periodProvider{}, Failures: map[string]int{}}
+	for _, m := range ms {
+		if m.Time.Before(from) || !m.Time.Before(to) {
+			continue
+		}
+		p := s.Providers[m.Provider]
+		if p == nil && m.Provider != "" {
+			p = &periodProvider{}
+			s.Providers[m.Provider] = p
+		}
+		switch m.Kind {
+		case metricDictation:
+			s.Dictations++
+			s.Words += m.Words
+		case metricRequest:
+			p.Requests++
+			if m.Failed {
+				p.Failures++
+				continue
+			}
+			p.Words += m.Words
+			p.latencies = append(p.latencies, m.LatencyMS)
+		case metricCorrection:
+			if p != nil {
+				p.Edited += m.Edits
+			}
+		case metricFailure:
+			s.Failures[m.Class]++
+		}
+	}
+	for _, p := range s.Providers {
+		sort.Slice(p.latencies, func(i, j int) bool { return p.latencies[i] < p.latencies[j] })
+	}
+	return s
+}
+
+// runReport implements `dictate report [--month|--week|--days N]`: the
+// metrics of the last period next to the one before it.
+func runReport(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.Bool("month", true, "report on the last 30 days (the default)")
+	week := fs.Bool("week", false, "report on the last 7 days")
+	days := fs.Int("days", 0, "report on the last N days")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	n := 30
+	switch {
+	case *days > 0:
+		n = *days
+	case *week:
+		n = 7
+	}
+	if !cfg.Metrics {
+		fmt.Fprintln(os.Stderr, `metrics are off; set "metrics": true in the config to collect them`)
+	}
+	now := time.Now()
+	period := time.Duration(n) * 24 * time.Hour
+	ms, err := loadMetrics(now.Add(-2 * period))
+	if err != nil {
+		return err
+	}
+	cur := summarize(ms, now.Add(-period), now)
+	prev := summarize(ms, now.Add(-2*period), now.Add(-period))
+	return printReport(os.Stdout, cur, prev)
+}
+
+func printReport(out io.Writer, cur, prev periodStats) error {
+	fmt.Fprintf(out, "%s – %s (previous %d days in brackets)\n\n", cur.From.Format("2006-01-02"), cur.To.Format("2006-01-02"),
+		int(cur.To.Sub(cur.From).Hours()/24))
+	fmt.Fprintf(out, "dictations  %d [%d]\nwords       %d [%d]\n\n", cur.Dictations, prev.Dictations, cur.Words, prev.Words)
+
+	names := map[string]bool{}
+	for n := range cur.Providers {
+		names[n] = true
+	}
+	for n := range prev.Providers {
+		names[n] = true
+	}
+	if len(names) > 0 {
+		sorted := make([]string, 0, len(names))
+		for n := range names {
+			sorted = append(sorted, n)
+		}
+		sort.Strings(sorted)
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROVIDER\tREQUESTS\tFAILED\tMEDIAN LATENCY\tP95 LATENCY\tCORRECTION RATE")
+		for _, n := range sorted {
+			c, p := cur.Providers[n], prev.Providers[n]
+			if c == nil {
+				c = &periodProvider{}
+			}
+			if p == nil {
+				p = &periodProvider{}
+			}
+			fmt.Fprintf(tw, "%s\t%d [%d]\t%d [%d]\t%s [%s]\t%s [%s]\t%.1f%% [%.1f%%]\n", n,
+				c.Requests, p.Requests, c.Failures, p.Failures,
+				c.latency(0.5).Round(10*time.Millisecond), p.latency(0.5).Round(10*time.Millisecond),
+				c.latency(0.95).Round(10*time.Millisecond), p.latency(0.95).Round(10*time.Millisecond),
+				100*c.werProxy(), 100*p.werProxy())
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBSYSTEM\tFAILURES")
+	for _, class := range []string{checkRecorder, checkTranscription, checkTyping} {
+		fmt.Fprintf(tw, "%s\t%d [%d]\n", class, cur.Failures[class], prev.Failures[class])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/user/dictation/insert"
+	"github.com/user/dictation/notify"
+)
+
+// With result_notification, the daemon shows each transcript in a
+// notification whose buttons act on that dictation afterwards.
+
+// Result notification actions.
+const (
+	resultCopy    = "copy"
+	resultRetry   = "retry"
+	resultDiscard = "discard"
+	resultHistory = "history"
+)
+
+const resultPreviewLength = 200
+
+// lastTranscriptID is the id of the last transcript, or "" when there is
+// none.
+func lastTranscriptID() string {
+	t, err := loadLastTranscript()
+	if err != nil {
+		return ""
+	}
+	return t.ID
+}
+
+// showResult is called after each toggle the daemon runs; before is the
+// last transcript's id from before it. When the toggle produced a new
+// transcript, its notification is shown and waited on in the background.
+func (d *daemon) showResult(before string) {
+	if !d.cfg.ResultNotification || isMac || isWindows {
+		return
+	}
+	t, err := loadLastTranscript()
+	if err != nil || t.ID == before {
+		return
+	}
+	go func() {
+		action, err := notify.WaitAction(notify.Notification{
+			Summary: "Dictation",
+			Body:    trimPreview(t.FinalText()),
+			Urgency: notify.Low,
+			Timeout: -1,
+			Actions: []string{
+				resultCopy, "Copy again",
+				resultRetry, "Retry transcription",
+				resultDiscard, "Discard",
+				resultHistory, "Open history",
+			},
+		})
+		if err != nil || action == "" {
+			return
+		}
+		if err := d.resultAction(action, t); err != nil {
+			notifyFailure("Dictation", err.Error())
+		}
+	}()
+}
+
+func trimPreview(s string) string {
+	if r := []rune(s); len(r) > resultPreviewLength {
+		return string(r[:resultPreviewLength]) + "…"
+	}
+	return s
+}
+
+// resultAction acts on the dictation t. Retry and Discard change what was
+// typed, so they only apply while t is still the last transcript.
+func (d *daemon) resultAction(action string, t Transcript) error {
+	switch action {
+	case resultCopy:
+		if err := insert.Copy(t.FinalText()); err != nil {
+			return err
+		}
+		notifyUser("Dictation", "Transcript copied to clipboard")
+		return nil
+	case resultHistory:
+		return exec.Command("xdg-open", historyPath()).Start()
+	case resultRetry, resultDiscard:
+	default:
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if lastTranscriptID() != t.ID {
+		return errors.New("that is no longer the last dictation")
+	}
+	if action == resultDiscard {
+		if t.Inserted == "" {
+			return nil
+		}
+		return runVoiceCommand(d.cfg, cmdScratch)
+	}
+
+	before := t.ID
+	defer d.showResult(before)
+	wav, err := recordingFor(d.cfg, t.ID)
+	if err != nil {
+		return err
+	}
+	cfg := d.cfg
+	res, err := transcribeFile(context.Background(), cfg, wav)
+	if err != nil {
+		return fmt.Errorf("transcription failed: %v", err)
+	}
+	if err := insert.Backspace(len([]rune(t.Inserted)), false); err != nil {
+		return err
+	}
+	nt := keepTranscript(cfg, res, wav)
+	text, err := prepareOutput(&cfg, nt.Text, res.Language)
+	if err != nil {
+		return err
+	}
+	typed, err := insertText(cfg, text)
+	if err != nil {
+		return err
+	}
+	if typed {
+		markInserted(nt.ID, text)
+	}
+	return nil
+}
+
+// keepLastRecording reports whether finishWAV should keep the recording as
+// the last one: for the correction API's word timestamps and for Retry.
+func keepLastRecording(cfg Config) bool {
+	return cfg.WordTimestamps || cfg.ResultNotification && cfg.inDaemon
+}
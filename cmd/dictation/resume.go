@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Batch results are remembered by audio content, so re-running a batch
+// over the same files after an interruption picks up where it stopped:
+// files done earlier are not sent again and their output is replayed.
+
+func resumeDir() string {
+	return filepath.Join(stateDir(), "batch")
+}
+
+// resumeKey identifies the output of path under the given output mode. It
+// hashes the audio itself rather than the path, so renamed or copied
+// files are recognised and edited ones are not; modes of exports, which
+// are written next to the input, include its path. It returns "" when the
+// file cannot be read.
+func resumeKey(path, mode string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	fmt.Fprintf(h, "\x00%s", mode)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// postProcessKey sums up the post-processing settings, including the
+// contents of the replacements file, for the resume key.
+func postProcessKey(cfg Config) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%v\x00%v\x00%s\x00%s\x00%s\x00", cfg.Casing, systemPrompt(cfg), cfg.SpokenPunctuation,
+		cfg.CodeMode, cfg.Punctuation, cfg.PostProcessor, cfg.ReplacementsFile)
+	if cfg.ReplacementsFile != "" {
+		if b, err := os.ReadFile(expandHome(cfg.ReplacementsFile)); err == nil {
+			h.Write(b)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// loadResume returns the output stored for key. Exports written next to
+// the input (whose paths make up the output) only count while the files
+// are still there.
+func loadResume(key string, exported bool) ([]byte, bool) {
+	if key == "" {
+		return nil, false
+	}
+	b, err := os.ReadFile(filepath.Join(resumeDir(), key))
+	if err != nil {
+		return nil, false
+	}
+	if exported {
+		for _, dst := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			if _, err := os.Stat(dst); err != nil {
+				return nil, false
+			}
+		}
+	}
+	return b, true
+}
+
+func saveResume(key string, out []byte) {
+	if key == "" {
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(resumeDir(), key), out, 0600); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save batch progress:", err)
+	}
+}
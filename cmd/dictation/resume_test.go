@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/dictation/postprocess"
+)
+
+func TestResumeKey(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a.wav", "RIFF audio")
+	copied := write("copy.wav", "RIFF audio")
+	edited := write("edited.wav", "RIFF audio, trimmed")
+
+	key := resumeKey(a, "text")
+	if len(key) != 64 {
+		t.Fatalf("resumeKey = %q, want a hex SHA-256", key)
+	}
+	if got := resumeKey(a, "text"); got != key {
+		t.Error("resumeKey is not stable")
+	}
+	// the audio is what counts, not its name
+	if got := resumeKey(copied, "text"); got != key {
+		t.Error("a copy of the same audio got another key")
+	}
+	if got := resumeKey(edited, "text"); got == key {
+		t.Error("edited audio got the same key")
+	}
+	if got := resumeKey(a, "json"); got == key {
+		t.Error("another mode got the same key")
+	}
+	// the separator keeps audio and mode apart
+	if resumeKey(write("b.wav", "RIFF audio\x00te"), "xt") == resumeKey(a, "text") {
+		t.Error("audio and mode run together")
+	}
+	if got := resumeKey(filepath.Join(dir, "missing.wav"), "text"); got != "" {
+		t.Errorf("missing file: %q", got)
+	}
+}
+
+func TestPostProcessKey(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "replacements.json")
+	if err := os.WriteFile(rules, []byte(`[{"from": "a", "to": "b"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := Config{Casing: postprocess.CasingSentence, ReplacementsFile: rules}
+	key := postProcessKey(base)
+	if len(key) != 16 || postProcessKey(base) != key {
+		t.Fatalf("postProcessKey = %q, want 16 stable hex digits", key)
+	}
+	changes := map[string]func(*Config){
+		"casing":             func(c *Config) { c.Casing = postprocess.CasingLower },
+		"spoken punctuation": func(c *Config) { c.SpokenPunctuation = true },
+		"code mode":          func(c *Config) { c.CodeMode = true },
+		"punctuation":        func(c *Config) { c.Punctuation = postprocess.PunctuationFrench },
+		"prompt":             func(c *Config) { c.Prompt = "Fix the grammar." },
+		"replacements file":  func(c *Config) { c.ReplacementsFile = "" },
+	}
+	for name, change := range changes {
+		cfg := base
+		change(&cfg)
+		if postProcessKey(cfg) == key {
+			t.Errorf("changing the %s keeps the key", name)
+		}
+	}
+	// edits to the rules count, not only the file name
+	if err := os.WriteFile(rules, []byte(`[{"from": "a", "to": "c"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if postProcessKey(base) == key {
+		t.Error("editing the replacements keeps the key")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// recordingFor returns the kept recording behind the transcript id: the
+// archived one with keep_audio, or the last one kept for word timestamps.
+// "last" names the most recent transcript whatever its id.
+func recordingFor(cfg Config, id string) (string, error) {
+	t, err := loadLastTranscript()
+	if err == nil && id == "last" {
+		id = t.ID
+	}
+	if m, _ := filepath.Glob(filepath.Join(audioArchiveDir(cfg), "*_"+id+".*")); len(m) > 0 {
+		return m[0], nil
+	}
+	if err == nil && id == t.ID {
+		if _, err := os.Stat(lastAudioPath()); err == nil {
+			return lastAudioPath(), nil
+		}
+	}
+	return "", fmt.Errorf("no recording kept for %q; enable keep_audio", id)
+}
+
+// runRetranscribe implements `dictate retranscribe [--provider name]
+// [--model name] <id|file>... [transcribe flags]`: it sends kept
+// recordings, or any audio files, through a possibly different backend,
+// to redo a bad first pass or compare backends. Output works as for
+// `transcribe`.
+func runRetranscribe(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("retranscribe", flag.ContinueOnError)
+	provider := fs.String("provider", "", "provider to use instead of the configured one")
+	model := fs.String("model", "", "model to use instead of the provider's")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: dictate retranscribe [--provider name] [--model name] <id|last|file>... [transcribe flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if *provider != "" {
+		if _, err := findProvider(cfg, *provider); err != nil {
+			return exitError{exitUsage, err}
+		}
+		cfg.Provider = *provider
+		cfg.AutoRoute = false
+	}
+	cfg.forceModel = *model
+
+	// ids and files come first, flags for transcribe after them
+	var paths, rest []string
+	for i, a := range fs.Args() {
+		if len(a) > 1 && a[0] == '-' {
+			rest = fs.Args()[i:]
+			break
+		}
+		if _, err := os.Stat(a); err == nil {
+			paths = append(paths, a)
+			continue
+		}
+		path, err := recordingFor(cfg, a)
+		if err != nil {
+			return exitError{exitUsage, err}
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		return exitError{exitUsage, errors.New("nothing to retranscribe")}
+	}
+	return runTranscribe(cfg, append(rest, paths...))
+}
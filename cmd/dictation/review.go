@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// With review (or --review) every transcript is shown in an editable
+// dialog before anything is typed: fix what the model misheard and accept
+// to insert it, or cancel and nothing is inserted. Edits are kept as the
+// transcript's correction, like those from the daemon's correction API.
+
+// Review dialogs.
+const (
+	reviewZenity = "zenity"
+	reviewYad    = "yad"
+	reviewRofi   = "rofi" // a single line: newlines become spaces
+)
+
+func validReviewDialog(d string) bool {
+	switch d {
+	case "", reviewZenity, reviewYad, reviewRofi:
+		return true
+	}
+	return false
+}
+
+// reviewDialog is the dialog reviewTranscript uses on Linux: review_dialog,
+// or else the first one installed; "" when there is none.
+func reviewDialog(cfg Config) string {
+	if cfg.ReviewDialog != "" {
+		if !pathExists(cfg.ReviewDialog) {
+			return ""
+		}
+		return cfg.ReviewDialog
+	}
+	for _, d := range []string{reviewZenity, reviewYad, reviewRofi} {
+		if pathExists(d) {
+			return d
+		}
+	}
+	return ""
+}
+
+// canReview reports whether a review dialog can be shown.
+func canReview(cfg Config) bool {
+	return isMac || isWindows || reviewDialog(cfg) != ""
+}
+
+// reviewTranscript lets the user edit text. It returns the text to insert,
+// and false when the dialog was cancelled or emptied.
+func reviewTranscript(cfg Config, text string) (string, bool, error) {
+	var cmd *exec.Cmd
+	switch {
+	case isMac:
+		script := "text returned of (display dialog " + appleScriptString("Edit the transcript, then type it:") +
+			" default answer " + appleScriptString(text) +
+			` with title "Dictation" buttons {"Cancel", "Type"} default button "Type")`
+		cmd = exec.Command("osascript", "-e", script)
+	case isWindows:
+		// an empty answer is also what Cancel returns
+		cmd = powershell(`Add-Type -AssemblyName Microsoft.VisualBasic; [Microsoft.VisualBasic.Interaction]::InputBox('Edit the transcript, then type it:', 'Dictation', $env:DICTATION_TEXT)`,
+			"DICTATION_TEXT="+text)
+	default:
+		switch reviewDialog(cfg) {
+		case "":
+			if cfg.ReviewDialog != "" {
+				return "", false, fmt.Errorf("%s not found", cfg.ReviewDialog)
+			}
+			return "", false, errors.New("no dialog to review the transcript with (install zenity, yad or rofi)")
+		case reviewZenity:
+			cmd = exec.Command("zenity", "--text-info", "--editable", "--title=Dictation",
+				"--ok-label=Type", "--cancel-label=Cancel", "--width=600", "--height=300")
+			cmd.Stdin = strings.NewReader(text)
+		case reviewYad:
+			cmd = exec.Command("yad", "--text-info", "--editable", "--title=Dictation", "--wrap",
+				"--button=Cancel:1", "--button=Type:0", "--width=600", "--height=300")
+			cmd.Stdin = strings.NewReader(text)
+		case reviewRofi:
+			// with no entries to choose from, Enter returns what was typed
+			cmd = exec.Command("rofi", "-dmenu", "-p", "Dictation", "-mesg", "Enter types the transcript, Escape cancels",
+				"-filter", strings.Join(strings.Fields(text), " "))
+			cmd.Stdin = strings.NewReader("")
+		}
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// Cancel or Escape in the Linux dialogs and osascript
+		return "", false, nil
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", false, fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+		return "", false, fmt.Errorf("%s: %v", cmd.Args[0], err)
+	}
+	edited := strings.TrimRight(strings.ReplaceAll(string(out), "\r\n", "\n"), "\n")
+	if strings.TrimSpace(edited) == "" {
+		return "", false, nil
+	}
+	return edited, true, nil
+}
+
+// correctLastTranscript saves text as the correction of the last
+// transcript, or of id's if it is not "", counting the first correction of
+// each transcript in the provider's stats.
+func correctLastTranscript(cfg Config, id, text string) (Transcript, error) {
+	firstCorrection := false
+	t, err := updateLastTranscript(func(t *Transcript) error {
+		if id != "" && t.ID != id {
+			return fmt.Errorf("transcript %s is no longer the last one", id)
+		}
+		firstCorrection = t.Corrected == ""
+		t.Corrected = text
+		return nil
+	})
+	if err != nil {
+		return t, err
+	}
+	// count each transcript once, however often it is re-corrected
+	if firstCorrection {
+		recordCorrection(t.Provider, t.Text, t.Corrected)
+		recordMetric(cfg, metric{Kind: metricCorrection, Provider: t.Provider,
+			Words: len(strings.Fields(t.Text)), Edits: wordEdits(t.Text, t.Corrected)})
+	}
+	return t, nil
+}
+
+// reviewDictation runs the review step of a toggle. It returns the text to
+// go on with, and false when the user cancelled.
+func reviewDictation(cfg Config, t Transcript, text string) (string, bool, error) {
+	edited, ok, err := reviewTranscript(cfg, text)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	if edited != text {
+		if _, err := correctLastTranscript(cfg, t.ID, edited); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: could not save correction:", err)
+		}
+	}
+	return edited, true, nil
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Routes decide per transcript where and how it is delivered, in one place
+// instead of spread over profiles and app rules. Each one is a line such as
+//
+//	if profile == meeting and words > 200 then output = file
+//	if class ~ "slack|discord" then suffix = "\n", stop
+//	if hour >= 18 or weekday == sat then template = "[after hours] {{.Text}}"
+//
+// Conditions compare the variables profile, language, class, title,
+// output, words, chars, hour and weekday (mon…sun) with ==, !=, <, <=,
+// >, >= or ~ (case-insensitive regular expression), combined with and,
+// or, not and parentheses. Actions set output (or its alias sink),
+// template, suffix or file (output_file), or are disable or stop; several
+// are separated by commas. A line without "if" always applies. Routes run
+// in order after the app rules and each match overrides the earlier ones;
+// stop ends the evaluation.
+
+// route is one compiled routing line.
+type route struct {
+	cond    func(routeVars) bool
+	then    []routeAction
+	orElse  []routeAction
+	window  bool // the condition looks at the focused window
+	hasCond bool
+}
+
+type routeAction struct {
+	key, value string
+}
+
+// routeVars are the values conditions can look at.
+type routeVars map[string]string
+
+var routeNumeric = map[string]bool{"words": true, "chars": true, "hour": true}
+
+var routeVarNames = map[string]bool{
+	"profile": true, "language": true, "class": true, "title": true, "output": true,
+	"words": true, "chars": true, "hour": true, "weekday": true,
+}
+
+// routeTokens splits a routing line into words, numbers, quoted strings
+// and operators.
+func routeTokens(s string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, s[i:j+1])
+			i = j + 1
+		case strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!=") ||
+			strings.HasPrefix(s[i:], ">=") || strings.HasPrefix(s[i:], "<="):
+			toks = append(toks, s[i:i+2])
+			i += 2
+		case strings.ContainsRune("<>~=(),", rune(c)):
+			toks = append(toks, s[i:i+1])
+			i++
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\"<>~=!(),", rune(s[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q", s[i:i+1])
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type routeParser struct {
+	toks []string
+	pos  int
+	r    *route
+}
+
+func (p *routeParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *routeParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *routeParser) expect(t string) error {
+	if got := p.next(); got != t {
+		return fmt.Errorf("expected %q, got %q", t, got)
+	}
+	return nil
+}
+
+// value reads a bare word, number or quoted string.
+func (p *routeParser) value() (string, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return "", fmt.Errorf("missing value")
+	case t[0] == '"':
+		return strconv.Unquote(t)
+	case strings.ContainsAny(t, "<>~=!(),"):
+		return "", fmt.Errorf("expected a value, got %q", t)
+	}
+	return t, nil
+}
+
+func (p *routeParser) or() (func(routeVars) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v routeVars) bool { return l(v) || right(v) }
+	}
+	return left, nil
+}
+
+func (p *routeParser) and() (func(routeVars) bool, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v routeVars) bool { return l(v) && right(v) }
+	}
+	return left, nil
+}
+
+func (p *routeParser) unary() (func(routeVars) bool, error) {
+	switch p.peek() {
+	case "not":
+		p.next()
+		c, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v routeVars) bool { return !c(v) }, nil
+	case "(":
+		p.next()
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+	return p.comparison()
+}
+
+func (p *routeParser) comparison() (func(routeVars) bool, error) {
+	name := p.next()
+	if !routeVarNames[name] {
+		return nil, fmt.Errorf("unknown variable %q", name)
+	}
+	if name == "class" || name == "title" {
+		p.r.window = true
+	}
+	op := p.next()
+	want, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "~":
+		re, err := regexp.Compile("(?i)" + want)
+		if err != nil {
+			return nil, err
+		}
+		return func(v routeVars) bool { return re.MatchString(v[name]) }, nil
+	case "==", "!=":
+		eq := op == "=="
+		return func(v routeVars) bool { return strings.EqualFold(v[name], want) == eq }, nil
+	case "<", "<=", ">", ">=":
+		if !routeNumeric[name] {
+			return nil, fmt.Errorf("%s is not a number", name)
+		}
+		n, err := strconv.Atoi(want)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s %q: not a number", name, op, want)
+		}
+		return func(v routeVars) bool {
+			x, _ := strconv.Atoi(v[name])
+			switch op {
+			case "<":
+				return x < n
+			case "<=":
+				return x <= n
+			case ">":
+				return x > n
+			}
+			return x >= n
+		}, nil
+	}
+	return nil, fmt.Errorf("expected a comparison after %s, got %q", name, op)
+}
+
+// actions reads actions up to "else" or the end of the line.
+func (p *routeParser) actions() ([]routeAction, error) {
+	var acts []routeAction
+	for {
+		key := p.next()
+		switch key {
+		case "disable", "stop":
+			acts = append(acts, routeAction{key: key})
+		case "output", "sink", "template", "suffix", "file":
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			val, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if key == "sink" {
+				key = "output"
+			}
+			if key == "output" && !validOutput(val) {
+				return nil, fmt.Errorf("invalid output mode %q", val)
+			}
+			acts = append(acts, routeAction{key, val})
+		case "":
+			return nil, fmt.Errorf("missing action")
+		default:
+			return nil, fmt.Errorf("unknown action %q", key)
+		}
+		if p.peek() != "," {
+			return acts, nil
+		}
+		p.next()
+	}
+}
+
+// compileRoute parses one routing line.
+func compileRoute(src string) (route, error) {
+	var r route
+	toks, err := routeTokens(src)
+	if err != nil {
+		return r, err
+	}
+	p := &routeParser{toks: toks, r: &r}
+	if p.peek() == "if" {
+		p.next()
+		if r.cond, err = p.or(); err != nil {
+			return r, err
+		}
+		r.hasCond = true
+		if err := p.expect("then"); err != nil {
+			return r, err
+		}
+	}
+	if r.then, err = p.actions(); err != nil {
+		return r, err
+	}
+	if p.peek() == "else" {
+		if !r.hasCond {
+			return r, fmt.Errorf("else without if")
+		}
+		p.next()
+		if r.orElse, err = p.actions(); err != nil {
+			return r, err
+		}
+	}
+	if t := p.peek(); t != "" {
+		return r, fmt.Errorf("unexpected %q", t)
+	}
+	return r, nil
+}
+
+// compileRoutes parses cfg.Routes into cfg.routes.
+func compileRoutes(cfg *Config) error {
+	cfg.routes = nil
+	for _, src := range cfg.Routes {
+		r, err := compileRoute(src)
+		if err != nil {
+			return fmt.Errorf("route %q: %v", src, err)
+		}
+		cfg.routes = append(cfg.routes, r)
+	}
+	return nil
+}
+
+func routesUseWindow(routes []route) bool {
+	for _, r := range routes {
+		if r.window {
+			return true
+		}
+	}
+	return false
+}
+
+// routeOutcome is what the routes decided for one transcript.
+type routeOutcome struct {
+	output, template, suffix, file *string
+	disable                        bool
+}
+
+// evalRoutes runs the routes against one transcript.
+func evalRoutes(routes []route, d sinkData, output string) routeOutcome {
+	now := d.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	vars := routeVars{
+		"profile": d.Profile, "language": d.Language, "class": d.Window.Class, "title": d.Window.Title,
+		"output":  output,
+		"words":   strconv.Itoa(len(strings.Fields(d.Text))),
+		"chars":   strconv.Itoa(len([]rune(d.Text))),
+		"hour":    strconv.Itoa(now.Hour()),
+		"weekday": strings.ToLower(now.Weekday().String()[:3]),
+	}
+	var out routeOutcome
+	for _, r := range routes {
+		acts := r.then
+		if r.hasCond && !r.cond(vars) {
+			acts = r.orElse
+		}
+		for _, a := range acts {
+			v := a.value
+			switch a.key {
+			case "output":
+				out.output = &v
+				vars["output"] = v
+			case "template":
+				out.template = &v
+			case "suffix":
+				out.suffix = &v
+			case "file":
+				out.file = &v
+			case "disable":
+				out.disable = true
+			case "stop":
+				return out
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileRouteErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`if profile == meeting`, `expected "then"`},
+		{`if then output = file`, `unknown variable "then"`},
+		{`if colour == red then stop`, `unknown variable "colour"`},
+		{`if profile meeting then stop`, `expected a comparison`},
+		{`if profile == ) then stop`, `expected a value`},
+		{`if words > many then stop`, `not a number`},
+		{`if title < 3 then stop`, `title is not a number`},
+		{`if class ~ "[" then stop`, `missing closing ]`},
+		{`if (profile == a then stop`, `expected ")"`},
+		{`if class == "slack then stop`, `unterminated string`},
+		{`if profile == a then`, `missing action`},
+		{`if profile == a then explode`, `unknown action "explode"`},
+		{`if profile == a then output file`, `expected "="`},
+		{`if profile == a then output = teleport`, `invalid output mode "teleport"`},
+		{`if profile == a then stop stop`, `unexpected "stop"`},
+		{`stop else disable`, `else without if`},
+		{`if profile == a then stop, `, `missing action`},
+		{`if profile == a! then stop`, `unexpected "!"`},
+	}
+	for _, tt := range tests {
+		_, err := compileRoute(tt.src)
+		if err == nil {
+			t.Errorf("compileRoute(%q) succeeded, want an error containing %q", tt.src, tt.err)
+		} else if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("compileRoute(%q) = %q, want an error containing %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+func TestRouteConditions(t *testing.T) {
+	// a Wednesday evening
+	at := time.Date(2026, 10, 14, 19, 30, 0, 0, time.Local)
+	d := sinkData{Text: "one two three", Profile: "Meeting", Language: "en", Time: at}
+	d.Window.Class = "Slack"
+	d.Window.Title = "general - Slack"
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{`profile == meeting`, true},
+		{`profile != meeting`, false},
+		{`class ~ "slack|discord"`, true},
+		{`title ~ "^random"`, false},
+		{`words == 3`, true},
+		{`words > 2 and chars <= 13`, true},
+		{`words >= 4`, false},
+		{`hour >= 18 and weekday == wed`, true},
+		{`weekday == sat`, false},
+		{`language == "en"`, true},
+		{`output == auto`, true},
+		// and binds tighter than or
+		{`profile == x and words > 100 or class ~ slack`, true},
+		{`class ~ slack or profile == x and words > 100`, true},
+		{`(class ~ slack or profile == x) and words > 100`, false},
+		{`profile == x and (words > 100 or class ~ slack)`, false},
+		// not binds tighter than and
+		{`not profile == x and words == 3`, true},
+		{`not (profile == meeting and words == 3)`, false},
+		{`not not profile == meeting`, true},
+	}
+	for _, tt := range tests {
+		r, err := compileRoute("if " + tt.cond + " then disable")
+		if err != nil {
+			t.Errorf("%s: %v", tt.cond, err)
+			continue
+		}
+		if got := evalRoutes([]route{r}, d, outputAuto).disable; got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestRouteWindow(t *testing.T) {
+	for src, want := range map[string]bool{
+		`if class ~ slack then stop`:                true,
+		`if profile == a or title == "x" then stop`: true,
+		`if words > 3 then stop`:                    false,
+		`output = clipboard`:                        false,
+	} {
+		r, err := compileRoute(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if got := routesUseWindow([]route{r}); got != want {
+			t.Errorf("%s: routesUseWindow = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestEvalRoutes(t *testing.T) {
+	str := func(p *string) string {
+		if p == nil {
+			return "<unset>"
+		}
+		return *p
+	}
+	tests := []struct {
+		name   string
+		routes []string
+		words  int
+		want   string
+	}{
+		{"unconditional", []string{`output = clipboard`}, 1,
+			"output=clipboard template=<unset> suffix=<unset> file=<unset> disable=false"},
+		{"several actions", []string{`if words > 2 then output = file, file = "~/notes.md", suffix = "\n"`}, 3,
+			"output=file template=<unset> suffix=\n file=~/notes.md disable=false"},
+		{"condition fails", []string{`if words > 2 then output = file`}, 1,
+			"output=<unset> template=<unset> suffix=<unset> file=<unset> disable=false"},
+		{"else", []string{`if words > 2 then output = file else output = clipboard, template = "> {{.Text}}"`}, 1,
+			"output=clipboard template=> {{.Text}} suffix=<unset> file=<unset> disable=false"},
+		{"then over else", []string{`if words > 2 then output = file else output = clipboard`}, 5,
+			"output=file template=<unset> suffix=<unset> file=<unset> disable=false"},
+		{"sink alias", []string{`sink = stdout`}, 1,
+			"output=stdout template=<unset> suffix=<unset> file=<unset> disable=false"},
+		{"later routes override", []string{`output = clipboard`, `if words == 1 then output = stdout`}, 1,
+			"output=stdout template=<unset> suffix=<unset> file=<unset> disable=false"},
+		{"stop", []string{`output = clipboard, stop`, `output = stdout, disable`}, 1,
+			"output=clipboard template=<unset> suffix=<unset> file=<unset> disable=false"},
+		{"stop in else", []string{`if words > 9 then disable else stop`, `disable`}, 1,
+			"output=<unset> template=<unset> suffix=<unset> file=<unset> disable=false"},
+		{"disable", []string{`if words > 9 then stop else disable`}, 1,
+			"output=<unset> template=<unset> suffix=<unset> file=<unset> disable=true"},
+		// output set by one route is what the next one sees
+		{"output variable", []string{`output = file`, `if output == file then suffix = "!"`}, 1,
+			"output=file template=<unset> suffix=! file=<unset> disable=false"},
+	}
+	for _, tt := range tests {
+		cfg := Config{Routes: tt.routes}
+		if err := compileRoutes(&cfg); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		d := sinkData{Text: strings.TrimSpace(strings.Repeat("word ", tt.words))}
+		out := evalRoutes(cfg.routes, d, outputAuto)
+		got := "output=" + str(out.output) + " template=" + str(out.template) + " suffix=" + str(out.suffix) +
+			" file=" + str(out.file) + " disable=" + strconv.FormatBool(out.disable)
+		if got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompileRoutesNamesTheLine(t *testing.T) {
+	cfg := Config{Routes: []string{`output = file`, `if bogus == 1 then stop`}}
+	err := compileRoutes(&cfg)
+	if err == nil || !strings.Contains(err.Error(), `"if bogus == 1 then stop"`) {
+		t.Errorf("compileRoutes = %v, want the failing route quoted", err)
+	}
+}
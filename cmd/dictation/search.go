@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// The history JSONL stays the record; history.db is a full-text index over
+// it, kept with the sqlite3 command-line tool (FTS5) and brought up to
+// date before every search. Everything user-supplied reaches sqlite3 as a
+// literal made by sqlQuote.
+
+func historyDBPath() string {
+	return filepath.Join(dataDir(), "history.db")
+}
+
+const historySchema = `CREATE VIRTUAL TABLE IF NOT EXISTS transcripts USING fts5(id UNINDEXED, time UNINDEXED, backend UNINDEXED, text);`
+
+// sqlQuote makes s a SQL string literal. The sqlite3 shell reads its
+// input as C strings, so NULs are spliced in with char(0).
+func sqlQuote(s string) string {
+	parts := strings.Split(s, "\x00")
+	for i, p := range parts {
+		parts[i] = "'" + strings.ReplaceAll(p, "'", "''") + "'"
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, " || char(0) || ") + ")"
+}
+
+// sqlite runs script against the history index and decodes its -json
+// output into out (when non-nil).
+func sqlite(script string, out any) error {
+	if !pathExists("sqlite3") {
+		return errors.New("sqlite3 not found")
+	}
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return err
+	}
+	cmd := exec.Command("sqlite3", "-json", "-bail", historyDBPath())
+	cmd.Stdin = strings.NewReader(historySchema + "\n" + script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("sqlite3: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if out == nil || len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+// syncHistoryIndex adds history entries the index does not have yet and
+// drops those no longer in the history, which were deleted or expired while
+// the index could not be updated.
+func syncHistoryIndex(entries []historyEntry) error {
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := sqlite("SELECT id FROM transcripts;", &rows); err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for _, r := range rows {
+		have[r.ID] = true
+	}
+	var script strings.Builder
+	keep := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		keep[e.ID] = true
+	}
+	for id := range have {
+		if !keep[id] {
+			fmt.Fprintf(&script, "DELETE FROM transcripts WHERE id = %s;\n", sqlQuote(id))
+		}
+	}
+	for _, e := range entries {
+		if have[e.ID] {
+			continue
+		}
+		fmt.Fprintf(&script, "INSERT INTO transcripts VALUES (%s, %s, %s, %s);\n",
+			sqlQuote(e.ID), sqlQuote(e.Time.Format("2006-01-02 15:04")), sqlQuote(e.Provider), sqlQuote(e.Text))
+	}
+	if script.Len() == 0 {
+		return nil
+	}
+	return sqlite("BEGIN;\n"+script.String()+"COMMIT;", nil)
+}
+
+type searchHit struct {
+	ID      string `json:"id"`
+	Time    string `json:"time"`
+	Backend string `json:"backend"`
+	Snippet string `json:"snippet"`
+}
+
+// ftsQuery turns the words of q into an FTS5 query matching all of them,
+// so punctuation in what the user typed is never parsed as syntax.
+func ftsQuery(q string) string {
+	var terms []string
+	for _, w := range strings.Fields(q) {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// runSearch implements `dictate search <query>`: full-text search over the
+// history, best matches first. Without sqlite3 it falls back to a plain
+// scan of the history file.
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	n := fs.Int("n", 20, "maximum number of results")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		return exitError{exitUsage, errors.New("usage: dictate search [-n N] <query>")}
+	}
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	var hits []searchHit
+	if pathExists("sqlite3") {
+		if err := syncHistoryIndex(entries); err != nil {
+			return err
+		}
+		err = sqlite(fmt.Sprintf(`SELECT id, time, backend, snippet(transcripts, 3, '[', ']', '…', 12) AS snippet
+FROM transcripts WHERE transcripts MATCH %s ORDER BY rank LIMIT %d;`, sqlQuote(ftsQuery(query)), *n), &hits)
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "sqlite3 not found; scanning the history file instead")
+		words := strings.Fields(strings.ToLower(query))
+		for i := len(entries) - 1; i >= 0 && len(hits) < *n; i-- {
+			e := entries[i]
+			text := strings.ToLower(e.Text)
+			all := true
+			for _, w := range words {
+				all = all && strings.Contains(text, w)
+			}
+			if all {
+				hits = append(hits, searchHit{ID: e.ID, Time: e.Time.Format("2006-01-02 15:04"), Backend: e.Provider, Snippet: e.Text})
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return exitError{exitEmpty, errors.New("no matches")}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "ID\tTIME\tTEXT")
+	for _, h := range hits {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", h.ID, h.Time, strings.Join(strings.Fields(h.Snippet), " "))
+	}
+	return nil
+}
+
+// deleteHistory removes the entries with the given ids from the history
+// file and the search index.
+func deleteHistory(ids []string) error {
+	want := map[string]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	removed, err := pruneHistory(func(e historyEntry) bool { return want[e.ID] })
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		return exitError{exitUsage, fmt.Errorf("no history entry %s", strings.Join(ids, ", "))}
+	}
+	return nil
+}
+
+// pruneHistory removes the entries drop reports true for from the history
+// file and the search index, and returns their ids.
+func pruneHistory(drop func(historyEntry) bool) ([]string, error) {
+	unlock, err := lockFile(historyPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	entries, err := loadHistory()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	var removed []string
+	for _, e := range entries {
+		if drop(e) {
+			removed = append(removed, e.ID)
+			continue
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(append(b, '\n'))
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	if err := writeFileAtomic(historyPath(), buf.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(historyDBPath()); err != nil {
+		return removed, nil
+	}
+	if !pathExists("sqlite3") {
+		// the next search rebuilds the index without the removed entries
+		if err := os.Remove(historyDBPath()); err != nil {
+			return removed, fmt.Errorf("history.db still has the removed entries: %v", err)
+		}
+		return removed, nil
+	}
+	quoted := make([]string, len(removed))
+	for i, id := range removed {
+		quoted[i] = sqlQuote(id)
+	}
+	return removed, sqlite("DELETE FROM transcripts WHERE id IN ("+strings.Join(quoted, ", ")+");", nil)
+}
+
+// exportHistory prints the full text of the entries with the given ids,
+// or the entries themselves as JSON lines.
+func exportHistory(ids []string, asJSON bool) error {
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	byID := map[string]historyEntry{}
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, id := range ids {
+		e, ok := byID[id]
+		if !ok {
+			return exitError{exitUsage, fmt.Errorf("no history entry %s", id)}
+		}
+		if asJSON {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(os.Stdout, e.Text)
+	}
+	return nil
+}
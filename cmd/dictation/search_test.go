@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestSQLQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "''"},
+		{"plain", "'plain'"},
+		{"it's", "'it''s'"},
+		{"''", "''''''"},
+		{`back\slash "double"`, `'back\slash "double"'`},
+		{"two\nlines\r\n", "'two\nlines\r\n'"},
+		{"a\x00b", "('a' || char(0) || 'b')"},
+		{"\x00'\x00", "('' || char(0) || '''' || char(0) || '')"},
+	}
+	for _, tt := range tests {
+		if got := sqlQuote(tt.in); got != tt.want {
+			t.Errorf("sqlQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	for in, want := range map[string]string{
+		"hello world":     `"hello" "world"`,
+		`say "hi" NEAR x`: `"say" """hi""" "NEAR" "x"`,
+		"  ":              "",
+	} {
+		if got := ftsQuery(in); got != want {
+			t.Errorf("ftsQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// The texts go through the real sqlite3 shell and must come back intact.
+func TestHistoryIndexRoundTrip(t *testing.T) {
+	if !pathExists("sqlite3") {
+		t.Skip("sqlite3 not installed")
+	}
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	texts := map[string]string{
+		"quote":   "it's 'quoted'",
+		"inject":  "x'); DROP TABLE transcripts; --",
+		"lines":   "first line\n.tables\nsecond; line",
+		"nul":     "before\x00after",
+		"unicode": "naïve café — 東京",
+	}
+	var entries []historyEntry
+	for id, text := range texts {
+		entries = append(entries, historyEntry{ID: id, Time: time.Now(), Provider: "p'q", Text: text})
+	}
+	if err := syncHistoryIndex(entries); err != nil {
+		t.Fatal(err)
+	}
+	var rows []struct {
+		ID      string `json:"id"`
+		Backend string `json:"backend"`
+		Hex     string `json:"hex"`
+	}
+	if err := sqlite("SELECT id, backend, hex(text) AS hex FROM transcripts;", &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(texts) {
+		t.Fatalf("%d rows, want %d", len(rows), len(texts))
+	}
+	for _, r := range rows {
+		got, err := hex.DecodeString(r.Hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := texts[r.ID]; string(got) != want || r.Backend != "p'q" {
+			t.Errorf("%s: got %q from %q, want %q", r.ID, got, r.Backend, want)
+		}
+	}
+
+	// entries gone from the history leave the index
+	if err := syncHistoryIndex(entries[:1]); err != nil {
+		t.Fatal(err)
+	}
+	rows = nil
+	if err := sqlite("SELECT id FROM transcripts;", &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].ID != entries[0].ID {
+		t.Errorf("after dropping entries the index has %+v", rows)
+	}
+}
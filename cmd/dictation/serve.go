@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// `dictate serve` offers transcription, and the microphone of this machine,
+// over HTTP, for other machines or a phone on the LAN:
+//
+//	POST /v1/transcribe   audio in the body (or a multipart "file" field),
+//	                      ?name=memo.ogg for its format, ?profile=NAME;
+//	                      returns the `dictate transcribe --json` object
+//	POST /v1/start        start recording here (?profile=NAME)
+//	POST /v1/stop         stop, transcribe and insert; returns the transcript
+//	POST /v1/cancel       discard the recording
+//	GET  /v1/status       {"state": "idle", "elapsed": 0}
+//	GET  /v1/stream       WebSocket streaming transcription; see stream.go
+//
+// Clients send "Authorization: Bearer TOKEN", or ?token=TOKEN where they
+// cannot set headers, as browsers opening a WebSocket.
+
+// maxUpload bounds the audio a client may post.
+const maxUpload = 200 << 20
+
+// audioExtensions gives uploads without a name an extension the providers
+// recognise.
+var audioExtensions = map[string]string{
+	"audio/wav": ".wav", "audio/x-wav": ".wav", "audio/wave": ".wav",
+	"audio/mpeg": ".mp3", "audio/mp3": ".mp3", "audio/mp4": ".m4a", "audio/x-m4a": ".m4a",
+	"audio/ogg": ".ogg", "audio/opus": ".ogg", "audio/webm": ".webm", "audio/flac": ".flac",
+}
+
+type server struct {
+	cfg   Config
+	token string
+	// mu serialises the recording endpoints, as the daemon's lock does.
+	mu sync.Mutex
+}
+
+// runServe implements `dictate serve [--http ADDR] [--token T]`.
+func runServe(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("http", "127.0.0.1:8080", "address to listen on, e.g. :8080 for the whole LAN")
+	token := fs.String("token", "", "token clients must send (default serve_token or $DICTATION_SERVE_TOKEN)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if fs.NArg() != 0 {
+		return exitError{exitUsage, errors.New("usage: dictate serve [--http ADDR] [--token T]")}
+	}
+	s := &server{cfg: cfg, token: *token}
+	if s.token == "" {
+		s.token = cfg.ServeToken
+	}
+	if s.token == "" {
+		s.token = os.Getenv("DICTATION_SERVE_TOKEN")
+	}
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return exitError{exitUsage, err}
+	}
+	if ip := net.ParseIP(host); s.token == "" && host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return exitError{exitUsage, errors.New("serving beyond this machine needs a token (--token, serve_token or $DICTATION_SERVE_TOKEN)")}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/transcribe", s.handleTranscribe)
+	mux.HandleFunc("/v1/start", s.handleStart)
+	mux.HandleFunc("/v1/stop", s.handleStop)
+	mux.HandleFunc("/v1/cancel", s.handleCancel)
+	mux.HandleFunc("/v1/status", s.handleStatus)
+	mux.HandleFunc("/v1/stream", s.handleStream)
+	mux.HandleFunc("/metrics", handleMetrics)
+	srv := &http.Server{Addr: *addr, Handler: s.authorize(mux), ReadHeaderTimeout: 10 * time.Second}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		srv.Close()
+	}()
+	fmt.Fprintln(os.Stderr, "dictation server listening on", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if got == "" {
+				got = r.URL.Query().Get("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				httpError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// upload saves the posted audio to a temporary file and returns its path
+// and the name to report.
+func upload(r *http.Request) (string, string, error) {
+	body := io.Reader(r.Body)
+	name := r.URL.Query().Get("name")
+	ctype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ctype == "multipart/form-data" {
+		f, h, err := r.FormFile("file")
+		if err != nil {
+			return "", "", err
+		}
+		defer f.Close()
+		body = f
+		if name == "" {
+			name = h.Filename
+		}
+	}
+	if name == "" {
+		name = "audio" + audioExtensions[ctype]
+	}
+	if filepath.Ext(name) == "" {
+		return "", "", errors.New("unknown audio format: pass ?name=file.ext or an audio Content-Type")
+	}
+	f, err := os.CreateTemp(runtimeDir(), "dictation-upload-*"+filepath.Ext(name))
+	if err != nil {
+		return "", "", err
+	}
+	_, err = io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", "", err
+	}
+	return f.Name(), filepath.Base(name), nil
+}
+
+func (s *server) handleTranscribe(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+	cfg := s.cfg
+	if p := r.URL.Query().Get("profile"); p != "" {
+		if err := applyProfile(&cfg, p); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	path, name, err := upload(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer os.Remove(path)
+	start := time.Now()
+	res, err := transcribeFile(r.Context(), cfg, path)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err)
+		return
+	}
+	ppStart := time.Now()
+	out := newTranscribeResult(cfg, path, postProcess(cfg, res.Text), res, start, ppStart)
+	out.File = name
+	writeJSON(w, out)
+}
+
+func (s *server) handleStart(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile := r.URL.Query().Get("profile")
+	if _, ok := s.cfg.Profiles[profile]; profile != "" && !ok {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("unknown profile %q", profile))
+		return
+	}
+	if _, recording := recordingSince(); recording {
+		httpError(w, http.StatusConflict, errors.New("already recording"))
+		return
+	}
+	if err := toggleWith(s.cfg, "", profile); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, recording := recordingSince(); !recording {
+		httpError(w, http.StatusConflict, errors.New("not recording"))
+		return
+	}
+	before := lastTranscriptID()
+	if err := toggle(s.cfg); err != nil {
+		var ee exitError
+		if errors.As(err, &ee) && ee.code == exitEmpty {
+			httpError(w, http.StatusNotFound, err)
+			return
+		}
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	t, err := loadLastTranscript()
+	if err != nil || t.ID == before {
+		httpError(w, http.StatusNotFound, errors.New("the recording made no transcript"))
+		return
+	}
+	writeJSON(w, t)
+}
+
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	// a stop that is still transcribing holds the lock
+	if abortTranscription(errCancelled) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := cancelRecording(); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	st := currentStatus()
+	writeJSON(w, map[string]any{"state": st.State, "elapsed": int(st.elapsed().Seconds())})
+}
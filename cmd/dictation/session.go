@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/insert"
+)
+
+// A session collects the dictations made between `dictate start-session`
+// and `dictate end-session` into one document instead of typing each one,
+// for long texts dictated in pieces.
+
+type session struct {
+	Title   string    `json:"title,omitempty"`
+	Started time.Time `json:"started"`
+	File    string    `json:"file"`
+	Pieces  int       `json:"pieces"`
+}
+
+func sessionPath() string {
+	return filepath.Join(stateDir(), "session.json")
+}
+
+// activeSession returns the session that is going on, if any.
+func activeSession() (session, bool) {
+	var s session
+	b, err := os.ReadFile(sessionPath())
+	if err != nil {
+		return s, false
+	}
+	return s, json.Unmarshal(b, &s) == nil && s.File != ""
+}
+
+func writeSession(s session) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(sessionPath(), b, 0600)
+}
+
+// sessionFile names a new session's document in the data directory.
+func sessionFile(title string, started time.Time) string {
+	name := started.Format("2006-01-02-150405")
+	if slug := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, title), "-"); slug != "" {
+		name += "-" + slug
+	}
+	return filepath.Join(dataDir(), "sessions", name+".md")
+}
+
+// addToSession appends a dictation to the session's document, separated
+// from what is there by session_separator.
+func addToSession(cfg Config, text string) (session, error) {
+	unlock, err := lockFile(sessionPath())
+	if err != nil {
+		return session{}, err
+	}
+	defer unlock()
+	s, ok := activeSession()
+	if !ok {
+		return s, errors.New("no session")
+	}
+	sep := cfg.SessionSeparator
+	if sep == "" {
+		sep = "\n\n"
+	}
+	// the document may have text from before the session too
+	if info, err := os.Stat(s.File); err == nil && info.Size() > 0 {
+		text = sep + text
+	}
+	f, err := os.OpenFile(s.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return s, err
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return s, err
+	}
+	if err := f.Close(); err != nil {
+		return s, err
+	}
+	s.Pieces++
+	return s, writeSession(s)
+}
+
+// runStartSession implements `dictate start-session [--file F] [title]`.
+func runStartSession(args []string) error {
+	fs := flag.NewFlagSet("start-session", flag.ContinueOnError)
+	file := fs.String("file", "", "document to append to (default: a new file in the data directory's sessions folder)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if fs.NArg() > 1 {
+		return exitError{exitUsage, errors.New("usage: dictate start-session [--file F] [title]")}
+	}
+	unlock, err := lockFile(sessionPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if s, ok := activeSession(); ok {
+		return fmt.Errorf("a session is already going on (%s); end it with `dictate end-session`", s.File)
+	}
+	s := session{Title: fs.Arg(0), Started: time.Now()}
+	s.File = sessionFile(s.Title, s.Started)
+	if *file != "" {
+		if s.File, err = filepath.Abs(expandHome(*file)); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.File), 0755); err != nil {
+		return err
+	}
+	if err := writeSession(s); err != nil {
+		return err
+	}
+	fmt.Println(s.File)
+	notifyUser("Dictation", "Session started — dictations go to "+filepath.Base(s.File))
+	return nil
+}
+
+// runEndSession implements `dictate end-session [--copy|--insert]`: it
+// closes the session and prints its document's path.
+func runEndSession(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("end-session", flag.ContinueOnError)
+	toClipboard := fs.Bool("copy", false, "also copy the whole document to the clipboard")
+	toWindow := fs.Bool("insert", false, "also insert the whole document into the focused window")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if fs.NArg() != 0 {
+		return exitError{exitUsage, errors.New("usage: dictate end-session [--copy|--insert]")}
+	}
+	unlock, err := lockFile(sessionPath())
+	if err != nil {
+		return err
+	}
+	s, ok := activeSession()
+	if !ok {
+		unlock()
+		return exitError{exitEmpty, errors.New("no session is going on")}
+	}
+	err = os.Remove(sessionPath())
+	unlock()
+	if err != nil {
+		return err
+	}
+	fmt.Println(s.File)
+	notifyUser("Dictation", fmt.Sprintf("Session ended — %d dictations in %s", s.Pieces, filepath.Base(s.File)))
+	if !*toClipboard && !*toWindow {
+		return nil
+	}
+	b, err := os.ReadFile(s.File)
+	if os.IsNotExist(err) {
+		return exitError{exitEmpty, errors.New("nothing was dictated in the session")}
+	}
+	if err != nil {
+		return err
+	}
+	text := string(b)
+	if *toClipboard {
+		if err := insert.Copy(text); err != nil {
+			return err
+		}
+	}
+	if *toWindow {
+		if text, err = prepareOutput(&cfg, text, ""); err != nil {
+			return err
+		}
+		_, err = insertText(cfg, text)
+		return err
+	}
+	return nil
+}
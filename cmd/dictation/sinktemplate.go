@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// sinkData is what output templates (output_template, an app rule's
+// template, and output_file) can refer to.
+type sinkData struct {
+	Text   string
+	Window struct {
+		Class string
+		Title string
+	}
+	Profile  string
+	Language string
+	Time     time.Time
+}
+
+var nonSlug = regexp.MustCompile(`[^\pL\pN]+`)
+
+var sinkFuncs = template.FuncMap{
+	"env":   os.Getenv,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	// slug makes s safe as a file name: "Notes — Draft.md" becomes
+	// "notes-draft-md".
+	"slug": func(s string) string {
+		return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	},
+	// date formats the time strftime-style, like output_timestamp.
+	"date": func(format string, t time.Time) string { return strftime(format, t) },
+}
+
+// expandSink executes tmpl with d. Text without "{{" is returned as is.
+func expandSink(name, tmpl string, d sinkData) (string, error) {
+	if !strings.Contains(tmpl, "{{") {
+		return tmpl, nil
+	}
+	t, err := template.New(name).Funcs(sinkFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("%s: bad template: %v", name, err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return b.String(), nil
+}
+
+var errTypingDisabled = errors.New("typing is disabled for this window")
+
+// prepareOutput applies the app rule matching the focused window, the
+// routes and the output template to text, returning what to deliver. Rules
+// and routes may change cfg.Output and cfg.OutputFile; the template data
+// is kept in cfg for output_file.
+func prepareOutput(cfg *Config, text, language string) (string, error) {
+	d := sinkData{Text: text, Profile: cfg.Profile, Language: language, Time: time.Now()}
+	if d.Language == "" {
+		d.Language = cfg.Language
+	}
+	tmpl, suffix := cfg.OutputTemplate, ""
+	haveWindow := len(cfg.AppRules) > 0 || routesUseWindow(cfg.routes)
+	if haveWindow {
+		d.Window.Class, d.Window.Title, _ = focusedWindow()
+	}
+	for _, r := range cfg.AppRules {
+		if !r.matches(d.Window.Class, d.Window.Title) {
+			continue
+		}
+		if r.Disable {
+			return "", errTypingDisabled
+		}
+		if r.Output != "" && !cfg.outputFromFlag {
+			cfg.Output = r.Output
+		}
+		if r.Template != "" {
+			tmpl = r.Template
+		}
+		suffix = r.Suffix
+		break
+	}
+	if len(cfg.routes) > 0 {
+		o := evalRoutes(cfg.routes, d, cfg.Output)
+		if o.disable {
+			return "", errTypingDisabled
+		}
+		if o.output != nil && !cfg.outputFromFlag {
+			cfg.Output = *o.output
+		}
+		if o.template != nil {
+			tmpl = *o.template
+		}
+		if o.suffix != nil {
+			suffix = *o.suffix
+		}
+		if o.file != nil {
+			cfg.OutputFile = *o.file
+		}
+	}
+	// a rule or route may have brought in a template that wants the window
+	if !haveWindow && strings.Contains(tmpl+cfg.OutputFile, ".Window") {
+		d.Window.Class, d.Window.Title, _ = focusedWindow()
+	}
+	cfg.sink = d
+	if cfg.Hooks.BeforeInsert != "" {
+		var err error
+		if text, err = runBeforeInsert(*cfg, text); err != nil {
+			return "", err
+		}
+		d.Text = text
+		cfg.sink = d
+	}
+	if tmpl != "" {
+		var err error
+		if text, err = expandSink("output_template", tmpl, d); err != nil {
+			return "", err
+		}
+	}
+	return text + suffix, nil
+}
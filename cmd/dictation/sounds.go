@@ -0,0 +1,157 @@
+package main
+
+import (
+	"embed"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/user/dictation/audio"
+)
+
+// defaultSounds are the start and stop sounds used when none is
+// configured.
+//
+//go:embed on.mp3 off.mp3
+var defaultSounds embed.FS
+
+// SoundTheme configures the audio cues beyond the start/stop pips, for
+// eyes-free use.
+type SoundTheme struct {
+	// Ticks plays a short, quiet tick every TickInterval seconds (default
+	// 2) while a transcription is in flight.
+	Ticks        bool    `json:"ticks"`
+	TickInterval float64 `json:"tick_interval"`
+	// Done plays a rising tone once the text is delivered, a falling low
+	// one when transcription or insertion failed and two flat beeps when
+	// nothing was recognised.
+	Done bool `json:"done"`
+	// Start and Stop are sound files played when recording starts and
+	// stops; Inserted, Error and Nothing replace the Done tones. Names
+	// without a directory are looked up in the config directory's sounds
+	// folder, then the config directory.
+	Start    string `json:"start"`
+	Stop     string `json:"stop"`
+	Inserted string `json:"inserted"`
+	Error    string `json:"error"`
+	Nothing  string `json:"nothing"`
+	// ToneHz and ToneMS shape the beep played when no start or stop sound
+	// can be played (default 220 Hz for 90 ms).
+	ToneHz float64 `json:"tone_hz"`
+	ToneMS int     `json:"tone_ms"`
+	// Volume is in percent of the sounds' own level (100 by default).
+	Volume int `json:"volume"`
+}
+
+// gain is the volume as a factor.
+func (s SoundTheme) gain() float64 {
+	return float64(max(s.Volume, 0)) / 100
+}
+
+// tone is a beep at the configured volume.
+func (s SoundTheme) tone(freqHz, seconds float64) []byte {
+	return audio.Tone(freqHz, seconds, s.gain())
+}
+
+// soundPath finds the sound file name refers to, or "" if there is none.
+func soundPath(name string) string {
+	if name == "" {
+		return ""
+	}
+	name = expandHome(name)
+	if filepath.IsAbs(name) || filepath.Base(name) != name {
+		if isRegularFile(name) {
+			return name
+		}
+		return ""
+	}
+	for _, dir := range []string{filepath.Join(configDir(), "sounds"), configDir()} {
+		if p := filepath.Join(dir, name); isRegularFile(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+func isRegularFile(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && !fi.IsDir()
+}
+
+// defaultSoundPath unpacks one of defaultSounds into the cache directory,
+// where the players can read it.
+func defaultSoundPath(name string) (string, error) {
+	p := filepath.Join(cacheDir(), "sounds", name)
+	if isRegularFile(p) {
+		return p, nil
+	}
+	b, err := defaultSounds.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return "", err
+	}
+	return p, writeFileAtomic(p, b, 0600)
+}
+
+// playSoundFile plays path at the configured volume.
+func playSoundFile(s SoundTheme, path string) error {
+	return audio.PlayFile(path, s.gain())
+}
+
+// startTicks plays the progress tick until the returned function is
+// called.
+func startTicks(cfg Config) func() {
+	if !cfg.Sounds.Ticks {
+		return func() {}
+	}
+	interval := time.Duration(cfg.Sounds.TickInterval * float64(time.Second))
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	tick := cfg.Sounds.tone(1400, 0.015)
+	stop := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				_ = audio.Play(tick)
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// Outcomes of a dictation, each with its own cue.
+const (
+	cueInserted = "inserted"
+	cueFailed   = "failed"
+	cueNothing  = "nothing"
+)
+
+// playCue plays the cue for how the dictation ended: its sound file if one
+// is set, else, with done, its tones.
+func playCue(cfg Config, cue string) {
+	file, freqs := cfg.Sounds.Inserted, []float64{660, 990}
+	switch cue {
+	case cueFailed:
+		file, freqs = cfg.Sounds.Error, []float64{300, 200}
+	case cueNothing:
+		// 0 Hz is a pause between the beeps
+		file, freqs = cfg.Sounds.Nothing, []float64{440, 0, 440}
+	}
+	if p := soundPath(file); p != "" && playSoundFile(cfg.Sounds, p) == nil {
+		return
+	}
+	if !cfg.Sounds.Done {
+		return
+	}
+	for _, f := range freqs {
+		_ = audio.Play(cfg.Sounds.tone(f, 0.08))
+	}
+}
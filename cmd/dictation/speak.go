@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/audio"
+	"github.com/user/dictation/insert"
+	"github.com/user/dictation/provider"
+)
+
+const openAISpeechURL = "https://api.openai.com/v1/audio/speech"
+
+// ttsProvider is the speech endpoint used when "tts" names a provider,
+// with OpenAI defaults for whatever is left empty.
+func ttsProvider(cfg Config) provider.Provider {
+	return cfg.TTS.WithDefaults(provider.Provider{
+		URL:       openAISpeechURL,
+		Model:     "tts-1",
+		APIKeyEnv: "OPENAI_API_KEY",
+	})
+}
+
+// speak reads text aloud: through the system's speech synthesizer by
+// default, or the configured TTS provider.
+func speak(cfg Config, text string) error {
+	if cfg.TTS.Name != "" && cfg.TTS.Name != "system" {
+		b, err := synthesize(cfg, text)
+		if err != nil {
+			return err
+		}
+		return audio.Play(b)
+	}
+	var cmd *exec.Cmd
+	switch {
+	case isMac:
+		cmd = exec.Command("say", "-f", "-")
+	case isWindows:
+		cmd = powershell(`Add-Type -AssemblyName System.Speech; [Console]::InputEncoding = [Text.Encoding]::UTF8; (New-Object System.Speech.Synthesis.SpeechSynthesizer).Speak([Console]::In.ReadToEnd())`)
+	case pathExists("espeak-ng"), pathExists("espeak"):
+		name := "espeak-ng"
+		if !pathExists(name) {
+			name = "espeak"
+		}
+		args := []string{"--stdin"}
+		if cfg.Language != "" {
+			args = append(args, "-v", cfg.Language)
+		}
+		cmd = exec.Command(name, args...)
+	case pathExists("spd-say"):
+		args := []string{"--wait", "-e"}
+		if cfg.Language != "" {
+			args = append(args, "-l", cfg.Language)
+		}
+		cmd = exec.Command("spd-say", args...)
+	default:
+		return errors.New("no speech synthesizer found; install espeak-ng or speech-dispatcher, or configure \"tts\"")
+	}
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// synthesize asks the TTS provider for text as WAV audio.
+func synthesize(cfg Config, text string) ([]byte, error) {
+	p := ttsProvider(cfg)
+	key, err := providerKey(cfg, p)
+	if err != nil {
+		return nil, err
+	}
+	url, err := p.Endpoint()
+	if err != nil {
+		return nil, err
+	}
+	voice := cfg.TTSVoice
+	if voice == "" {
+		voice = "alloy"
+	}
+	reqBody, err := json.Marshal(map[string]string{
+		"model":           p.Model,
+		"voice":           voice,
+		"input":           text,
+		"response_format": "wav",
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = p.Header(key)
+	req.Header.Set("Content-Type", "application/json")
+
+	cli := &http.Client{Timeout: 60 * time.Second}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s error: %s", p.Name, string(body))
+	}
+	return body, nil
+}
+
+// runSpeakSelection implements `dictate speak-selection`: it reads the
+// selected text aloud, e.g. to proofread a dictation by ear.
+func runSpeakSelection(cfg Config) error {
+	sel, err := insert.Selection()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(sel) == "" {
+		return exitError{exitEmpty, errors.New("nothing selected")}
+	}
+	if err := speak(cfg, sel); err != nil {
+		notifyUser("Dictation", "Could not read the selection aloud: "+err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Transcript is a single dictation result as kept on disk.
+type Transcript struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+	// Provider and Model record what produced Text, so results from
+	// different machines and backends can be told apart.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Corrected is the text submitted by a correction UI, if any.
+	Corrected string `json:"corrected,omitempty"`
+	// Inserted is what was typed into the target window, suffix included,
+	// so voice commands can take it back.
+	Inserted string `json:"inserted,omitempty"`
+	// Words holds word timings when word timestamps are enabled.
+	Words []Word `json:"words,omitempty"`
+}
+
+func newTranscript(text string) Transcript {
+	now := time.Now()
+	return Transcript{
+		ID:   strconv.FormatInt(now.UnixNano(), 36),
+		Time: now,
+		Text: text,
+	}
+}
+
+// FinalText is the corrected text when there is one, else the original.
+func (t Transcript) FinalText() string {
+	if t.Corrected != "" {
+		return t.Corrected
+	}
+	return t.Text
+}
+
+// stateDir is where persistent runtime state (last transcript etc.) lives.
+func stateDir() string {
+	if portableDir != "" {
+		return portableDir
+	}
+	if d := os.Getenv("XDG_STATE_HOME"); d != "" {
+		return filepath.Join(d, "dictation")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "dictation")
+}
+
+// dataDir is where user data that is worth keeping (the transcript
+// history) lives.
+func dataDir() string {
+	if portableDir != "" {
+		return portableDir
+	}
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return filepath.Join(d, "dictation")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "dictation")
+}
+
+// audioArchiveDir is where keep_audio puts transcribed recordings.
+func audioArchiveDir(cfg Config) string {
+	if cfg.AudioArchiveDir != "" {
+		return expandHome(cfg.AudioArchiveDir)
+	}
+	return filepath.Join(dataDir(), "audio")
+}
+
+// cacheDir is for files that can be lost without harm, like recordings in
+// progress.
+func cacheDir() string {
+	if portableDir != "" {
+		return portableDir
+	}
+	if d := os.Getenv("XDG_CACHE_HOME"); d != "" {
+		return filepath.Join(d, "dictation")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "dictation")
+}
+
+// recordingsDir holds the recording toggle writes and any not yet
+// transcribed.
+func recordingsDir() string {
+	return filepath.Join(cacheDir(), "recordings")
+}
+
+// runtimeDir is where sockets and other per-session files live.
+func runtimeDir() string {
+	if portableDir != "" {
+		return portableDir
+	}
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		return d
+	}
+	return os.TempDir()
+}
+
+// toggleLockPath is locked (as toggleLockPath()+".lock") while a toggle
+// runs.
+func toggleLockPath() string {
+	return filepath.Join(runtimeDir(), "dictation-toggle")
+}
+
+// recorderPidPath is the pidfile of the recorder toggle started.
+func recorderPidPath() string {
+	return filepath.Join(runtimeDir(), "dictation-recorder.pid")
+}
+
+func lastTranscriptPath() string {
+	return filepath.Join(stateDir(), "last.json")
+}
+
+// lastAudioPath is where the recording behind the last transcript is kept
+// when word timestamps are enabled.
+func lastAudioPath() string {
+	return filepath.Join(stateDir(), "last.wav")
+}
+
+// keepLastAudio moves wav to lastAudioPath, copying when a rename is not
+// possible (e.g. across filesystems).
+func keepLastAudio(wav string) error {
+	dst := lastAudioPath()
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(wav, dst); err == nil {
+		return nil
+	}
+	b, err := os.ReadFile(wav)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(dst, b, 0600); err != nil {
+		return err
+	}
+	return os.Remove(wav)
+}
+
+func loadLastTranscript() (Transcript, error) {
+	var t Transcript
+	b, err := os.ReadFile(lastTranscriptPath())
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal(b, &t)
+	return t, err
+}
+
+func saveLastTranscript(t Transcript) error {
+	unlock, err := lockFile(lastTranscriptPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeLastTranscript(t)
+}
+
+func writeLastTranscript(t Transcript) error {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(lastTranscriptPath(), b, 0600)
+}
+
+// updateLastTranscript applies fn to the last transcript as one transaction:
+// no other process can replace the transcript between the read and the
+// write. Nothing is written if fn returns an error.
+func updateLastTranscript(fn func(*Transcript) error) (Transcript, error) {
+	unlock, err := lockFile(lastTranscriptPath())
+	if err != nil {
+		return Transcript{}, err
+	}
+	defer unlock()
+	t, err := loadLastTranscript()
+	if err != nil {
+		return t, err
+	}
+	if err := fn(&t); err != nil {
+		return t, err
+	}
+	return t, writeLastTranscript(t)
+}
+
+// markInserted records text as what the transcript id put into the focused
+// window, unless a newer transcript has replaced it meanwhile.
+func markInserted(id, text string) {
+	_, err := updateLastTranscript(func(last *Transcript) error {
+		if last.ID == id {
+			last.Inserted = text
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not save transcript:", err)
+	}
+}
+
+// writeFileAtomic writes to a temporary file next to path and renames it
+// into place, so readers never see a half-written file.
+func writeFileAtomic(path string, b []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
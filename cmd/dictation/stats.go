@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// providerStats accumulates how one provider has done for this user.
+type providerStats struct {
+	Requests int `json:"requests"`
+	Failures int `json:"failures"`
+	// LatencyMS is the summed round trip of successful requests.
+	LatencyMS int64 `json:"latency_ms"`
+	// Words is the number of words transcribed successfully.
+	Words int `json:"words"`
+	// Corrections counts transcripts the user corrected, EditedWords the
+	// word-level edits those corrections needed.
+	Corrections int `json:"corrections"`
+	EditedWords int `json:"edited_words"`
+}
+
+func (s *providerStats) successes() int { return s.Requests - s.Failures }
+
+func (s *providerStats) avgLatency() time.Duration {
+	if s.successes() == 0 {
+		return 0
+	}
+	return time.Duration(s.LatencyMS/int64(s.successes())) * time.Millisecond
+}
+
+// correctionRate is edited words per transcribed word, a rough WER proxy.
+func (s *providerStats) correctionRate() float64 {
+	if s.Words == 0 {
+		return 0
+	}
+	return float64(s.EditedWords) / float64(s.Words)
+}
+
+func (s *providerStats) failureRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Requests)
+}
+
+// score is lower-is-better: correction rate dominates, failures count as
+// fully wrong transcripts, and every second of latency weighs like 2% WER.
+func (s *providerStats) score() float64 {
+	return s.correctionRate() + s.failureRate() + 0.02*s.avgLatency().Seconds()
+}
+
+func statsPath() string {
+	return filepath.Join(stateDir(), "stats.json")
+}
+
+func loadStats() (map[string]*providerStats, error) {
+	stats := map[string]*providerStats{}
+	b, err := os.ReadFile(statsPath())
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// updateStats applies fn to the stats for provider under the state lock.
+func updateStats(provider string, fn func(*providerStats)) error {
+	unlock, err := lockFile(statsPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	stats, err := loadStats()
+	if err != nil {
+		return err
+	}
+	s := stats[provider]
+	if s == nil {
+		s = &providerStats{}
+		stats[provider] = s
+	}
+	fn(s)
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(statsPath(), b, 0600)
+}
+
+func recordRequest(provider string, d time.Duration, text string, reqErr error) {
+	err := updateStats(provider, func(s *providerStats) {
+		s.Requests++
+		if reqErr != nil {
+			s.Failures++
+			return
+		}
+		s.LatencyMS += d.Milliseconds()
+		s.Words += len(strings.Fields(text))
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not update stats:", err)
+	}
+}
+
+func recordCorrection(provider, original, corrected string) {
+	if provider == "" {
+		return
+	}
+	err := updateStats(provider, func(s *providerStats) {
+		s.Corrections++
+		s.EditedWords += wordEdits(original, corrected)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not update stats:", err)
+	}
+}
+
+// wordEdits is the word-level Levenshtein distance between a and b,
+// ignoring case.
+func wordEdits(a, b string) int {
+	x := strings.Fields(strings.ToLower(a))
+	y := strings.Fields(strings.ToLower(b))
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		cur[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(y)]
+}
+
+const (
+	// routeMinSamples successful requests are collected from every
+	// provider before the scores are trusted.
+	routeMinSamples = 5
+	// routeExplore is the share of requests sent to a random provider so
+	// the stats keep up with changes.
+	routeExplore = 0.1
+)
+
+// routeProvider picks a provider epsilon-greedily from the stats. Only
+// providers that have a key configured take part. It returns "" when
+// there is nothing to choose from.
+func routeProvider(cfg Config) string {
+	var names []string
+	for _, p := range providerList(cfg) {
+		if providerHasKey(cfg, p) {
+			names = append(names, p.Name)
+		}
+	}
+	if len(names) < 2 {
+		return ""
+	}
+	stats, err := loadStats()
+	if err != nil {
+		return ""
+	}
+	get := func(n string) *providerStats {
+		if s := stats[n]; s != nil {
+			return s
+		}
+		return &providerStats{}
+	}
+	// explore: least-sampled first, then at random now and then
+	sort.SliceStable(names, func(i, j int) bool { return get(names[i]).successes() < get(names[j]).successes() })
+	if get(names[0]).successes() < routeMinSamples {
+		return names[0]
+	}
+	if rand.Float64() < routeExplore {
+		return names[rand.Intn(len(names))]
+	}
+	sort.SliceStable(names, func(i, j int) bool { return get(names[i]).score() < get(names[j]).score() })
+	return names[0]
+}
+
+// runStats prints the routing stats per provider, best first.
+func runStats(cfg Config) error {
+	stats, err := loadStats()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(stats))
+	for n := range stats {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return stats[names[i]].score() < stats[names[j]].score() })
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tREQUESTS\tFAILED\tAVG LATENCY\tWORDS\tCORRECTED\tCORRECTION RATE\tSCORE")
+	for _, n := range names {
+		s := stats[n]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\t%d\t%.1f%%\t%.3f\n", n, s.Requests, s.Failures,
+			s.avgLatency().Round(10*time.Millisecond), s.Words, s.Corrections, 100*s.correctionRate(), s.score())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if cfg.AutoRoute {
+		fmt.Println("\nauto_route is on: lower score wins.")
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/recorder"
+)
+
+// Dictation states as reported by `dictate status`.
+const (
+	stateIdle         = "idle"
+	stateRecording    = "recording"
+	stateTranscribing = "transcribing"
+)
+
+// busyPath marks a toggle that is transcribing and inserting; it holds
+// the toggle's pid so a crashed one is not reported forever, and the stage
+// it is at, since only a transcription can be aborted.
+func busyPath() string {
+	return filepath.Join(runtimeDir(), "dictation-busy")
+}
+
+// Stages of a busy toggle.
+const (
+	busyTranscribing = "transcribing"
+	busyInserting    = "inserting"
+)
+
+// markBusy writes busyPath for stage busyTranscribing and returns the
+// function that removes it.
+func markBusy() func() {
+	if err := writeBusy(busyTranscribing); err != nil {
+		return func() {}
+	}
+	return func() { os.Remove(busyPath()) }
+}
+
+// markInserting moves the busy toggle on to busyInserting, once the
+// transcription is over.
+func markInserting() {
+	if _, err := os.Stat(busyPath()); err == nil {
+		writeBusy(busyInserting)
+	}
+}
+
+func writeBusy(stage string) error {
+	return os.WriteFile(busyPath(), []byte(strconv.Itoa(os.Getpid())+" "+stage), 0600)
+}
+
+// readBusy returns the pid and stage of the busy toggle, if it is still
+// running.
+func readBusy() (int, string, bool) {
+	b, err := os.ReadFile(busyPath())
+	if err != nil {
+		return 0, "", false
+	}
+	f := strings.Fields(string(b))
+	if len(f) == 0 {
+		return 0, "", false
+	}
+	pid, err := strconv.Atoi(f[0])
+	if err != nil {
+		return 0, "", false
+	}
+	if _, err := recorder.ProcessName(pid); err != nil {
+		return 0, "", false
+	}
+	stage := busyTranscribing
+	if len(f) > 1 {
+		stage = f[1]
+	}
+	return pid, stage, true
+}
+
+type status struct {
+	State string
+	// Since is when the recording started.
+	Since time.Time
+}
+
+func currentStatus() status {
+	if since, ok := recordingSince(); ok {
+		return status{State: stateRecording, Since: since}
+	}
+	if _, _, ok := readBusy(); ok {
+		return status{State: stateTranscribing}
+	}
+	return status{State: stateIdle}
+}
+
+func (s status) elapsed() time.Duration {
+	if s.Since.IsZero() {
+		return 0
+	}
+	return time.Since(s.Since).Round(time.Second)
+}
+
+// clock is the elapsed time as m:ss.
+func (s status) clock() string {
+	d := s.elapsed()
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// String is the state with the elapsed time while recording.
+func (s status) String() string {
+	if s.State != stateRecording {
+		return s.State
+	}
+	return s.State + " " + s.clock()
+}
+
+// waybar is the JSON a waybar custom module (return-type json) reads; the
+// state doubles as alt, for format-icons, and class, for styling.
+// Polybar takes the plain output.
+func (s status) waybar() string {
+	text := s.String()
+	tooltip := "Dictation: " + s.String()
+	if p := activeProfile(); p != "" {
+		tooltip += " (" + p + ")"
+	}
+	if ss, ok := activeSession(); ok {
+		tooltip += fmt.Sprintf("\nSession: %d dictations in %s", ss.Pieces, filepath.Base(ss.File))
+	}
+	b, _ := json.Marshal(struct {
+		Text    string `json:"text"`
+		Alt     string `json:"alt"`
+		Class   string `json:"class"`
+		Tooltip string `json:"tooltip"`
+		Elapsed int    `json:"elapsed"`
+	}{text, s.State, s.State, tooltip, int(s.elapsed().Seconds())})
+	return string(b)
+}
+
+// runStatus implements `dictate status [--waybar] [--follow]`.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	asWaybar := fs.Bool("waybar", false, "print the JSON waybar custom modules expect")
+	follow := fs.Bool("follow", false, "keep running and print a line whenever the status changes")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	line := func() string {
+		if *asWaybar {
+			return currentStatus().waybar()
+		}
+		return currentStatus().String()
+	}
+	if !*follow {
+		fmt.Println(line())
+		return nil
+	}
+	var last string
+	for {
+		if l := line(); l != last {
+			if _, err := fmt.Println(l); err != nil {
+				return nil // the bar went away
+			}
+			last = l
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
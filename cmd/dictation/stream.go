@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// The /v1/stream endpoint of `dictate serve` takes audio over a WebSocket
+// as it is recorded, for a web page or browser extension to dictate
+// through. Binary messages carry the audio: 16-bit little-endian mono PCM
+// at ?rate= Hz (16000 by default), or, with ?format=webm or ogg, what
+// MediaRecorder produces, which ffmpeg decodes. Speech is cut at pauses,
+// as in continuous dictation, and each segment is answered with
+//
+//	{"type": "segment", "index": 0, "text": "..."}
+//
+// A text message "stop" (or closing the socket) ends the stream; the
+// speech not yet answered is transcribed and
+//
+//	{"type": "done", "text": "all the segments"}
+//
+// is sent before the server closes it. Problems come as
+// {"type": "error", "error": "..."}.
+
+type streamMessage struct {
+	Type  string `json:"type"`
+	Index *int   `json:"index,omitempty"`
+	Text  string `json:"text,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// streamDecoder converts the stream to 16 kHz mono PCM through ffmpeg,
+// unless it is that already.
+func streamDecoder(format string, rate int, in io.Reader) (io.Reader, *exec.Cmd, error) {
+	var input []string
+	switch format {
+	case "", "pcm":
+		if rate == continuousRate {
+			return in, nil, nil
+		}
+		input = []string{"-f", "s16le", "-ar", strconv.Itoa(rate), "-ac", "1"}
+	case "webm", "ogg":
+		input = []string{"-f", format}
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q (pcm, webm or ogg)", format)
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, nil, errors.New("decoding the stream needs ffmpeg")
+	}
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, input...)
+	args = append(args, "-i", "pipe:0", "-f", "s16le", "-ar", strconv.Itoa(continuousRate), "-ac", "1", "pipe:1")
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdin = in
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return out, cmd, nil
+}
+
+func (s *server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	cfg := s.cfg
+	q := r.URL.Query()
+	if p := q.Get("profile"); p != "" {
+		if err := applyProfile(&cfg, p); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	rate := continuousRate
+	if v := q.Get("rate"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 8000 || n > 192000 {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("bad rate %q", v))
+			return
+		}
+		rate = n
+	}
+	det, err := newVoiceDetector(cfg.VAD, continuousRate)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer det.close()
+	pr, pw := io.Pipe()
+	pcm, decoder, err := streamDecoder(q.Get("format"), rate, pr)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		pw.Close()
+		if decoder != nil {
+			decoder.Wait()
+		}
+		return
+	}
+	defer ws.Close()
+
+	// segments are queued generously: a client may send faster than real
+	// time
+	segments := make(chan []byte, 64)
+	go func() {
+		err := segmentSpeech(cfg, det, pcm, segments)
+		// nothing reads the stream any more, so writes to it must fail
+		pr.Close()
+		if decoder != nil {
+			if err != nil {
+				decoder.Process.Kill()
+			}
+			decoder.Wait()
+		}
+		if err != nil {
+			ws.writeJSON(streamMessage{Type: "error", Error: err.Error()})
+		}
+		close(segments)
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var texts []string
+		for seg := range segments {
+			res, wav, err := transcribePCM(cfg, seg)
+			if err != nil {
+				ws.writeJSON(streamMessage{Type: "error", Error: "transcription failed: " + err.Error()})
+				continue
+			}
+			os.Remove(wav)
+			text := strings.TrimSpace(postProcess(cfg, res.Text))
+			if text == "" {
+				continue
+			}
+			index := len(texts)
+			ws.writeJSON(streamMessage{Type: "segment", Index: &index, Text: text})
+			texts = append(texts, text)
+		}
+		ws.writeJSON(streamMessage{Type: "done", Text: strings.Join(texts, " ")})
+	}()
+
+	for {
+		op, msg, err := ws.readMessage()
+		if err != nil {
+			break
+		}
+		if op == wsText {
+			var m streamMessage
+			if string(bytes.TrimSpace(msg)) == "stop" || json.Unmarshal(msg, &m) == nil && m.Type == "stop" {
+				break
+			}
+			continue
+		}
+		if _, err := pw.Write(msg); err != nil {
+			break
+		}
+	}
+	pw.Close()
+	<-done
+	ws.write(wsClose, []byte{0x03, 0xe8}) // 1000, normal closure
+}
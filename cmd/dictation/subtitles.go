@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Export formats for `transcribe --format`.
+const (
+	formatText  = "text"
+	formatSRT   = "srt"
+	formatVTT   = "vtt"
+	formatWords = "words"
+)
+
+// subtitleTime formats seconds as HH:MM:SS<sep>mmm.
+func subtitleTime(sec float64, sep string) string {
+	ms := int64(math.Round(sec * 1000))
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+func formatSubtitles(format string, segs []Segment) string {
+	var b strings.Builder
+	sep := ","
+	if format == formatVTT {
+		b.WriteString("WEBVTT\n\n")
+		sep = "."
+	}
+	n := 0
+	for _, s := range segs {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		n++
+		if format == formatSRT {
+			fmt.Fprintf(&b, "%d\n", n)
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", subtitleTime(s.Start, sep), subtitleTime(s.End, sep), text)
+	}
+	return b.String()
+}
+
+// formatWordList prints one "start<TAB>end<TAB>word" line per word, times in
+// seconds.
+func formatWordList(words []Word) string {
+	var b strings.Builder
+	for _, w := range words {
+		fmt.Fprintf(&b, "%.2f\t%.2f\t%s\n", w.Start, w.End, strings.TrimSpace(w.Word))
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestSubtitleTime(t *testing.T) {
+	tests := []struct {
+		sec       float64
+		sep, want string
+	}{
+		{0, ",", "00:00:00,000"},
+		{1.5, ",", "00:00:01,500"},
+		{61.25, ".", "00:01:01.250"},
+		{3661.001, ",", "01:01:01,001"},
+		// rounded to the millisecond, carrying into the seconds
+		{59.9996, ",", "00:01:00,000"},
+		{0.0004, ",", "00:00:00,000"},
+		{-2, ",", "00:00:00,000"},
+		{360000, ".", "100:00:00.000"},
+	}
+	for _, tt := range tests {
+		if got := subtitleTime(tt.sec, tt.sep); got != tt.want {
+			t.Errorf("subtitleTime(%v, %q) = %q, want %q", tt.sec, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSubtitles(t *testing.T) {
+	segs := []Segment{
+		{Start: 0, End: 2.5, Text: " Hello there."},
+		{Start: 2.5, End: 3, Text: "  "},
+		{Start: 3, End: 65.125, Text: "General Kenobi."},
+	}
+	srt := "1\n00:00:00,000 --> 00:00:02,500\nHello there.\n\n" +
+		"2\n00:00:03,000 --> 00:01:05,125\nGeneral Kenobi.\n\n"
+	if got := formatSubtitles(formatSRT, segs); got != srt {
+		t.Errorf("SRT:\n%q\nwant\n%q", got, srt)
+	}
+	vtt := "WEBVTT\n\n00:00:00.000 --> 00:00:02.500\nHello there.\n\n" +
+		"00:00:03.000 --> 00:01:05.125\nGeneral Kenobi.\n\n"
+	if got := formatSubtitles(formatVTT, segs); got != vtt {
+		t.Errorf("VTT:\n%q\nwant\n%q", got, vtt)
+	}
+	if got := formatSubtitles(formatVTT, nil); got != "WEBVTT\n\n" {
+		t.Errorf("empty VTT = %q", got)
+	}
+	if got := formatSubtitles(formatSRT, nil); got != "" {
+		t.Errorf("empty SRT = %q", got)
+	}
+}
+
+func TestFormatWordList(t *testing.T) {
+	words := []Word{{Word: " Hello", Start: 0, End: 0.42}, {Word: "world ", Start: 0.5, End: 1.005}}
+	want := "0.00\t0.42\tHello\n0.50\t1.00\tworld\n"
+	if got := formatWordList(words); got != want {
+		t.Errorf("formatWordList = %q, want %q", got, want)
+	}
+}
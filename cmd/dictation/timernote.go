@@ -0,0 +1,65 @@
+package main
+
+import (
+	"time"
+
+	"github.com/user/dictation/notify"
+)
+
+// The daemon keeps one notification up while a dictation is in progress,
+// updated in place: the elapsed time while recording, then "Transcribing…"
+// until the text is in. It follows the same state `dictate status`
+// reports, so toggles run outside the daemon show it too.
+
+// progressNote is a notification replaced in place through its id.
+type progressNote struct {
+	id uint32
+}
+
+func (n *progressNote) show(body string) {
+	id, err := notify.Send(notify.Notification{Summary: "Dictation", Body: body,
+		Urgency: notify.Low, ReplaceID: n.id})
+	if err == nil {
+		n.id = id
+	}
+}
+
+func (n *progressNote) close() {
+	if n.id == 0 {
+		return
+	}
+	notify.Close(n.id)
+	n.id = 0
+}
+
+// watchTimer shows the progress notification until done is closed.
+func watchTimer(done <-chan struct{}) {
+	var note progressNote
+	defer note.close()
+	var last string
+	tick := time.NewTicker(500 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		s := currentStatus()
+		body := ""
+		switch s.State {
+		case stateRecording:
+			body = "Recording " + s.clock()
+		case stateTranscribing:
+			body = "Transcribing…"
+		}
+		if body != last {
+			if body == "" {
+				note.close()
+			} else {
+				note.show(body)
+			}
+			last = body
+		}
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+		}
+	}
+}
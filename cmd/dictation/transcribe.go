@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/provider"
+	"github.com/user/dictation/transcribe"
+)
+
+// Word and Segment are the timings in verbose responses.
+type (
+	Word    = transcribe.Word
+	Segment = transcribe.Segment
+)
+
+// transcription is what a backend returns for one recording.
+type transcription struct {
+	transcribe.Result
+	// Provider and Model record who produced the text.
+	Provider string
+	Model    string
+}
+
+// transcribeFile sends the recording to the selected provider and records the
+// outcome in the routing stats. Cancelling ctx aborts the request.
+func transcribeFile(ctx context.Context, cfg Config, wavPath string) (transcription, error) {
+	p, err := selectProvider(cfg)
+	if err != nil {
+		return transcription{}, err
+	}
+	if cfg.forceModel != "" {
+		p.Model = cfg.forceModel
+	}
+	start := time.Now()
+	if cfg.Denoise {
+		if clean, err := denoiseAudio(wavPath); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		} else {
+			defer os.Remove(clean)
+			wavPath = clean
+		}
+	}
+	if cfg.Hooks.BeforeUpload != "" {
+		if processed, cleanup, err := runBeforeUpload(cfg, wavPath); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		} else {
+			defer cleanup()
+			wavPath = processed
+		}
+	}
+	res, err := transcribeWith(ctx, cfg, p, wavPath)
+	if unsupportedFormat(err) && ctx.Err() == nil {
+		// retry once with audio the provider should understand
+		conv, cerr := reencodeAudio(wavPath, cfg.ReencodeFormat)
+		if cerr != nil {
+			fmt.Fprintln(os.Stderr, "warning:", cerr)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s rejected %s (%v); retrying as %s\n", p.Name, filepath.Base(wavPath), err, filepath.Ext(conv))
+			res, err = transcribeWith(ctx, cfg, p, conv)
+			os.Remove(conv)
+		}
+	}
+	if ctx.Err() != nil {
+		// an abort says nothing about the provider
+		slog.Info("transcription aborted", "provider", p.Name)
+		return res, err
+	}
+	if err != nil {
+		slog.Error("transcription failed", "provider", p.Name, "model", p.Model, "err", err)
+	} else {
+		observeLatency(map[string]time.Duration{latencyRequest: res.RequestTime})
+		slog.Info("transcribed", "provider", p.Name, "model", p.Model, "duration", time.Since(start),
+			"audio_seconds", res.Duration, "chars", len([]rune(res.Text)))
+	}
+	recordRequest(p.Name, time.Since(start), res.Text, err)
+	recordMetric(cfg, metric{Kind: metricRequest, Provider: p.Name, LatencyMS: time.Since(start).Milliseconds(),
+		Words: len(strings.Fields(res.Text)), Failed: err != nil})
+	return res, err
+}
+
+// requestTimeout is the provider's timeout, else request_timeout, else 120
+// seconds.
+func requestTimeout(cfg Config, p provider.Provider) time.Duration {
+	secs := p.Timeout
+	if secs <= 0 {
+		secs = cfg.RequestTimeout
+	}
+	if secs <= 0 {
+		return 120 * time.Second
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+func transcribeWith(ctx context.Context, cfg Config, p provider.Provider, wavPath string) (transcription, error) {
+	res := transcription{Provider: p.Name, Model: p.Model}
+	apiKey, err := providerKey(cfg, p)
+	if err != nil {
+		return res, err
+	}
+	if isExec(p.URL) {
+		return execTranscribe(ctx, cfg, p, wavPath, apiKey)
+	}
+	endpoint := p.Endpoint
+	if cfg.Translate {
+		endpoint = p.TranslateEndpoint
+	}
+	url, err := endpoint()
+	if err != nil {
+		return res, err
+	}
+	req := transcribe.Request{
+		URL:    url,
+		Name:   p.Name,
+		Header: p.Header(apiKey),
+		Model:  p.Model,
+		Prompt: cfg.TranscriptionPrompt,
+		// word timings, segments and confidence only come with the
+		// verbose format; otherwise ask for the smallest response the
+		// provider has (min_confidence wants it only where there is one)
+		Format: p.ResponseFormat(cfg.WordTimestamps || cfg.wantDetails ||
+			(cfg.MinConfidence > 0 && p.SupportsFormat(transcribe.FormatVerboseJSON))),
+		// the translations endpoint has no word timings
+		WordTimings: cfg.WordTimestamps && !cfg.Translate,
+		Timeout:     requestTimeout(cfg, p),
+	}
+	// translations are always English, the language field doesn't apply
+	if !cfg.Translate {
+		req.Language = cfg.Language
+	}
+	res.Result, err = transcribe.FileContext(ctx, req, wavPath)
+	var ue *neturl.Error
+	if p.Name == "worker" && errors.As(err, &ue) && ctx.Err() == nil {
+		return res, fmt.Errorf("worker not reachable (is `dictate daemon` running?): %v", err)
+	}
+	return res, err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// `dictate tray` keeps an icon in the system tray that shows when the
+// microphone is live. It drives yad's notification icon (a StatusNotifier
+// item where yad is built with AppIndicator support) over its --listen
+// protocol: one command per line on yad's stdin.
+
+const (
+	trayIconIdle      = "audio-input-microphone"
+	trayIconRecording = "media-record"
+	trayIconBusy      = "view-refresh"
+)
+
+// runTray implements `dictate tray`. Clicking the icon toggles a recording;
+// its menu stops, cancels and switches profiles.
+func runTray(cfg Config) error {
+	if isMac || isWindows || !pathExists("yad") {
+		return errors.New("tray: needs yad (Linux)")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	run := shellQuote(self)
+	if cfg.Portable {
+		run += " --portable"
+	}
+	cmd := exec.Command("yad", "--notification", "--listen",
+		"--image="+trayIconIdle, "--text=Dictation", "--command="+run)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("tray: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	defer in.Close()
+
+	fmt.Fprintln(in, "menu:"+trayMenu(cfg, run))
+	var last string
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		if s := trayState(); s != last {
+			if err := trayUpdate(in, s); err != nil {
+				return err
+			}
+			last = s
+		}
+		select {
+		case err := <-done:
+			return err
+		case <-tick.C:
+		}
+	}
+}
+
+// trayState is the tooltip for the current state; the icon follows from
+// it.
+func trayState() string {
+	s := "Dictation: " + currentStatus().String()
+	if p := activeProfile(); p != "" {
+		s += " (" + p + ")"
+	}
+	return s
+}
+
+func trayUpdate(w io.Writer, state string) error {
+	icon := trayIconIdle
+	switch {
+	case strings.Contains(state, stateRecording):
+		icon = trayIconRecording
+	case strings.Contains(state, stateTranscribing):
+		icon = trayIconBusy
+	}
+	_, err := fmt.Fprintf(w, "icon:%s\ntooltip:%s\n", icon, state)
+	return err
+}
+
+// trayMenu builds yad's menu: entries separated by |, label and command by
+// !.
+func trayMenu(cfg Config, run string) string {
+	items := []string{
+		"Start / stop recording!" + run,
+		"Cancel recording!" + run + " cancel",
+	}
+	names := make([]string, 0, len(cfg.Profiles))
+	for n := range cfg.Profiles {
+		if !strings.ContainsAny(n, "|!") {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		items = append(items, "Profile: "+n+"!"+run+" profile "+shellQuote(n))
+	}
+	if len(names) > 0 {
+		items = append(items, "No profile!"+run+" profile "+profileNone)
+	}
+	items = append(items, "Quit!quit")
+	return strings.Join(items, "|")
+}
+
+// shellQuote quotes s for the shell-style parsing yad does of commands.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
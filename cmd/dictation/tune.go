@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/user/dictation/audio"
+	"github.com/user/dictation/recorder"
+)
+
+// `dictate tune` has the user read a few known sentences and transcribes
+// them with every combination of the settings that matter most for
+// accuracy, so the choice is made on their own voice and microphone.
+
+// tuneSentences are read when no --text file is given. They mix numbers,
+// names and words that are easily misheard.
+var tuneSentences = []string{
+	"The quick brown fox jumps over the lazy dog.",
+	"Please schedule the meeting for Thursday at half past three.",
+	"Our quarterly revenue grew by twelve percent compared to last year.",
+	"Send the draft to Catherine and copy Mr. Nguyen on the reply.",
+	"I'd like two coffees, one with oat milk, and a croissant.",
+}
+
+// tuneSetting is one combination of settings tried.
+type tuneSetting struct {
+	Provider string
+	Language string
+	Prompt   bool
+	Denoise  bool
+}
+
+func (s tuneSetting) String() string {
+	lang := s.Language
+	if lang == "" {
+		lang = "auto"
+	}
+	return fmt.Sprintf("%s, language %s, prompt %s, denoise %s", s.Provider, lang, onOff(s.Prompt), onOff(s.Denoise))
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+type tuneScore struct {
+	Setting tuneSetting
+	// WER is word edits per reference word over all sentences.
+	WER     float64
+	Latency time.Duration
+	Err     error
+}
+
+// runTune implements `dictate tune [--text file] [--apply]`.
+func runTune(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("tune", flag.ContinueOnError)
+	textFile := fs.String("text", "", "file with the sentences to read, one per line (default: a few English ones)")
+	apply := fs.Bool("apply", false, "write the best settings to the config file")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	sentences, lang := tuneSentences, "en"
+	if *textFile != "" {
+		b, err := os.ReadFile(expandHome(*textFile))
+		if err != nil {
+			return err
+		}
+		sentences, lang = nil, cfg.Language
+		for _, l := range strings.Split(string(b), "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				sentences = append(sentences, l)
+			}
+		}
+		if len(sentences) == 0 {
+			return exitError{exitUsage, fmt.Errorf("%s has no sentences", *textFile)}
+		}
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return exitError{exitUsage, errors.New("tune records from the terminal; run it in one")}
+	}
+
+	settings := tuneSettings(cfg, lang)
+	if len(settings) == 0 {
+		return errors.New("no provider has its key available")
+	}
+
+	dir, err := os.MkdirTemp("", "dictation-tune-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	in := bufio.NewReader(os.Stdin)
+	var wavs []string
+	for i, s := range sentences {
+		wav := filepath.Join(dir, fmt.Sprintf("%d.wav", i))
+		fmt.Fprintf(os.Stderr, "\n%d/%d: %q\nPress Enter, read it out, then press Enter again.", i+1, len(sentences), s)
+		in.ReadString('\n')
+		if err := recordUntilEnter(in, wav); err != nil {
+			return err
+		}
+		wavs = append(wavs, wav)
+	}
+
+	fmt.Fprintf(os.Stderr, "\ntrying %d settings on %d recordings…\n", len(settings), len(wavs))
+	scores := make([]tuneScore, 0, len(settings))
+	for _, s := range settings {
+		scores = append(scores, scoreSetting(cfg, s, sentences, wavs))
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		if a.WER != b.WER {
+			return a.WER < b.WER
+		}
+		return a.Latency < b.Latency
+	})
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tLANGUAGE\tPROMPT\tDENOISE\tWORD ERRORS\tLATENCY")
+	for _, sc := range scores {
+		s := sc.Setting
+		lang := s.Language
+		if lang == "" {
+			lang = "auto"
+		}
+		if sc.Err != nil {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\terror: %v\t\n", s.Provider, lang, onOff(s.Prompt), onOff(s.Denoise), sc.Err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.1f%%\t%s\n", s.Provider, lang, onOff(s.Prompt), onOff(s.Denoise),
+			100*sc.WER, sc.Latency.Round(10*time.Millisecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	best := scores[0]
+	if best.Err != nil {
+		return fmt.Errorf("every setting failed: %v", best.Err)
+	}
+	fmt.Printf("\nbest: %s\n", best.Setting)
+	if !*apply {
+		fmt.Println("run with --apply to save it")
+		return nil
+	}
+	if err := applyTuning(cfg, best.Setting); err != nil {
+		return err
+	}
+	fmt.Println("saved to", filepath.Join(configDir(), "config.json"))
+	return nil
+}
+
+// tuneSettings lists the combinations worth trying: every provider with a
+// key, the language hint of the sentences on and off, the transcription prompt on and off
+// when one is configured, and denoising on and off when ffmpeg is there.
+func tuneSettings(cfg Config, lang string) []tuneSetting {
+	langs := []string{""}
+	if lang != "" {
+		langs = append(langs, lang)
+	}
+	prompts := []bool{false}
+	if cfg.TranscriptionPrompt != "" {
+		prompts = append(prompts, true)
+	}
+	denoise := []bool{false}
+	if pathExists("ffmpeg") {
+		denoise = append(denoise, true)
+	}
+	var list []tuneSetting
+	for _, p := range providerList(cfg) {
+		if _, err := providerKey(cfg, p); err != nil {
+			continue
+		}
+		for _, l := range langs {
+			for _, pr := range prompts {
+				for _, d := range denoise {
+					list = append(list, tuneSetting{p.Name, l, pr, d})
+				}
+			}
+		}
+	}
+	return list
+}
+
+// recordUntilEnter records into wav until a line is read from in.
+func recordUntilEnter(in *bufio.Reader, wav string) error {
+	cmd := recorder.Command(wav)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start recorder: %v", err)
+	}
+	fmt.Fprint(os.Stderr, " recording…")
+	in.ReadString('\n')
+	if err := recorder.StopProcess(cmd.Process.Pid); err != nil {
+		return err
+	}
+	cmd.Wait()
+	if isWindows {
+		return audio.RepairWAVHeader(wav)
+	}
+	return nil
+}
+
+func scoreSetting(cfg Config, s tuneSetting, refs, wavs []string) tuneScore {
+	sc := tuneScore{Setting: s}
+	p, err := findProvider(cfg, s.Provider)
+	if err != nil {
+		sc.Err = err
+		return sc
+	}
+	cfg.Language = s.Language
+	if !s.Prompt {
+		cfg.TranscriptionPrompt = ""
+	}
+	var edits, words int
+	for i, wav := range wavs {
+		if s.Denoise {
+			clean, err := denoiseAudio(wav)
+			if err != nil {
+				sc.Err = err
+				return sc
+			}
+			wav = clean
+		}
+		start := time.Now()
+		res, err := transcribeWith(context.Background(), cfg, p, wav)
+		if s.Denoise {
+			os.Remove(wav)
+		}
+		if err != nil {
+			sc.Err = err
+			return sc
+		}
+		sc.Latency += time.Since(start)
+		ref := tuneNormalize(refs[i])
+		edits += wordEdits(ref, tuneNormalize(res.Text))
+		words += len(strings.Fields(ref))
+	}
+	sc.WER = float64(edits) / float64(max(words, 1))
+	sc.Latency /= time.Duration(len(wavs))
+	return sc
+}
+
+// tuneNormalize drops punctuation so only the words are compared.
+func tuneNormalize(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}), " ")
+}
+
+// applyTuning writes the setting into config.json, leaving everything else
+// in the file as it is.
+func applyTuning(cfg Config, s tuneSetting) error {
+	path := filepath.Join(configDir(), "config.json")
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	m := map[string]json.RawMessage{}
+	if b, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(b, &m); err != nil {
+			return fmt.Errorf("config: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	set := func(key string, v any) {
+		b, _ := json.Marshal(v)
+		m[key] = b
+	}
+	set("provider", s.Provider)
+	set("language", s.Language)
+	set("denoise", s.Denoise)
+	if !s.Prompt && cfg.TranscriptionPrompt != "" {
+		set("transcription_prompt", "")
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/audio"
+)
+
+// VADConfig configures voice activity detection, used to stop a recording
+// on its own once the speaker has finished.
+type VADConfig struct {
+	// Backend is energy (default), silero or webrtc. The latter two run
+	// the model through Python (onnxruntime and numpy, or webrtcvad).
+	Backend string `json:"backend"`
+	// AutoStop is the number of seconds of silence after speech that end
+	// the recording; 0 leaves stopping to the hotkey.
+	AutoStop float64 `json:"auto_stop"`
+	// EnergyDB is the level in dBFS above which a frame counts as speech
+	// for the energy backend. By default it is 10 dB above the noise floor
+	// measured by `dictate calibrate`, or -40 without one.
+	EnergyDB float64 `json:"energy_db"`
+	// Threshold is the speech probability for silero (default 0.5).
+	Threshold float64 `json:"threshold"`
+	// Aggressiveness is the webrtc mode, 0 (lenient) to 3 (strict),
+	// default 2.
+	Aggressiveness *int `json:"aggressiveness"`
+	// Model is the Silero VAD ONNX file (v5).
+	Model string `json:"model"`
+	// Python is the interpreter for silero and webrtc (default python3).
+	Python string `json:"python"`
+}
+
+// VAD backends.
+const (
+	vadEnergy = "energy"
+	vadSilero = "silero"
+	vadWebRTC = "webrtc"
+)
+
+// voiceDetector classifies fixed-size frames of 16-bit little-endian mono
+// PCM as speech or not.
+type voiceDetector interface {
+	// frameSamples is the number of samples isSpeech expects.
+	frameSamples() int
+	isSpeech(frame []byte) (bool, error)
+	close()
+}
+
+// newVoiceDetector builds the configured backend for audio at rate Hz.
+func newVoiceDetector(c VADConfig, rate int) (voiceDetector, error) {
+	switch c.Backend {
+	case "", vadEnergy:
+		db := c.EnergyDB
+		if floor, ok := noiseFloor(); db == 0 && ok {
+			db = floor + speechMarginDB
+		}
+		if db == 0 {
+			db = -40
+		}
+		return energyVAD{samples: rate * 30 / 1000, threshold: db}, nil
+	case vadSilero:
+		if c.Model == "" {
+			return nil, errors.New("vad: silero needs \"model\", the path to silero_vad.onnx")
+		}
+		if rate != 16000 {
+			return nil, fmt.Errorf("vad: silero needs 16 kHz audio, not %d Hz", rate)
+		}
+		threshold := c.Threshold
+		if threshold == 0 {
+			threshold = 0.5
+		}
+		return startPythonVAD(c, sileroScript, 512, threshold, expandHome(c.Model))
+	case vadWebRTC:
+		if rate != 8000 && rate != 16000 && rate != 32000 && rate != 48000 {
+			return nil, fmt.Errorf("vad: webrtc cannot handle %d Hz audio", rate)
+		}
+		mode := 2
+		if c.Aggressiveness != nil {
+			mode = *c.Aggressiveness
+		}
+		return startPythonVAD(c, webrtcScript, rate*30/1000, 0.5, strconv.Itoa(mode), strconv.Itoa(rate))
+	}
+	return nil, fmt.Errorf("vad: unknown backend %q (energy, silero or webrtc)", c.Backend)
+}
+
+// energyVAD calls a frame speech when its RMS level is above threshold
+// dBFS. It needs nothing installed but is easily fooled by steady noise.
+type energyVAD struct {
+	samples   int
+	threshold float64
+}
+
+func (v energyVAD) frameSamples() int { return v.samples }
+
+func (v energyVAD) isSpeech(frame []byte) (bool, error) {
+	return frameDB(frame) > v.threshold, nil
+}
+
+func (energyVAD) close() {}
+
+// Silero v5 keeps a recurrent state and expects each 512-sample window with
+// the last 64 samples of the previous one in front.
+const sileroScript = `
+import sys, numpy as np, onnxruntime as ort
+s = ort.InferenceSession(sys.argv[1], providers=["CPUExecutionProvider"])
+state = np.zeros((2, 1, 128), dtype=np.float32)
+ctx = np.zeros((1, 64), dtype=np.float32)
+sr = np.array(16000, dtype=np.int64)
+while True:
+    b = sys.stdin.buffer.read(1024)
+    if len(b) < 1024:
+        break
+    x = np.frombuffer(b, dtype="<i2").astype(np.float32)[None, :] / 32768
+    p, state = s.run(None, {"input": np.concatenate([ctx, x], axis=1), "state": state, "sr": sr})
+    ctx = x[:, -64:]
+    print(float(p[0][0]), flush=True)
+`
+
+const webrtcScript = `
+import sys, webrtcvad
+v = webrtcvad.Vad(int(sys.argv[1]))
+rate = int(sys.argv[2])
+n = rate * 30 // 1000 * 2
+while True:
+    b = sys.stdin.buffer.read(n)
+    if len(b) < n:
+        break
+    print(1 if v.is_speech(b, rate) else 0, flush=True)
+`
+
+// pythonVAD feeds frames to a model running in a Python helper and reads
+// back one speech probability per frame.
+type pythonVAD struct {
+	cmd       *exec.Cmd
+	in        io.WriteCloser
+	out       *bufio.Reader
+	samples   int
+	threshold float64
+}
+
+func startPythonVAD(c VADConfig, script string, samples int, threshold float64, args ...string) (voiceDetector, error) {
+	python := c.Python
+	if python == "" {
+		python = "python3"
+	}
+	cmd := exec.Command(python, append([]string{"-c", script}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("vad: %v", err)
+	}
+	v := &pythonVAD{cmd: cmd, in: in, out: bufio.NewReader(out), samples: samples, threshold: threshold}
+	// a missing module only shows once the first frame gets no answer
+	if _, err := v.isSpeech(make([]byte, 2*samples)); err != nil {
+		v.close()
+		return nil, fmt.Errorf("vad %s: %s", c.Backend, strings.TrimSpace(stderr.String()))
+	}
+	return v, nil
+}
+
+func (v *pythonVAD) frameSamples() int { return v.samples }
+
+func (v *pythonVAD) isSpeech(frame []byte) (bool, error) {
+	if _, err := v.in.Write(frame); err != nil {
+		return false, err
+	}
+	line, err := v.out.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	p, err := strconv.ParseFloat(strings.TrimSpace(line), 64)
+	if err != nil {
+		return false, err
+	}
+	return p >= v.threshold, nil
+}
+
+func (v *pythonVAD) close() {
+	v.in.Close()
+	v.cmd.Wait()
+}
+
+// vadPoll is how often the growing recording is read.
+const vadPoll = 100 * time.Millisecond
+
+// runVADWatch implements the hidden `dictate vad-watch <wav> <pidfile>`,
+// started next to the recorder when vad.auto_stop is set. It follows the
+// recording as it grows and, once speech has been heard and followed by
+// auto_stop seconds of silence, stops and transcribes it as the hotkey
+// would. It gives up when the recorder is stopped some other way.
+func runVADWatch(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("vad-watch", flag.ContinueOnError)
+	autoStop := fs.Float64("auto-stop", cfg.VAD.AutoStop, "seconds of silence that end the recording")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return exitError{exitUsage, err}
+	}
+	if fs.NArg() != 2 {
+		return exitError{exitUsage, errors.New("usage: dictate vad-watch [--auto-stop S] <wav> <pidfile>")}
+	}
+	cfg.VAD.AutoStop = *autoStop
+	wav, pidFile := fs.Arg(0), fs.Arg(1)
+	pid, err := os.ReadFile(pidFile)
+	if err != nil {
+		return err
+	}
+
+	var (
+		f        *os.File
+		det      voiceDetector
+		info     audio.WAVInfo
+		offset   int64
+		speech   bool
+		silentMS float64
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+		if det != nil {
+			det.close()
+		}
+	}()
+	for {
+		time.Sleep(vadPoll)
+		// a different pid means the user stopped this one and started again
+		if cur, err := os.ReadFile(pidFile); err != nil || !bytes.Equal(cur, pid) {
+			return nil
+		}
+		if det == nil {
+			head := make([]byte, 4096)
+			if f == nil {
+				if f, err = os.Open(wav); err != nil {
+					f = nil
+					continue
+				}
+			}
+			n, _ := f.ReadAt(head, 0)
+			if info, err = audio.ParseWAV(head[:n]); err != nil {
+				continue // header not written yet
+			}
+			if info.Channels != 1 || info.BitsPerSample != 16 {
+				return fmt.Errorf("vad: need 16-bit mono audio, got %d channels of %d bits", info.Channels, info.BitsPerSample)
+			}
+			if det, err = newVoiceDetector(cfg.VAD, info.SampleRate); err != nil {
+				notifyUser("Dictation", "Auto-stop disabled: "+err.Error())
+				return err
+			}
+			offset = int64(info.DataOffset)
+		}
+		frame := make([]byte, 2*det.frameSamples())
+		frameMS := float64(det.frameSamples()) * 1000 / float64(info.SampleRate)
+		for {
+			if _, err := f.ReadAt(frame, offset); err != nil {
+				break // wait for the recorder to write more
+			}
+			offset += int64(len(frame))
+			ok, err := det.isSpeech(frame)
+			if err != nil {
+				return err
+			}
+			switch {
+			case ok:
+				speech, silentMS = true, 0
+			case speech:
+				silentMS += frameMS
+			}
+		}
+		if speech && silentMS >= cfg.VAD.AutoStop*1000 {
+			f.Close()
+			f = nil
+			return toggle(cfg)
+		}
+	}
+}
+
+// startVADWatch runs vad-watch in the background for a recording just
+// started, with the same flags as this invocation. The auto-stop delay is
+// passed on as well, since the daemon's wake word sets its own.
+func startVADWatch(cfg Config, wav, pidFile string) {
+	if cfg.VAD.AutoStop <= 0 {
+		return
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: auto-stop:", err)
+		return
+	}
+	// the flags only: a daemon's command line ends in "daemon"
+	flags := os.Args[1 : len(os.Args)-flag.NArg()]
+	args := append(flags[:len(flags):len(flags)], "vad-watch", "--auto-stop", strconv.FormatFloat(cfg.VAD.AutoStop, 'f', -1, 64), wav, pidFile)
+	cmd := exec.Command(self, args...)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: auto-stop:", err)
+		return
+	}
+	cmd.Process.Release()
+}
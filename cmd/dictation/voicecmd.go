@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/user/dictation/insert"
+	"github.com/user/dictation/postprocess"
+)
+
+// Voice commands are whole utterances that edit what was dictated
+// instead of being typed themselves.
+const (
+	cmdScratch    = "scratch"
+	cmdDeleteWord = "delete-word"
+	// cmdRecase retypes the last dictation with a casing policy; the
+	// policy follows the colon, e.g. "recase:upper".
+	cmdRecase = "recase:"
+)
+
+var voiceCommands = map[string]string{
+	"scratch that":       cmdScratch,
+	"delete that":        cmdScratch,
+	"undo that":          cmdScratch,
+	"delete last word":   cmdDeleteWord,
+	"delete word":        cmdDeleteWord,
+	"capitalize that":    cmdRecase + postprocess.CasingTitle,
+	"cap that":           cmdRecase + postprocess.CasingTitle,
+	"title case that":    cmdRecase + postprocess.CasingTitle,
+	"uppercase that":     cmdRecase + postprocess.CasingUpper,
+	"upper case that":    cmdRecase + postprocess.CasingUpper,
+	"all caps that":      cmdRecase + postprocess.CasingUpper,
+	"lowercase that":     cmdRecase + postprocess.CasingLower,
+	"lower case that":    cmdRecase + postprocess.CasingLower,
+	"no caps that":       cmdRecase + postprocess.CasingLower,
+	"sentence case that": cmdRecase + postprocess.CasingSentence,
+}
+
+// voiceCommand reports which command, if any, the raw transcript is.
+// Only an utterance consisting of nothing but the command counts, so
+// "please scratch that idea" is still typed.
+func voiceCommand(text string) (string, bool) {
+	s := strings.ToLower(strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}))
+	cmd, ok := voiceCommands[strings.Join(strings.Fields(s), " ")]
+	return cmd, ok
+}
+
+// runVoiceCommand performs cmd against the focused window. Commands that
+// act on "that" work on the text inserted by the last dictation.
+func runVoiceCommand(cfg Config, cmd string) error {
+	if cmd == cmdDeleteWord {
+		return insert.Backspace(1, true)
+	}
+	t, err := loadLastTranscript()
+	if err != nil {
+		return fmt.Errorf("nothing to change: %v", err)
+	}
+	if t.Inserted == "" {
+		return errors.New("nothing to change: the last dictation was not typed")
+	}
+	if err := insert.Backspace(len([]rune(t.Inserted)), false); err != nil {
+		return err
+	}
+	var text string
+	if policy, ok := strings.CutPrefix(cmd, cmdRecase); ok {
+		text = postprocess.ApplyCasing(policy, t.Inserted)
+		typed, err := insertText(cfg, text)
+		if err != nil {
+			return err
+		}
+		if !typed {
+			text = ""
+		}
+	}
+	_, err = updateLastTranscript(func(last *Transcript) error {
+		if last.ID == t.ID {
+			last.Inserted = text
+		}
+		return nil
+	})
+	return err
+}
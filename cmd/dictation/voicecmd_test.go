@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/user/dictation/postprocess"
+)
+
+func TestVoiceCommand(t *testing.T) {
+	tests := []struct {
+		text, cmd string
+		ok        bool
+	}{
+		{"scratch that", cmdScratch, true},
+		{"Scratch that.", cmdScratch, true},
+		{"  Delete   that!  ", cmdScratch, true},
+		{"delete last word", cmdDeleteWord, true},
+		{"Capitalize that.", cmdRecase + postprocess.CasingTitle, true},
+		{"ALL CAPS THAT", cmdRecase + postprocess.CasingUpper, true},
+		{"no caps that", cmdRecase + postprocess.CasingLower, true},
+		{"sentence case that", cmdRecase + postprocess.CasingSentence, true},
+		// only the whole utterance counts
+		{"please scratch that idea", "", false},
+		{"scratch that and then some", "", false},
+		{"scratch", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		cmd, ok := voiceCommand(tt.text)
+		if cmd != tt.cmd || ok != tt.ok {
+			t.Errorf("voiceCommand(%q) = %q, %v, want %q, %v", tt.text, cmd, ok, tt.cmd, tt.ok)
+		}
+	}
+}
+
+// Every recase command names a real casing policy.
+func TestVoiceCommandPolicies(t *testing.T) {
+	for phrase, cmd := range voiceCommands {
+		if policy, ok := strings.CutPrefix(cmd, cmdRecase); ok && (policy == "" || !postprocess.ValidCasing(policy)) {
+			t.Errorf("%q recases with unknown policy %q", phrase, policy)
+		}
+	}
+}
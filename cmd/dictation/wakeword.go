@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/dictation/recorder"
+)
+
+// With wake_word set, the daemon listens to the microphone for a wake word
+// and starts a recording when it hears it, which auto-stop then ends. The
+// detector runs locally; only the recording goes to the transcription
+// provider.
+
+// WakeWordConfig configures the wake-word detector.
+type WakeWordConfig struct {
+	// Model is an openWakeWord model: a bundled one by name
+	// ("hey_jarvis") or the path of a .tflite or .onnx file trained for
+	// "hey dictation".
+	Model string `json:"model"`
+	// Threshold is the score above which the word counts as heard
+	// (default 0.5).
+	Threshold float64 `json:"threshold"`
+	// Command replaces openWakeWord with another detector, e.g. a
+	// Porcupine script: it reads 16 kHz 16-bit mono PCM on stdin and prints
+	// a line each time it hears the word.
+	Command []string `json:"command"`
+	// Python is the interpreter for openWakeWord (default python3).
+	Python string `json:"python"`
+	// Profile is applied to recordings the wake word starts.
+	Profile string `json:"profile"`
+	// AutoStop is the seconds of silence that end those recordings; by
+	// default vad.auto_stop, or 2 when that is not set.
+	AutoStop float64 `json:"auto_stop"`
+}
+
+// openWakeWordScript prints the model's name each time its score crosses
+// the threshold, then ignores it for about two seconds so one utterance is
+// not taken for several.
+const openWakeWordScript = `
+import sys, numpy as np
+from openwakeword.model import Model
+name, threshold = sys.argv[1], float(sys.argv[2])
+kw = {"inference_framework": "onnx"} if name.endswith(".onnx") else {}
+m = Model(wakeword_models=[name], **kw)
+quiet = 0
+while True:
+    b = sys.stdin.buffer.read(2560)
+    if len(b) < 2560:
+        break
+    scores = m.predict(np.frombuffer(b, dtype="<i2"))
+    quiet = max(quiet - 1, 0)
+    for k, v in scores.items():
+        if v >= threshold and quiet == 0:
+            print(k, round(float(v), 3), flush=True)
+            quiet = 25
+`
+
+func validWakeWord(cfg Config, w *WakeWordConfig) error {
+	if w.Model == "" && len(w.Command) == 0 {
+		return errors.New(`needs a "model" or a "command"`)
+	}
+	if w.Threshold < 0 || w.Threshold > 1 {
+		return fmt.Errorf("threshold %v is not between 0 and 1", w.Threshold)
+	}
+	if w.AutoStop < 0 {
+		return errors.New("auto_stop cannot be negative")
+	}
+	if w.Profile != "" {
+		if _, ok := cfg.Profiles[w.Profile]; !ok {
+			return fmt.Errorf("unknown profile %q", w.Profile)
+		}
+	}
+	return nil
+}
+
+// wakeWordDetector is the detector command, reading PCM on stdin.
+func wakeWordDetector(w *WakeWordConfig) *exec.Cmd {
+	if len(w.Command) > 0 {
+		return exec.Command(w.Command[0], w.Command[1:]...)
+	}
+	python := w.Python
+	if python == "" {
+		python = "python3"
+	}
+	threshold := w.Threshold
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	return exec.Command(python, "-c", openWakeWordScript, expandHome(w.Model), strconv.FormatFloat(threshold, 'f', -1, 64))
+}
+
+// listenWakeWord runs the recorder and the detector, calling heard for each
+// detection, until one of them exits.
+func listenWakeWord(w *WakeWordConfig, heard func()) error {
+	rec := recorder.RawCommand()
+	det := wakeWordDetector(w)
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	rec.Stdout = pw
+	det.Stdin = pr
+	var stderr bytes.Buffer
+	det.Stderr = &stderr
+	out, err := det.StdoutPipe()
+	if err != nil {
+		pr.Close()
+		pw.Close()
+		return err
+	}
+	if err := det.Start(); err != nil {
+		pr.Close()
+		pw.Close()
+		return err
+	}
+	pr.Close()
+	if err := rec.Start(); err != nil {
+		pw.Close()
+		det.Process.Kill()
+		det.Wait()
+		return err
+	}
+	pw.Close()
+
+	sc := bufio.NewScanner(out)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) != "" {
+			heard()
+		}
+	}
+	rec.Process.Kill()
+	rec.Wait()
+	err = det.Wait()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		lines := strings.Split(msg, "\n")
+		return errors.New(lines[len(lines)-1])
+	}
+	if err != nil {
+		return err
+	}
+	return errors.New("the detector stopped")
+}
+
+// watchWakeWord keeps the wake-word listener running for as long as the
+// daemon runs, restarting it when it stops.
+func (d *daemon) watchWakeWord(w *WakeWordConfig) {
+	for warned := false; ; time.Sleep(5 * time.Second) {
+		err := listenWakeWord(w, func() {
+			if err := d.wake(w); err != nil {
+				fmt.Fprintln(os.Stderr, "wake word:", err)
+			}
+		})
+		fmt.Fprintln(os.Stderr, "wake word:", err)
+		if !warned {
+			notifyFailure("Dictation", "Wake word is not available: "+err.Error())
+			warned = true
+		}
+	}
+}
+
+// wake starts a hands-free recording, unless one is already going or
+// being transcribed: the detector also hears the dictation itself, and
+// what it heard meanwhile must not start another once the daemon is free.
+func (d *daemon) wake(w *WakeWordConfig) error {
+	if !d.mu.TryLock() {
+		return nil
+	}
+	defer d.mu.Unlock()
+	if d.continuous != nil || currentStatus().State != stateIdle {
+		return nil
+	}
+	cfg := d.cfg
+	switch {
+	case w.AutoStop > 0:
+		cfg.VAD.AutoStop = w.AutoStop
+	case cfg.VAD.AutoStop <= 0:
+		cfg.VAD.AutoStop = 2
+	}
+	return toggleWith(cfg, "", w.Profile)
+}
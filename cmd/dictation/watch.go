@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// What to do with an audio file once its transcript is written.
+const (
+	afterSidecar = "sidecar"
+	afterDelete  = "delete"
+	afterArchive = "archive"
+)
+
+// WatchRule is one watched directory for `dictate watch`. Audio files that
+// appear in Dir are transcribed with Profile on top of the active settings
+// and the transcript is written next to them in Format.
+type WatchRule struct {
+	Dir     string `json:"dir"`
+	Profile string `json:"profile"`
+	// Format is text (default), srt, vtt or words.
+	Format string `json:"format"`
+	// After is sidecar (default: keep the audio; the transcript file
+	// marks it done), delete, or archive (move it to ArchiveDir, by
+	// default a "processed" directory inside Dir).
+	After      string `json:"after"`
+	ArchiveDir string `json:"archive_dir"`
+}
+
+const watchPoll = 5 * time.Second
+
+var audioExts = map[string]bool{
+	".wav": true, ".mp3": true, ".m4a": true, ".flac": true,
+	".ogg": true, ".opus": true, ".webm": true,
+}
+
+func (r WatchRule) format() string {
+	if r.Format == "" {
+		return formatText
+	}
+	return r.Format
+}
+
+func (r WatchRule) after() string {
+	if r.After == "" {
+		return afterSidecar
+	}
+	return r.After
+}
+
+func (r WatchRule) archiveDir() string {
+	if r.ArchiveDir == "" {
+		return filepath.Join(expandHome(r.Dir), "processed")
+	}
+	return expandHome(r.ArchiveDir)
+}
+
+// outputPath is where the transcript of audio goes for this rule.
+func (r WatchRule) outputPath(audio string) string {
+	ext := "." + r.format()
+	switch r.format() {
+	case formatText:
+		ext = ".txt"
+	case formatWords:
+		ext = ".words.txt"
+	}
+	return strings.TrimSuffix(audio, filepath.Ext(audio)) + ext
+}
+
+func validateWatchRules(cfg Config) error {
+	if len(cfg.Watch) == 0 {
+		return errors.New(`no watch directories configured (add "watch" to the config)`)
+	}
+	for _, r := range cfg.Watch {
+		if r.Dir == "" {
+			return errors.New("watch: rule without dir")
+		}
+		if _, ok := cfg.Profiles[r.Profile]; r.Profile != "" && !ok {
+			return fmt.Errorf("watch %s: unknown profile %q", r.Dir, r.Profile)
+		}
+		switch r.format() {
+		case formatText, formatSRT, formatVTT, formatWords:
+		default:
+			return fmt.Errorf("watch %s: unknown format %q", r.Dir, r.Format)
+		}
+		switch r.after() {
+		case afterSidecar, afterDelete, afterArchive:
+		default:
+			return fmt.Errorf("watch %s: unknown after action %q", r.Dir, r.After)
+		}
+	}
+	return nil
+}
+
+// runWatch implements `dictate watch`: it polls the configured directories
+// and transcribes new audio files until interrupted. A file is picked up
+// once its size has stopped changing between two polls, so recordings
+// that are still being copied in are left alone.
+func runWatch(cfg Config) error {
+	if err := validateWatchRules(cfg); err != nil {
+		return exitError{exitUsage, err}
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	dg := newDigest(time.Duration(cfg.BatchNotifyInterval) * time.Second)
+	defer dg.flush()
+	sizes := map[string]int64{}
+	// failed files are not retried until the next start
+	failed := map[string]bool{}
+	tick := time.NewTicker(watchPoll)
+	defer tick.Stop()
+	for {
+		for _, r := range cfg.Watch {
+			var ready []string
+			for _, path := range watchCandidates(r) {
+				fi, err := os.Stat(path)
+				if err != nil || failed[path] {
+					continue
+				}
+				if prev, ok := sizes[path]; ok && prev == fi.Size() {
+					ready = append(ready, path)
+					delete(sizes, path)
+					continue
+				}
+				sizes[path] = fi.Size()
+			}
+			if len(ready) == 0 {
+				continue
+			}
+			rcfg := cfg
+			if err := applyProfile(&rcfg, r.Profile); err != nil {
+				return err
+			}
+			j, err := startJob(ready)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "warning: could not record job:", err)
+			}
+			for i, path := range ready {
+				err := watchProcess(rcfg, r, path)
+				if err != nil {
+					failed[path] = true
+					fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+					j.Files[i].Status, j.Files[i].Error = jobFailed, err.Error()
+				} else {
+					fmt.Fprintln(os.Stderr, "transcribed", path)
+					j.Files[i].Status = jobDone
+				}
+				dg.add(err)
+			}
+			j.Finished = time.Now()
+			if err := saveJob(j); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: could not record job:", err)
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// watchCandidates lists the audio files in r.Dir that have no transcript
+// yet.
+func watchCandidates(r WatchRule) []string {
+	entries, err := os.ReadDir(expandHome(r.Dir))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || !audioExts[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		path := filepath.Join(expandHome(r.Dir), e.Name())
+		if _, err := os.Stat(r.outputPath(path)); err == nil {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// watchProcess transcribes one file, writes its transcript and applies the
+// rule's after action.
+func watchProcess(cfg Config, r WatchRule, path string) error {
+	switch r.format() {
+	case formatSRT, formatVTT:
+		cfg.wantDetails = true
+	case formatWords:
+		cfg.WordTimestamps = true
+	}
+	if cfg.MetadataSidecar {
+		cfg.wantDetails = true
+	}
+	start := time.Now()
+	res, err := transcribeFile(context.Background(), cfg, path)
+	if err != nil {
+		return err
+	}
+	ppStart := time.Now()
+	text := postProcess(cfg, res.Text)
+	if cfg.MetadataSidecar {
+		if err := writeSidecar(path, newTranscribeResult(cfg, path, text, res, start, ppStart)); err != nil {
+			return err
+		}
+	}
+	if r.format() == formatText {
+		err = writeFileAtomic(r.outputPath(path), []byte(text+"\n"), 0644)
+	} else {
+		err = exportTranscript(os.Stdout, r.format(), path, res, true)
+	}
+	if err != nil {
+		return err
+	}
+	switch r.after() {
+	case afterDelete:
+		return os.Remove(path)
+	case afterArchive:
+		return moveProcessed(path, r.archiveDir(), "")
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A minimal WebSocket server side (RFC 6455), enough for the streaming
+// endpoint of `dictate serve`: the upgrade, masked client frames,
+// fragmented messages, ping and close. No extensions are negotiated.
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsMaxMessage bounds a message from the client.
+const wsMaxMessage = 1 << 20
+
+type wsConn struct {
+	c net.Conn
+	r *bufio.Reader
+	// mu serialises writes, which come from the reader (pongs) and the
+	// sender.
+	mu sync.Mutex
+}
+
+// upgradeWebSocket answers the handshake and takes over the connection.
+// On failure it has already replied with an HTTP error.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		err := errors.New("this endpoint needs a WebSocket")
+		httpError(w, http.StatusBadRequest, err)
+		return nil, err
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		err := errors.New("unsupported WebSocket version")
+		httpError(w, http.StatusUpgradeRequired, err)
+		return nil, err
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		err := errors.New("connection cannot be upgraded")
+		httpError(w, http.StatusInternalServerError, err)
+		return nil, err
+	}
+	c, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if _, err := c.Write([]byte(resp)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &wsConn{c: c, r: brw.Reader}, nil
+}
+
+func (ws *wsConn) Close() error {
+	return ws.c.Close()
+}
+
+// readMessage returns the next text or binary message. Pings are answered
+// on the way; a close frame is answered and reported as io.EOF.
+func (ws *wsConn) readMessage() (byte, []byte, error) {
+	var (
+		op  byte
+		msg []byte
+	)
+	for {
+		fin, frameOp, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frameOp {
+		case wsPing:
+			if err := ws.write(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			code := payload
+			if len(code) > 2 {
+				code = code[:2]
+			}
+			ws.write(wsClose, code)
+			return 0, nil, io.EOF
+		case wsContinuation:
+			if op == 0 {
+				return 0, nil, errors.New("websocket: continuation without a message")
+			}
+		case wsText, wsBinary:
+			if op != 0 {
+				return 0, nil, errors.New("websocket: new message inside a fragmented one")
+			}
+			op = frameOp
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", frameOp)
+		}
+		if len(msg)+len(payload) > wsMaxMessage {
+			return 0, nil, errors.New("websocket: message too large")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+func (ws *wsConn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op := head[0]&0x80 != 0, head[0]&0x0f
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("websocket: unmasked client frame")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > wsMaxMessage {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// write sends one unfragmented, unmasked frame.
+func (ws *wsConn) write(op byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	b := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		b = append(b, byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 126), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint64(append(b, 127), uint64(n))
+	}
+	_, err := ws.c.Write(append(b, payload...))
+	return err
+}
+
+func (ws *wsConn) writeJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.write(wsText, b)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/user/dictation/insert"
+)
+
+// Windows counterparts of the Linux output modes and dialogs. Dialogs go
+// through PowerShell (always installed); recording, sounds, notifications
+// and keystrokes have their Windows side in the recorder, audio, notify and
+// insert packages.
+
+const isWindows = runtime.GOOS == "windows"
+
+// powershell runs script with the given environment variables set, which
+// avoids having to quote user text into the script itself.
+func powershell(script string, env ...string) *exec.Cmd {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+// winInsert handles the window-targeting output modes. It reports whether
+// the text went into the window.
+func winInsert(mode, text string) (bool, error) {
+	switch mode {
+	case outputAuto, outputType, "":
+		if err := insert.Type(text); err == nil || mode == outputType {
+			return err == nil, err
+		}
+		// fall back to the clipboard
+		if err := insert.Copy(text); err != nil {
+			return false, err
+		}
+		err := insert.Paste()
+		return err == nil, err
+	case outputPaste:
+		if err := insert.Copy(text); err != nil {
+			return false, err
+		}
+		err := insert.Paste()
+		return err == nil, err
+	case outputClipboard:
+		if err := insert.Copy(text); err != nil {
+			return false, err
+		}
+		notifyUser("Dictation", "Transcribed text copied to clipboard — please paste into target app")
+		return false, nil
+	}
+	return false, errors.New("unsupported output mode on Windows: " + mode)
+}
+
+// winConfirm shows an OK/Cancel message box and reports whether OK was
+// chosen.
+func winConfirm(msg string) bool {
+	out, err := powershell(`Add-Type -AssemblyName System.Windows.Forms; [System.Windows.Forms.MessageBox]::Show($env:DICTATION_MSG, 'Dictation', 'OKCancel')`,
+		"DICTATION_MSG="+msg).Output()
+	return err == nil && strings.TrimSpace(string(out)) == "OK"
+}
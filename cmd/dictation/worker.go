@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WorkerConfig describes a containerised local transcription server that
+// speaks the OpenAI audio API (e.g. faster-whisper-server, whisper.cpp's
+// server). The daemon starts it, watches it and removes it on exit, so no
+// CUDA libraries have to be installed on the host.
+type WorkerConfig struct {
+	// Runtime is the container CLI: "podman" (default) or "docker".
+	Runtime string `json:"runtime"`
+	Image   string `json:"image"`
+	// Name is the container name, "dictation-worker" by default.
+	Name string `json:"name"`
+	// Port is the host port the server is published on (127.0.0.1 only).
+	Port int `json:"port"`
+	// ContainerPort is the port the server listens on inside the container.
+	ContainerPort int `json:"container_port"`
+	// GPUFlags are passed to "run" verbatim, e.g. ["--gpus=all"] for docker
+	// or ["--device", "nvidia.com/gpu=all"] for podman with CDI.
+	GPUFlags []string `json:"gpu_flags"`
+	// Args are extra "run" arguments (volumes for the model cache, env, ...).
+	Args []string `json:"args"`
+	// HealthPath is polled to decide whether the server is up.
+	HealthPath string `json:"health_path"`
+	// Model is the model the worker serves; defaults depend on the
+	// accelerator.
+	Model string `json:"model"`
+	// Accelerator is "auto" (default), "cuda", "rocm", "vulkan" or "cpu".
+	// With "auto" the best detected one that has an image is used, and
+	// image, GPU flags, model and compute type default accordingly.
+	Accelerator string `json:"accelerator"`
+	// Images overrides the image per accelerator.
+	Images map[string]string `json:"images"`
+	// ComputeType is the model quantization (float16, int8_float16, int8),
+	// passed to the server as WHISPER__COMPUTE_TYPE.
+	ComputeType string `json:"compute_type"`
+	// StartupTimeout is how long to wait for the first healthy response, in
+	// seconds. Model downloads on first start can take a while.
+	StartupTimeout int `json:"startup_timeout"`
+
+	// resolvedAccel is the accelerator resolveBackend settled on.
+	resolvedAccel string
+	// cpuFallback is set when no GPU was found and the CPU model was
+	// chosen automatically.
+	cpuFallback bool
+}
+
+func (w *WorkerConfig) runtime() string {
+	if w.Runtime != "" {
+		return w.Runtime
+	}
+	if !pathExists("podman") && pathExists("docker") {
+		return "docker"
+	}
+	return "podman"
+}
+
+func (w *WorkerConfig) name() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return "dictation-worker"
+}
+
+func (w *WorkerConfig) port() int {
+	if w.Port != 0 {
+		return w.Port
+	}
+	return 8000
+}
+
+func (w *WorkerConfig) baseURL() string {
+	return "http://127.0.0.1:" + strconv.Itoa(w.port())
+}
+
+func (w *WorkerConfig) container(args ...string) *exec.Cmd {
+	return exec.Command(w.runtime(), args...)
+}
+
+// startWorker (re)creates the worker container and waits until it answers
+// its health check.
+func startWorker(w *WorkerConfig) error {
+	if w.Image == "" {
+		return fmt.Errorf("worker: no image for accelerator %q; set worker.image or worker.images", w.resolvedAccel)
+	}
+	if !pathExists(w.runtime()) {
+		return fmt.Errorf("worker: %s not found", w.runtime())
+	}
+	// leftover container from a crashed daemon
+	_ = w.container("rm", "-f", w.name()).Run()
+
+	inner := w.ContainerPort
+	if inner == 0 {
+		inner = w.port()
+	}
+	args := []string{"run", "-d", "--rm", "--name", w.name(),
+		"-p", fmt.Sprintf("127.0.0.1:%d:%d", w.port(), inner)}
+	args = append(args, w.GPUFlags...)
+	if w.ComputeType != "" {
+		args = append(args, "-e", "WHISPER__COMPUTE_TYPE="+w.ComputeType)
+	}
+	args = append(args, w.Args...)
+	args = append(args, w.Image)
+	cmd := w.container(args...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("worker: %s run: %v", w.runtime(), err)
+	}
+
+	timeout := time.Duration(w.StartupTimeout) * time.Second
+	if timeout == 0 {
+		timeout = 120 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for !workerHealthy(w) {
+		if time.Now().After(deadline) {
+			stopWorker(w)
+			return fmt.Errorf("worker: not healthy after %s", timeout)
+		}
+		time.Sleep(time.Second)
+	}
+	return nil
+}
+
+func stopWorker(w *WorkerConfig) {
+	_ = w.container("rm", "-f", w.name()).Run()
+}
+
+func workerHealthy(w *WorkerConfig) bool {
+	path := w.HealthPath
+	if path == "" {
+		path = "/health"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	cli := &http.Client{Timeout: 2 * time.Second}
+	resp, err := cli.Get(w.baseURL() + path)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < 300
+}
+
+// superviseWorker restarts the worker whenever it stops answering, until
+// done is closed.
+func superviseWorker(w *WorkerConfig, done <-chan struct{}) {
+	t := time.NewTicker(30 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+		if workerHealthy(w) {
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "worker unhealthy, restarting")
+		if err := startWorker(w); err != nil {
+			notifyFailure("Dictation", "Transcription worker failed: "+err.Error())
+		}
+	}
+}
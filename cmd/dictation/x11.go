@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal X11 client, enough to grab hotkeys on the root window: the
+// connection setup, GetKeyboardMapping, GrabKey and key events. See the X
+// Window System Protocol for the wire format; everything here is
+// little-endian.
+
+// X11 core modifier masks.
+const (
+	x11Shift   = 1 << 0
+	x11Lock    = 1 << 1
+	x11Control = 1 << 2
+	x11Mod1    = 1 << 3 // Alt
+	x11Mod2    = 1 << 4 // Num Lock
+	x11Mod4    = 1 << 6 // Super
+)
+
+// X11 event codes.
+const (
+	x11Error      = 0
+	x11Reply      = 1
+	x11KeyPress   = 2
+	x11KeyRelease = 3
+)
+
+type x11Conn struct {
+	c          net.Conn
+	r          *bufio.Reader
+	root       uint32
+	minKeycode byte
+	maxKeycode byte
+}
+
+// x11Event is a key event or an error: for errors, detail is the error
+// code.
+type x11Event struct {
+	code   byte
+	detail byte
+	time   uint32
+	state  uint16
+}
+
+// x11Socket returns the unix socket and display number for $DISPLAY. Only
+// local displays are supported.
+func x11Socket() (string, string, error) {
+	d := os.Getenv("DISPLAY")
+	host, rest, ok := strings.Cut(d, ":")
+	if !ok || (host != "" && host != "unix") {
+		return "", "", fmt.Errorf("DISPLAY %q is not a local display", d)
+	}
+	num, _, _ := strings.Cut(rest, ".")
+	if _, err := strconv.Atoi(num); err != nil {
+		return "", "", fmt.Errorf("DISPLAY %q is not a local display", d)
+	}
+	return "/tmp/.X11-unix/X" + num, num, nil
+}
+
+// x11Cookie finds the MIT-MAGIC-COOKIE-1 for the display in the
+// Xauthority file; none is fine where the server does not ask for one.
+func x11Cookie(display string) (name string, data []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, ".Xauthority")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	field := func() ([]byte, error) {
+		var n uint16
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	hostname, _ := os.Hostname()
+	for {
+		var family uint16
+		if err := binary.Read(r, binary.BigEndian, &family); err != nil {
+			return "", nil
+		}
+		addr, err1 := field()
+		num, err2 := field()
+		n, err3 := field()
+		d, err4 := field()
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			return "", nil
+		}
+		// 256 is FamilyLocal, 65535 FamilyWild
+		local := family == 256 && string(addr) == hostname || family == 65535
+		if local && (len(num) == 0 || string(num) == display) && string(n) == "MIT-MAGIC-COOKIE-1" {
+			return string(n), d
+		}
+	}
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+func dialX11() (*x11Conn, error) {
+	socket, display, err := x11Socket()
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	x := &x11Conn{c: c, r: bufio.NewReader(c)}
+	if err := x.setup(display); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *x11Conn) setup(display string) error {
+	x.c.SetDeadline(time.Now().Add(5 * time.Second))
+	defer x.c.SetDeadline(time.Time{})
+	name, data := x11Cookie(display)
+	req := []byte{'l', 0}
+	req = binary.LittleEndian.AppendUint16(req, 11)
+	req = binary.LittleEndian.AppendUint16(req, 0)
+	req = binary.LittleEndian.AppendUint16(req, uint16(len(name)))
+	req = binary.LittleEndian.AppendUint16(req, uint16(len(data)))
+	req = append(req, 0, 0)
+	req = append(append(req, name...), make([]byte, pad4(len(name)))...)
+	req = append(append(req, data...), make([]byte, pad4(len(data)))...)
+	if _, err := x.c.Write(req); err != nil {
+		return err
+	}
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(x.r, head); err != nil {
+		return err
+	}
+	body := make([]byte, 4*int(binary.LittleEndian.Uint16(head[6:])))
+	if _, err := io.ReadFull(x.r, body); err != nil {
+		return err
+	}
+	if head[0] != 1 {
+		reason := body
+		if n := int(head[1]); n <= len(body) {
+			reason = body[:n]
+		}
+		return fmt.Errorf("X server refused the connection: %s", strings.TrimSpace(string(reason)))
+	}
+	if len(body) < 32 {
+		return errors.New("X11: short setup reply")
+	}
+	vendorLen := int(binary.LittleEndian.Uint16(body[16:]))
+	formats := int(body[21])
+	x.minKeycode, x.maxKeycode = body[26], body[27]
+	screen := 32 + vendorLen + pad4(vendorLen) + 8*formats
+	if len(body) < screen+4 {
+		return errors.New("X11: short setup reply")
+	}
+	x.root = binary.LittleEndian.Uint32(body[screen:])
+	return nil
+}
+
+func (x *x11Conn) Close() error {
+	return x.c.Close()
+}
+
+// keycodes maps keysyms to the keycodes that produce them.
+func (x *x11Conn) keycodes() (map[uint32][]byte, error) {
+	count := x.maxKeycode - x.minKeycode + 1
+	req := []byte{101, 0, 2, 0, x.minKeycode, count, 0, 0}
+	if _, err := x.c.Write(req); err != nil {
+		return nil, err
+	}
+	head := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(x.r, head); err != nil {
+			return nil, err
+		}
+		if head[0] == x11Error {
+			return nil, fmt.Errorf("X11: GetKeyboardMapping failed with error %d", head[1])
+		}
+		if head[0] == x11Reply {
+			break
+		}
+	}
+	perKey := int(head[1])
+	body := make([]byte, 4*binary.LittleEndian.Uint32(head[4:]))
+	if _, err := io.ReadFull(x.r, body); err != nil {
+		return nil, err
+	}
+	m := map[uint32][]byte{}
+	for i := 0; i < int(count) && (i+1)*perKey*4 <= len(body); i++ {
+		for j := 0; j < perKey; j++ {
+			if sym := binary.LittleEndian.Uint32(body[(i*perKey+j)*4:]); sym != 0 {
+				m[sym] = append(m[sym], x.minKeycode+byte(i))
+			}
+		}
+	}
+	return m, nil
+}
+
+// grabKey grabs keycode with mods on the root window, also with Caps Lock
+// and Num Lock on so they don't get in the way.
+func (x *x11Conn) grabKey(keycode byte, mods uint16) error {
+	for _, extra := range []uint16{0, x11Lock, x11Mod2, x11Lock | x11Mod2} {
+		req := []byte{33, 1, 4, 0}
+		req = binary.LittleEndian.AppendUint32(req, x.root)
+		req = binary.LittleEndian.AppendUint16(req, mods|extra)
+		req = append(req, keycode, 1, 1, 0, 0, 0)
+		if _, err := x.c.Write(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next reads the next key event or error, skipping everything else.
+func (x *x11Conn) next() (x11Event, error) {
+	b := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(x.r, b); err != nil {
+			return x11Event{}, err
+		}
+		code := b[0] &^ 0x80
+		switch code {
+		case x11Reply:
+			extra := 4 * int(binary.LittleEndian.Uint32(b[4:]))
+			if _, err := io.CopyN(io.Discard, x.r, int64(extra)); err != nil {
+				return x11Event{}, err
+			}
+		case x11Error, x11KeyPress, x11KeyRelease:
+			return x11Event{
+				code:   code,
+				detail: b[1],
+				time:   binary.LittleEndian.Uint32(b[4:]),
+				state:  binary.LittleEndian.Uint16(b[28:]),
+			}, nil
+		}
+	}
+}
@@ -1,3 +1,18 @@
 module github.com/user/dictation
 
-go 1.20
+go 1.24.0
+
+require (
+	github.com/ebitengine/oto/v3 v3.3.3
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	google.golang.org/grpc v1.76.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	github.com/ebitengine/purego v0.8.0 // indirect
+	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250804133106-a7a43d27e69b // indirect
+)
@@ -0,0 +1,164 @@
+// Package insert puts text into the focused window: as keystrokes, or
+// through the clipboard and a simulated paste. On Linux it uses xdotool and
+// the X or Wayland clipboard tools, on macOS System Events and pbcopy, on
+// Windows SendInput and PowerShell.
+//
+// Type sends the text as it is. Keyboard layouts and input methods that
+// turn keystrokes into something else are the caller's business; the
+// dictation command switches them around typing.
+package insert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	isMac     = runtime.GOOS == "darwin"
+	isWindows = runtime.GOOS == "windows"
+)
+
+func have(tool string) bool {
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
+// Xdotool runs xdotool with args, its errors going to stderr.
+func Xdotool(args ...string) error {
+	if !have("xdotool") {
+		return errors.New("xdotool not found")
+	}
+	cmd := exec.Command("xdotool", args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// powershell runs script with the given environment variables set, which
+// avoids having to quote user text into the script itself.
+func powershell(script string, env ...string) *exec.Cmd {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+// osascript runs an AppleScript. The calling terminal (or the hotkey tool)
+// needs the Accessibility permission for System Events.
+func osascript(script string) error {
+	return exec.Command("osascript", "-e", script).Run()
+}
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// Type types text into the focused window. Typing through System Events
+// mangles non-ASCII text on macOS; SendInput on Windows sends Unicode key
+// events, which do not depend on the layout.
+func Type(text string) error {
+	switch {
+	case isMac:
+		return osascript(`tell application "System Events" to keystroke ` + appleScriptString(text))
+	case isWindows:
+		return sendText(text)
+	}
+	return Xdotool("type", "--clearmodifiers", text)
+}
+
+// Copy puts text on the clipboard using whichever tool is available for
+// the current session.
+func Copy(text string) error {
+	var cmd *exec.Cmd
+	switch {
+	case isMac:
+		cmd = exec.Command("pbcopy")
+	case isWindows:
+		cmd = powershell(`[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())`)
+	case os.Getenv("WAYLAND_DISPLAY") != "" && have("wl-copy"):
+		cmd = exec.Command("wl-copy")
+	case have("xclip"):
+		cmd = exec.Command("xclip", "-selection", "clipboard")
+	case have("xsel"):
+		cmd = exec.Command("xsel", "--clipboard", "--input")
+	case have("wl-copy"):
+		cmd = exec.Command("wl-copy")
+	default:
+		return fmt.Errorf("no clipboard tool found; install wl-clipboard, xclip or xsel")
+	}
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// Paste sends Ctrl+V (Cmd+V on macOS) to the focused window.
+func Paste() error {
+	switch {
+	case isMac:
+		return osascript(`tell application "System Events" to keystroke "v" using command down`)
+	case isWindows:
+		return sendPaste()
+	}
+	if !have("xdotool") {
+		return errors.New("xdotool not found; cannot simulate paste")
+	}
+	return Xdotool("key", "--clearmodifiers", "ctrl+v")
+}
+
+// Backspace deletes n characters before the cursor, or n words when word
+// is set.
+func Backspace(n int, word bool) error {
+	if n <= 0 {
+		return nil
+	}
+	switch {
+	case isMac:
+		key := "key code 51"
+		if word {
+			key += " using option down"
+		}
+		return osascript(fmt.Sprintf("tell application \"System Events\" to repeat %d times\n%s\nend repeat", n, key))
+	case isWindows:
+		return sendBackspace(n, word)
+	}
+	key := "BackSpace"
+	if word {
+		key = "ctrl+BackSpace"
+	}
+	return Xdotool("key", "--clearmodifiers", "--repeat", fmt.Sprint(n), "--delay", "5", key)
+}
+
+// Selection returns the text selected in the focused window: the X or
+// Wayland PRIMARY selection on Linux, a simulated copy elsewhere.
+func Selection() (string, error) {
+	var out []byte
+	var err error
+	switch {
+	case isMac:
+		if err := osascript(`tell application "System Events" to keystroke "c" using command down`); err != nil {
+			return "", err
+		}
+		time.Sleep(200 * time.Millisecond)
+		out, err = exec.Command("pbpaste").Output()
+	case isWindows:
+		if err := sendCopy(); err != nil {
+			return "", err
+		}
+		time.Sleep(200 * time.Millisecond)
+		out, err = powershell(`[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard -Raw`).Output()
+		return strings.TrimRight(string(out), "\r\n"), err
+	case os.Getenv("WAYLAND_DISPLAY") != "" && have("wl-paste"):
+		out, err = exec.Command("wl-paste", "--primary", "--no-newline").Output()
+	case have("xclip"):
+		out, err = exec.Command("xclip", "-o", "-selection", "primary").Output()
+	case have("xsel"):
+		out, err = exec.Command("xsel", "--primary", "--output").Output()
+	default:
+		return "", errors.New("no clipboard tool found; install wl-clipboard, xclip or xsel")
+	}
+	return string(out), err
+}
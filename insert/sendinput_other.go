@@ -0,0 +1,15 @@
+//go:build !windows
+
+package insert
+
+import "errors"
+
+var errNoSendInput = errors.New("SendInput is only available on Windows")
+
+func sendText(text string) error { return errNoSendInput }
+
+func sendPaste() error { return errNoSendInput }
+
+func sendCopy() error { return errNoSendInput }
+
+func sendBackspace(n int, word bool) error { return errNoSendInput }
@@ -0,0 +1,101 @@
+package insert
+
+import (
+	"errors"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var procSendInput = syscall.NewLazyDLL("user32.dll").NewProc("SendInput")
+
+const (
+	inputKeyboard    = 1
+	keyeventfKeyUp   = 0x0002
+	keyeventfUnicode = 0x0004
+	vkBack           = 0x08
+	vkControl        = 0x11
+	vkC              = 0x43
+	vkV              = 0x56
+)
+
+// keyboardInput mirrors the Win32 INPUT struct for keyboard events. The
+// trailing padding makes it as large as the MOUSEINPUT member of the union.
+type keyboardInput struct {
+	typ uint32
+	ki  struct {
+		vk    uint16
+		scan  uint16
+		flags uint32
+		time  uint32
+		extra uintptr
+	}
+	_ [8]byte
+}
+
+func sendInputs(in []keyboardInput) error {
+	if len(in) == 0 {
+		return nil
+	}
+	n, _, err := procSendInput.Call(uintptr(len(in)), uintptr(unsafe.Pointer(&in[0])), unsafe.Sizeof(in[0]))
+	if int(n) != len(in) {
+		return err
+	}
+	return nil
+}
+
+// sendText types text into the focused window as Unicode key events, which
+// does not depend on the active keyboard layout.
+func sendText(text string) error {
+	var in []keyboardInput
+	for _, u := range utf16.Encode([]rune(text)) {
+		for _, flags := range []uint32{keyeventfUnicode, keyeventfUnicode | keyeventfKeyUp} {
+			var k keyboardInput
+			k.typ = inputKeyboard
+			k.ki.scan = u
+			k.ki.flags = flags
+			in = append(in, k)
+		}
+	}
+	if err := sendInputs(in); err != nil {
+		return errors.New("SendInput failed: " + err.Error())
+	}
+	return nil
+}
+
+func vkey(vk uint16, flags uint32) keyboardInput {
+	var k keyboardInput
+	k.typ = inputKeyboard
+	k.ki.vk = vk
+	k.ki.flags = flags
+	return k
+}
+
+// sendPaste presses Ctrl+V.
+func sendPaste() error {
+	return sendInputs([]keyboardInput{
+		vkey(vkControl, 0), vkey(vkV, 0), vkey(vkV, keyeventfKeyUp), vkey(vkControl, keyeventfKeyUp),
+	})
+}
+
+// sendCopy presses Ctrl+C.
+func sendCopy() error {
+	return sendInputs([]keyboardInput{
+		vkey(vkControl, 0), vkey(vkC, 0), vkey(vkC, keyeventfKeyUp), vkey(vkControl, keyeventfKeyUp),
+	})
+}
+
+// sendBackspace presses Backspace n times, Ctrl+Backspace when word is set.
+func sendBackspace(n int, word bool) error {
+	var in []keyboardInput
+	if word {
+		in = append(in, vkey(vkControl, 0))
+	}
+	for i := 0; i < n; i++ {
+		in = append(in, vkey(vkBack, 0), vkey(vkBack, keyeventfKeyUp))
+	}
+	if word {
+		in = append(in, vkey(vkControl, keyeventfKeyUp))
+	}
+	return sendInputs(in)
+}
@@ -0,0 +1,461 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal client for the D-Bus session bus, enough to talk to the
+// notification daemon without libnotify's notify-send: method calls and
+// signals whose bodies hold basic types, strings arrays and a{sv} dicts.
+// See the D-Bus specification for the wire format.
+
+// dbusTimeout bounds every method call, including the bus starting a
+// notification daemon on demand.
+const dbusTimeout = 5 * time.Second
+
+// Message types.
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+	dbusSignal       = 4
+)
+
+// Header field codes.
+const (
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldErrorName   = 4
+	dbusFieldReplySerial = 5
+	dbusFieldDestination = 6
+	dbusFieldSender      = 7
+	dbusFieldSignature   = 8
+)
+
+// dbusPath and dbusSig marshal as an object path and a signature.
+type (
+	dbusPath string
+	dbusSig  string
+)
+
+type dbusMsg struct {
+	typ         byte
+	serial      uint32
+	replySerial uint32
+	path        string
+	iface       string
+	member      string
+	errName     string
+	sender      string
+	body        []any
+}
+
+type dbusConn struct {
+	c       net.Conn
+	r       *bufio.Reader
+	serial  uint32
+	signals []dbusMsg
+}
+
+// sessionBusAddresses lists where the session bus may be listening.
+func sessionBusAddresses() []string {
+	if a := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); a != "" {
+		return strings.Split(a, ";")
+	}
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		return []string{"unix:path=" + filepath.Join(d, "bus")}
+	}
+	return nil
+}
+
+func dialSessionBus() (*dbusConn, error) {
+	var lastErr error = errors.New("no session bus (DBUS_SESSION_BUS_ADDRESS is not set)")
+	for _, addr := range sessionBusAddresses() {
+		transport, params, _ := strings.Cut(addr, ":")
+		if transport != "unix" {
+			continue
+		}
+		var socket string
+		for _, kv := range strings.Split(params, ",") {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "path":
+				socket = v
+			case "abstract":
+				socket = "@" + v
+			}
+		}
+		if socket == "" {
+			continue
+		}
+		c, err := net.DialTimeout("unix", socket, dbusTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn := &dbusConn{c: c, r: bufio.NewReader(c)}
+		if err := conn.hello(); err != nil {
+			c.Close()
+			lastErr = err
+			continue
+		}
+		return conn, nil
+	}
+	return nil, lastErr
+}
+
+// hello authenticates as the current user and registers on the bus.
+func (c *dbusConn) hello() error {
+	c.c.SetDeadline(time.Now().Add(dbusTimeout))
+	defer c.c.SetDeadline(time.Time{})
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := io.WriteString(c.c, "\x00AUTH EXTERNAL "+uid+"\r\n"); err != nil {
+		return err
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("session bus refused authentication: %s", strings.TrimSpace(line))
+	}
+	if _, err := io.WriteString(c.c, "BEGIN\r\n"); err != nil {
+		return err
+	}
+	_, err = c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "")
+	return err
+}
+
+func (c *dbusConn) Close() error {
+	return c.c.Close()
+}
+
+// call invokes a method and returns the reply's body. Signals that arrive
+// meanwhile are kept for nextSignal.
+func (c *dbusConn) call(dest, path, iface, member, sig string, args ...any) ([]any, error) {
+	c.serial++
+	serial := c.serial
+	fields := []dbusField{
+		{dbusFieldPath, dbusPath(path)},
+		{dbusFieldInterface, iface},
+		{dbusFieldMember, member},
+		{dbusFieldDestination, dest},
+	}
+	if sig != "" {
+		fields = append(fields, dbusField{dbusFieldSignature, dbusSig(sig)})
+	}
+	c.c.SetDeadline(time.Now().Add(dbusTimeout))
+	defer c.c.SetDeadline(time.Time{})
+	if _, err := c.c.Write(marshalDBus(dbusMethodCall, serial, fields, args)); err != nil {
+		return nil, err
+	}
+	for {
+		m, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case m.typ == dbusSignal:
+			c.signals = append(c.signals, m)
+		case m.replySerial != serial:
+		case m.typ == dbusError:
+			if len(m.body) > 0 {
+				return nil, fmt.Errorf("%s: %v", m.errName, m.body[0])
+			}
+			return nil, errors.New(m.errName)
+		default:
+			return m.body, nil
+		}
+	}
+}
+
+// nextSignal waits for a signal the connection has subscribed to.
+func (c *dbusConn) nextSignal() (dbusMsg, error) {
+	if len(c.signals) > 0 {
+		m := c.signals[0]
+		c.signals = c.signals[1:]
+		return m, nil
+	}
+	for {
+		m, err := c.read()
+		if err != nil {
+			return m, err
+		}
+		if m.typ == dbusSignal {
+			return m, nil
+		}
+	}
+}
+
+type dbusField struct {
+	code  byte
+	value any
+}
+
+func marshalDBus(typ byte, serial uint32, fields []dbusField, args []any) []byte {
+	var body dbusEnc
+	for _, a := range args {
+		body.value(a)
+	}
+	var e dbusEnc
+	e.b = append(e.b, 'l', typ, 0, 1)
+	e.u32(uint32(len(body.b)))
+	e.u32(serial)
+	e.array(8, func() {
+		for _, f := range fields {
+			e.align(8)
+			e.b = append(e.b, f.code)
+			e.variant(f.value)
+		}
+	})
+	e.align(8)
+	return append(e.b, body.b...)
+}
+
+// dbusEnc marshals little-endian; offsets, like the alignment they
+// decide, count from the start of the message or body.
+type dbusEnc struct {
+	b []byte
+}
+
+func (e *dbusEnc) align(n int) {
+	for len(e.b)%n != 0 {
+		e.b = append(e.b, 0)
+	}
+}
+
+func (e *dbusEnc) u32(v uint32) {
+	e.align(4)
+	e.b = binary.LittleEndian.AppendUint32(e.b, v)
+}
+
+func (e *dbusEnc) str(s string) {
+	e.u32(uint32(len(s)))
+	e.b = append(append(e.b, s...), 0)
+}
+
+func (e *dbusEnc) sig(s string) {
+	e.b = append(append(append(e.b, byte(len(s))), s...), 0)
+}
+
+// array writes the length and, after the padding to the elements'
+// alignment, what elems appends.
+func (e *dbusEnc) array(elemAlign int, elems func()) {
+	e.u32(0)
+	at := len(e.b) - 4
+	e.align(elemAlign)
+	start := len(e.b)
+	elems()
+	binary.LittleEndian.PutUint32(e.b[at:], uint32(len(e.b)-start))
+}
+
+func (e *dbusEnc) value(v any) {
+	switch v := v.(type) {
+	case byte:
+		e.b = append(e.b, v)
+	case bool:
+		b := uint32(0)
+		if v {
+			b = 1
+		}
+		e.u32(b)
+	case int32:
+		e.u32(uint32(v))
+	case uint32:
+		e.u32(v)
+	case string:
+		e.str(v)
+	case dbusPath:
+		e.str(string(v))
+	case dbusSig:
+		e.sig(string(v))
+	case []string:
+		e.array(4, func() {
+			for _, s := range v {
+				e.str(s)
+			}
+		})
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.array(8, func() {
+			for _, k := range keys {
+				e.align(8)
+				e.str(k)
+				e.variant(v[k])
+			}
+		})
+	default:
+		panic(fmt.Sprintf("dbus: cannot marshal %T", v))
+	}
+}
+
+func (e *dbusEnc) variant(v any) {
+	var s string
+	switch v.(type) {
+	case byte:
+		s = "y"
+	case bool:
+		s = "b"
+	case int32:
+		s = "i"
+	case uint32:
+		s = "u"
+	case string:
+		s = "s"
+	case dbusPath:
+		s = "o"
+	case dbusSig:
+		s = "g"
+	case []string:
+		s = "as"
+	default:
+		panic(fmt.Sprintf("dbus: cannot marshal %T in a variant", v))
+	}
+	e.sig(s)
+	e.value(v)
+}
+
+// read reads the next message. Only bodies of basic types are decoded; a
+// body that goes on with anything else is cut off there.
+func (c *dbusConn) read() (dbusMsg, error) {
+	var m dbusMsg
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(c.r, fixed); err != nil {
+		return m, err
+	}
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return m, errors.New("dbus: bad message")
+	}
+	bodyLen, fieldsLen := order.Uint32(fixed[4:]), order.Uint32(fixed[12:])
+	headerLen := (16 + int(fieldsLen) + 7) &^ 7
+	if fieldsLen > 1<<20 || bodyLen > 1<<24 {
+		return m, errors.New("dbus: message too large")
+	}
+	msg := make([]byte, headerLen+int(bodyLen))
+	copy(msg, fixed)
+	if _, err := io.ReadFull(c.r, msg[16:]); err != nil {
+		return m, err
+	}
+	m.typ, m.serial = fixed[1], order.Uint32(fixed[8:])
+
+	var sig string
+	d := &dbusDec{b: msg[:16+fieldsLen], off: 16, order: order}
+	for d.off < len(d.b) {
+		d.align(8)
+		code := d.byte()
+		v, ok := d.variant()
+		if !ok {
+			return m, errors.New("dbus: bad header")
+		}
+		switch code {
+		case dbusFieldPath:
+			m.path, _ = v.(string)
+		case dbusFieldInterface:
+			m.iface, _ = v.(string)
+		case dbusFieldMember:
+			m.member, _ = v.(string)
+		case dbusFieldErrorName:
+			m.errName, _ = v.(string)
+		case dbusFieldSender:
+			m.sender, _ = v.(string)
+		case dbusFieldReplySerial:
+			m.replySerial, _ = v.(uint32)
+		case dbusFieldSignature:
+			sig, _ = v.(string)
+		}
+	}
+	d = &dbusDec{b: msg[headerLen:], order: order}
+	for _, t := range sig {
+		v, ok := d.basic(byte(t))
+		if !ok {
+			break
+		}
+		m.body = append(m.body, v)
+	}
+	return m, nil
+}
+
+type dbusDec struct {
+	b     []byte
+	off   int
+	order binary.ByteOrder
+	bad   bool
+}
+
+func (d *dbusDec) align(n int) {
+	d.off = (d.off + n - 1) / n * n
+}
+
+func (d *dbusDec) take(n int) []byte {
+	if d.bad || d.off+n > len(d.b) {
+		d.bad = true
+		return make([]byte, n)
+	}
+	p := d.b[d.off : d.off+n]
+	d.off += n
+	return p
+}
+
+func (d *dbusDec) byte() byte {
+	return d.take(1)[0]
+}
+
+func (d *dbusDec) u32() uint32 {
+	d.align(4)
+	return d.order.Uint32(d.take(4))
+}
+
+func (d *dbusDec) variant() (any, bool) {
+	n := int(d.byte())
+	s := string(d.take(n + 1)[:n])
+	if len(s) != 1 {
+		return nil, false
+	}
+	return d.basic(s[0])
+}
+
+func (d *dbusDec) basic(t byte) (any, bool) {
+	var v any
+	switch t {
+	case 'y':
+		v = d.byte()
+	case 'b':
+		v = d.u32() != 0
+	case 'i':
+		v = int32(d.u32())
+	case 'u':
+		v = d.u32()
+	case 's', 'o':
+		n := int(d.u32())
+		v = string(d.take(n + 1)[:n])
+	case 'g':
+		n := int(d.byte())
+		v = string(d.take(n + 1)[:n])
+	default:
+		return nil, false
+	}
+	return v, !d.bad
+}
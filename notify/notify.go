@@ -0,0 +1,200 @@
+// Package notify shows desktop notifications. On Linux they go straight to
+// org.freedesktop.Notifications over the session bus, through a minimal
+// D-Bus client rather than libnotify; macOS and Windows get a simpler
+// title-and-body notification through the tools they ship.
+package notify
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	notifyDest  = "org.freedesktop.Notifications"
+	notifyPath  = "/org/freedesktop/Notifications"
+	notifyIface = "org.freedesktop.Notifications"
+)
+
+// Urgency is a level of the notification spec.
+type Urgency byte
+
+// Urgency levels.
+const (
+	Low      Urgency = 0
+	Normal   Urgency = 1
+	Critical Urgency = 2
+)
+
+// Icons from the freedesktop icon naming spec, which every theme has.
+const (
+	IconDictation = "audio-input-microphone"
+	IconFailure   = "dialog-error"
+)
+
+// Notification is one desktop notification.
+type Notification struct {
+	// AppName and DesktopEntry identify the sender; they default to
+	// "Dictation" and "dictation".
+	AppName      string
+	DesktopEntry string
+
+	Summary string
+	Body    string
+	Icon    string
+	Urgency Urgency
+	// ReplaceID updates that notification in place instead of showing a
+	// new one.
+	ReplaceID uint32
+	// Timeout is in milliseconds: -1 leaves it to the daemon, 0 keeps the
+	// notification up until it is closed.
+	Timeout int32
+	// Actions alternate keys and labels, as in {"type", "Type"}.
+	Actions []string
+}
+
+func (n Notification) send(c *dbusConn) (uint32, error) {
+	icon := n.Icon
+	if icon == "" {
+		icon = IconDictation
+	}
+	app, entry := n.AppName, n.DesktopEntry
+	if app == "" {
+		app = "Dictation"
+	}
+	if entry == "" {
+		entry = "dictation"
+	}
+	hints := map[string]any{"urgency": byte(n.Urgency), "desktop-entry": entry}
+	actions := n.Actions
+	if actions == nil {
+		actions = []string{}
+	}
+	body, err := c.call(notifyDest, notifyPath, notifyIface, "Notify", "susssasa{sv}i",
+		app, n.ReplaceID, icon, n.Summary, n.Body, actions, hints, n.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	if len(body) == 0 {
+		return 0, fmt.Errorf("notify: empty reply")
+	}
+	id, _ := body[0].(uint32)
+	return id, nil
+}
+
+// Send shows n and returns its id for replacing or closing it.
+func Send(n Notification) (uint32, error) {
+	c, err := dialSessionBus()
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	return n.send(c)
+}
+
+// Close takes the notification with the given id down.
+func Close(id uint32) error {
+	c, err := dialSessionBus()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, err = c.call(notifyDest, notifyPath, notifyIface, "CloseNotification", "u", id)
+	return err
+}
+
+// WaitAction shows n and waits until one of its actions is
+// clicked, returning its key, or until it is dismissed or expires,
+// returning "".
+func WaitAction(n Notification) (string, error) {
+	c, err := dialSessionBus()
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	// subscribe before showing it, so a quick click is not missed
+	match := "type='signal',interface='" + notifyIface + "'"
+	if _, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", match); err != nil {
+		return "", err
+	}
+	id, err := n.send(c)
+	if err != nil {
+		return "", err
+	}
+	for {
+		m, err := c.nextSignal()
+		if err != nil {
+			return "", err
+		}
+		if m.iface != notifyIface || len(m.body) < 2 || m.body[0] != id {
+			continue
+		}
+		switch m.member {
+		case "ActionInvoked":
+			key, _ := m.body[1].(string)
+			return key, nil
+		case "NotificationClosed":
+			return "", nil
+		}
+	}
+}
+
+// Server names the running notification daemon.
+func Server() (string, error) {
+	c, err := dialSessionBus()
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	body, err := c.call(notifyDest, notifyPath, notifyIface, "GetServerInformation", "")
+	if err != nil {
+		return "", err
+	}
+	if len(body) < 3 {
+		return "", fmt.Errorf("notify: short reply")
+	}
+	return fmt.Sprintf("%v %v (%v)", body[0], body[2], body[1]), nil
+}
+
+// Post shows a plain notification on any platform: through the
+// notification daemon on Linux, where critical ones get the failure icon
+// and usually stay up until dismissed, terminal-notifier or AppleScript on
+// macOS and a toast on Windows.
+func Post(urgency Urgency, title, body string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("terminal-notifier"); err == nil {
+			return exec.Command("terminal-notifier", "-title", title, "-message", body).Run()
+		}
+		script := "display notification " + appleScriptString(body) + " with title " + appleScriptString(title)
+		return exec.Command("osascript", "-e", script).Run()
+	case "windows":
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+		cmd.Env = append(os.Environ(), "DICTATION_TITLE="+title, "DICTATION_BODY="+body)
+		return cmd.Run()
+	}
+	n := Notification{Summary: title, Body: body, Urgency: urgency, Timeout: -1}
+	if urgency == Critical {
+		n.Icon = IconFailure
+	}
+	_, err := Send(n)
+	return err
+}
+
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode($env:DICTATION_TITLE)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode($env:DICTATION_BODY)) > $null
+$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($t))
+`
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,188 @@
+package postprocess
+
+import (
+	"strings"
+	"unicode"
+)
+
+// codeSymbol is a spoken token in code mode and how it attaches to its
+// neighbours: glueLeft drops the space before it, glueRight the one after.
+type codeSymbol struct {
+	text                string
+	glueLeft, glueRight bool
+}
+
+// codeSymbols maps spoken phrases to code tokens. Phrases are matched
+// longest first, so "equals equals" wins over "equals".
+var codeSymbols = map[string]codeSymbol{
+	"equals":            {"=", false, false},
+	"equals equals":     {"==", false, false},
+	"double equals":     {"==", false, false},
+	"triple equals":     {"===", false, false},
+	"not equals":        {"!=", false, false},
+	"plus equals":       {"+=", false, false},
+	"minus equals":      {"-=", false, false},
+	"colon equals":      {":=", false, false},
+	"plus":              {"+", false, false},
+	"minus":             {"-", false, false},
+	"times":             {"*", false, false},
+	"star":              {"*", false, false},
+	"slash":             {"/", false, false},
+	"percent":           {"%", false, false},
+	"greater than":      {">", false, false},
+	"less than":         {"<", false, false},
+	"greater or equal":  {">=", false, false},
+	"less or equal":     {"<=", false, false},
+	"and and":           {"&&", false, false},
+	"or or":             {"||", false, false},
+	"pipe":              {"|", false, false},
+	"ampersand":         {"&", false, false},
+	"arrow":             {"->", false, false},
+	"fat arrow":         {"=>", false, false},
+	"bang":              {"!", false, true},
+	"not":               {"!", false, true},
+	"open paren":        {"(", true, true},
+	"close paren":       {")", true, false},
+	"open bracket":      {"[", true, true},
+	"close bracket":     {"]", true, false},
+	"open brace":        {"{", false, false},
+	"close brace":       {"}", false, false},
+	"open angle":        {"<", true, true},
+	"close angle":       {">", true, false},
+	"dot":               {".", true, true},
+	"comma":             {",", true, false},
+	"semicolon":         {";", true, false},
+	"colon":             {":", true, false},
+	"underscore":        {"_", true, true},
+	"hash":              {"#", false, true},
+	"dollar":            {"$", false, true},
+	"at sign":           {"@", false, true},
+	"quote":             {`"`, false, false},
+	"single quote":      {"'", false, false},
+	"backtick":          {"`", false, false},
+	"new line":          {"\n", true, true},
+	"space":             {" ", true, true},
+	"increment":         {"++", true, false},
+	"decrement":         {"--", true, false},
+	"question mark":     {"?", true, false},
+	"exclamation mark":  {"!", true, false},
+	"double colon":      {"::", true, true},
+	"open curly brace":  {"{", false, false},
+	"close curly brace": {"}", false, false},
+}
+
+// codeCases join the words that follow them into one identifier.
+var codeCases = map[string]func([]string) string{
+	"camel case": func(w []string) string {
+		return strings.ToLower(w[0]) + capitalizeAll(w[1:])
+	},
+	"pascal case": func(w []string) string { return capitalizeAll(w) },
+	"snake case":  func(w []string) string { return strings.ToLower(strings.Join(w, "_")) },
+	"kebab case":  func(w []string) string { return strings.ToLower(strings.Join(w, "-")) },
+	"constant case": func(w []string) string {
+		return strings.ToUpper(strings.Join(w, "_"))
+	},
+	"all caps": func(w []string) string { return strings.ToUpper(strings.Join(w, "")) },
+	"one word": func(w []string) string { return strings.ToLower(strings.Join(w, "")) },
+}
+
+const codeMaxPhrase = 3
+
+// capitalizeAll joins words with the first letter of each upper-cased.
+func capitalizeAll(words []string) string {
+	for i, w := range words {
+		r := []rune(strings.ToLower(w))
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, "")
+}
+
+// ApplyCodeMode turns dictated programming constructs into code: symbol
+// names become symbols and "camel case foo bar" becomes fooBar. A case
+// command takes the words up to the next symbol, case command or the end.
+// The prose punctuation and sentence capitals Whisper adds are dropped.
+func ApplyCodeMode(text string) string {
+	var words []string
+	start := true
+	for _, w := range strings.Fields(text) {
+		end := strings.ContainsAny(w[len(w)-1:], ".?!")
+		w = strings.TrimRight(w, ",.?!")
+		if w == "" {
+			continue
+		}
+		if start && isSentenceCapital(w) {
+			w = strings.ToLower(w)
+		}
+		start = end
+		words = append(words, w)
+	}
+
+	var out strings.Builder
+	glue := true // no space before the first token
+	emit := func(s string, glueLeft, glueRight bool) {
+		if !glue && !glueLeft {
+			out.WriteByte(' ')
+		}
+		out.WriteString(s)
+		glue = glueRight
+	}
+	// match returns the length in words of the longest phrase at i that
+	// found accepts, or 0.
+	match := func(i int, found func(string) bool) int {
+		for n := min(codeMaxPhrase, len(words)-i); n > 0; n-- {
+			if found(strings.ToLower(strings.Join(words[i:i+n], " "))) {
+				return n
+			}
+		}
+		return 0
+	}
+	isSymbol := func(p string) bool { _, ok := codeSymbols[p]; return ok }
+	isCase := func(p string) bool { _, ok := codeCases[p]; return ok }
+
+	// quotes open and close alternately; an open one glues to what follows
+	open := map[string]bool{}
+
+	for i := 0; i < len(words); {
+		if n := match(i, isCase); n > 0 {
+			join := codeCases[strings.ToLower(strings.Join(words[i:i+n], " "))]
+			j := i + n
+			for j < len(words) && match(j, isSymbol) == 0 && match(j, isCase) == 0 {
+				j++
+			}
+			if j > i+n {
+				emit(join(append([]string(nil), words[i+n:j]...)), false, false)
+			}
+			i = j
+			continue
+		}
+		if n := match(i, isSymbol); n > 0 {
+			s := codeSymbols[strings.ToLower(strings.Join(words[i:i+n], " "))]
+			if strings.ContainsAny(s.text, "\"'`") {
+				s.glueLeft, s.glueRight = open[s.text], !open[s.text]
+				open[s.text] = !open[s.text]
+			}
+			emit(s.text, s.glueLeft, s.glueRight)
+			i += n
+			continue
+		}
+		emit(words[i], false, false)
+		i++
+	}
+	return out.String()
+}
+
+// isSentenceCapital reports whether w looks capitalised only because it
+// starts a sentence ("If"), not an identifier like "HTTP" or "I".
+func isSentenceCapital(w string) bool {
+	r := []rune(w)
+	if len(r) < 2 || !unicode.IsUpper(r[0]) {
+		return false
+	}
+	for _, c := range r[1:] {
+		if !unicode.IsLower(c) {
+			return false
+		}
+	}
+	return true
+}
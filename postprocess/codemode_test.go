@@ -0,0 +1,53 @@
+package postprocess
+
+import "testing"
+
+func TestApplyCodeMode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"x equals y plus one", "x = y + one"},
+		{"if x equals equals y", "if x == y"},
+		{"a not equals b", "a != b"},
+		{"count plus equals one", "count += one"},
+		{"print open paren x close paren", "print(x)"},
+		{"items open bracket i close bracket dot name", "items[i].name"},
+		{"a comma b semicolon", "a, b;"},
+		{"bang done", "!done"},
+		{"x colon equals camel case user name", "x := userName"},
+		{"pascal case http server", "HttpServer"},
+		{"snake case Max Retries equals three", "max_retries = three"},
+		{"kebab case main menu", "main-menu"},
+		{"constant case max size", "MAX_SIZE"},
+		{"one word data base", "database"},
+		{"all caps id", "ID"},
+		// a case command ends at the next symbol or case command
+		{"camel case get value open paren close paren", "getValue()"},
+		{"snake case a b camel case c d", "a_b cD"},
+		{"camel case", ""},
+		// quotes open and close in turn
+		{"print open paren quote hello world quote close paren", `print("hello world")`},
+		{"single quote a single quote", "'a'"},
+		// Whisper's sentence capitals and punctuation are dropped
+		{"If x equals one, return.", "if x = one return"},
+		{"Then Foo dot Bar.", "then Foo.Bar"},
+		{"HTTP dot Get", "HTTP.Get"},
+		{"a fat arrow b", "a => b"},
+		{"i increment semicolon", "i++;"},
+		{"std double colon vector", "std::vector"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ApplyCodeMode(tt.in); got != tt.want {
+			t.Errorf("ApplyCodeMode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsSentenceCapital(t *testing.T) {
+	for w, want := range map[string]bool{"If": true, "Return": true, "I": false, "HTTP": false, "iPhone": false, "Foo2": false, "if": false} {
+		if got := isSentenceCapital(w); got != want {
+			t.Errorf("isSentenceCapital(%q) = %v, want %v", w, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package postprocess
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIChatURL is OpenAI's chat completions endpoint.
+const OpenAIChatURL = "https://api.openai.com/v1/chat/completions"
+
+// RewriteRequest asks a chat model to rewrite Text following Prompt.
+type RewriteRequest struct {
+	// URL is the chat completions endpoint.
+	URL string
+	// Name labels the endpoint in errors.
+	Name string
+	// Header carries the API key and any other headers.
+	Header http.Header
+	Model  string
+	// Prompt is the system prompt, e.g. "Fix the grammar."
+	Prompt string
+	Text   string
+	// Timeout bounds the request; zero means 60 seconds.
+	Timeout time.Duration
+}
+
+// Rewrite sends the text to the chat model and returns the model's answer.
+func Rewrite(req RewriteRequest) (string, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":       req.Model,
+		"temperature": 0,
+		"messages": []map[string]string{
+			{"role": "system", "content": req.Prompt + "\n\nReply with the rewritten text only."},
+			{"role": "user", "content": req.Text},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	hreq, err := http.NewRequest("POST", req.URL, bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	hreq.Header = req.Header.Clone()
+	if hreq.Header == nil {
+		hreq.Header = http.Header{}
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	cli := &http.Client{Timeout: timeout}
+	resp, err := cli.Do(hreq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("%s error: %s", req.Name, string(body))
+	}
+
+	var js struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &js); err != nil {
+		return "", err
+	}
+	if len(js.Choices) == 0 {
+		return "", errors.New("llm returned no choices")
+	}
+	return strings.TrimSpace(js.Choices[0].Message.Content), nil
+}
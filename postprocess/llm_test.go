@@ -0,0 +1,68 @@
+package postprocess
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	var got struct {
+		Model    string              `json:"model"`
+		Messages []map[string]string `json:"messages"`
+	}
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"  Fixed text.\n"}}]}`))
+	}))
+	defer srv.Close()
+
+	out, err := Rewrite(RewriteRequest{
+		URL:    srv.URL,
+		Name:   "llm",
+		Header: http.Header{"Authorization": {"Bearer k"}},
+		Model:  "gpt-4o-mini",
+		Prompt: "Fix the grammar.",
+		Text:   "fix text",
+	})
+	if err != nil || out != "Fixed text." {
+		t.Fatalf("Rewrite = %q, %v", out, err)
+	}
+	if header.Get("Authorization") != "Bearer k" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", header)
+	}
+	if got.Model != "gpt-4o-mini" || len(got.Messages) != 2 ||
+		!strings.HasPrefix(got.Messages[0]["content"], "Fix the grammar.") || got.Messages[1]["content"] != "fix text" {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestRewriteErrors(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"choices":[]}`: "no choices",
+		`not json`:       "invalid character",
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		_, err := Rewrite(RewriteRequest{URL: srv.URL, Name: "llm"})
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", body, err, want)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	if _, err := Rewrite(RewriteRequest{URL: srv.URL, Name: "llm"}); err == nil || !strings.Contains(err.Error(), "llm error: quota exceeded") {
+		t.Errorf("status error = %v", err)
+	}
+}
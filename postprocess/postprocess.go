@@ -0,0 +1,133 @@
+// Package postprocess cleans up transcripts: casing policies, typographic
+// punctuation, spoken punctuation, code mode, replacement rules and an LLM
+// rewrite through a chat completions endpoint. Each stage is a function of
+// the text; which stages run, and in what order, is up to the caller.
+package postprocess
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Casing policies for ApplyCasing. Empty is the same as CasingNone.
+const (
+	CasingNone     = "none"
+	CasingSentence = "sentence"
+	CasingLower    = "lower"
+	CasingUpper    = "upper"
+	CasingTitle    = "title"
+)
+
+// ValidCasing reports whether c is a casing policy.
+func ValidCasing(c string) bool {
+	switch c {
+	case "", CasingNone, CasingSentence, CasingLower, CasingUpper, CasingTitle:
+		return true
+	}
+	return false
+}
+
+// PunctuationFrench is the ApplyPunctuation style for French typography.
+const PunctuationFrench = "fr"
+
+var frenchAfter = regexp.MustCompile(`(«)\s*`)
+
+// ApplyPunctuation applies a language's typographic rules. French wants a
+// (narrow) no-break space before ; : ! ? and inside guillemets.
+func ApplyPunctuation(style, text string) string {
+	if style != PunctuationFrench {
+		return text
+	}
+	text = frenchSpaceBefore(text)
+	return frenchAfter.ReplaceAllString(text, "$1\u202f")
+}
+
+// frenchSpaceBefore puts the narrow no-break space before ; : ! ? and »
+// where they end a word, replacing any space already there. Marks followed
+// by a letter or digit are left alone, so times (12:30), ratios, URLs and
+// paths (C:\) stay intact.
+func frenchSpaceBefore(text string) string {
+	const marks = ";:!?»"
+	r := []rune(text)
+	out := make([]rune, 0, len(r)+8)
+	for i, c := range r {
+		if !strings.ContainsRune(marks, c) {
+			out = append(out, c)
+			continue
+		}
+		if i+1 < len(r) {
+			if next := r[i+1]; unicode.IsLetter(next) || unicode.IsDigit(next) || next == '/' || next == '\\' {
+				out = append(out, c)
+				continue
+			}
+		}
+		for len(out) > 0 && unicode.IsSpace(out[len(out)-1]) {
+			out = out[:len(out)-1]
+		}
+		// one space before a run of marks, as in "quoi ?!"
+		if len(out) > 0 && !strings.ContainsRune(marks, out[len(out)-1]) {
+			out = append(out, '\u202f')
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+// ApplyCasing changes the case of text by policy.
+func ApplyCasing(policy, text string) string {
+	switch policy {
+	case CasingSentence:
+		return SentenceCase(text)
+	case CasingLower:
+		return strings.ToLower(text)
+	case CasingUpper:
+		return strings.ToUpper(text)
+	case CasingTitle:
+		return TitleCase(text)
+	}
+	return text
+}
+
+// SentenceCase upper-cases the first letter of every sentence and leaves the
+// rest alone, so names and acronyms survive. A sentence ends at a '.', '!'
+// or '?' followed by whitespace, which leaves "example.com" and "v1.2"
+// alone.
+func SentenceCase(text string) string {
+	r := []rune(text)
+	start := true
+	for i, c := range r {
+		switch {
+		case c == '\n':
+			start = true
+		case c == '.' || c == '!' || c == '?':
+			if i+1 == len(r) || unicode.IsSpace(r[i+1]) {
+				start = true
+			}
+		case start && unicode.IsLetter(c):
+			r[i] = unicode.ToUpper(c)
+			start = false
+		case start && !unicode.IsSpace(c) && !unicode.IsPunct(c):
+			// digits etc. start a sentence without being capitalised
+			start = false
+		}
+	}
+	return string(r)
+}
+
+// TitleCase upper-cases the first letter of every word.
+func TitleCase(text string) string {
+	r := []rune(text)
+	inWord := false
+	for i, c := range r {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '\'' {
+			if !inWord {
+				r[i] = unicode.ToUpper(c)
+			}
+			inWord = true
+		} else {
+			inWord = false
+		}
+	}
+	return string(r)
+}
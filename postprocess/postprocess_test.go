@@ -0,0 +1,81 @@
+package postprocess
+
+import "testing"
+
+func TestApplyPunctuationFrench(t *testing.T) {
+	const nb = "\u202f"
+	tests := []struct {
+		in, want string
+	}{
+		{"Bonjour !", "Bonjour" + nb + "!"},
+		{"Bonjour!", "Bonjour" + nb + "!"},
+		{"Note : ceci", "Note" + nb + ": ceci"},
+		{"Quoi ?!", "Quoi" + nb + "?!"},
+		{"« Oui »", "«" + nb + "Oui" + nb + "»"},
+		{"un; deux", "un" + nb + "; deux"},
+		// times, ratios, URLs and paths are left alone
+		{"à 12:30 demain", "à 12:30 demain"},
+		{"un rapport de 3:1", "un rapport de 3:1"},
+		{"voir https://example.com/?q=1", "voir https://example.com/?q=1"},
+		{`dans C:\Users`, `dans C:\Users`},
+		{"à 12:30 : fin", "à 12:30" + nb + ": fin"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ApplyPunctuation(PunctuationFrench, tt.in); got != tt.want {
+			t.Errorf("ApplyPunctuation(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := ApplyPunctuation("", "12:30 !"); got != "12:30 !" {
+		t.Errorf("without a style the text changed: %q", got)
+	}
+}
+
+func TestSentenceCase(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hello world. this is it", "Hello world. This is it"},
+		{"really?! yes", "Really?! Yes"},
+		{"done.\nnext line", "Done.\nNext line"},
+		{"line one\nline two", "Line one\nLine two"},
+		{"call NASA. then ask", "Call NASA. Then ask"},
+		{"wait... what", "Wait... What"},
+		// a terminator inside a word ends no sentence
+		{"see example.com for more", "See example.com for more"},
+		{"go to www.example.org/path.html now", "Go to www.example.org/path.html now"},
+		{"version v1.2.3 is out", "Version v1.2.3 is out"},
+		{"it costs 3.50 dollars. ok", "It costs 3.50 dollars. Ok"},
+		{"what?no", "What?no"},
+		// digits and punctuation start a sentence without capitals
+		{"3 apples. «quoted» text", "3 apples. «Quoted» text"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := SentenceCase(tt.in); got != tt.want {
+			t.Errorf("SentenceCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCasing(t *testing.T) {
+	tests := []struct {
+		policy, in, want string
+	}{
+		{CasingTitle, "the lord of the rings", "The Lord Of The Rings"},
+		{CasingTitle, "don't stop-me now", "Don't Stop-Me Now"},
+		{CasingTitle, "résumé über 3rd place", "Résumé Über 3rd Place"},
+		{CasingTitle, "keep NASA and iPhone", "Keep NASA And IPhone"},
+		{CasingLower, "Git Commit -m", "git commit -m"},
+		{CasingUpper, "résumé ok", "RÉSUMÉ OK"},
+		{CasingSentence, "one. two", "One. Two"},
+		{CasingNone, "Leave it Alone. please", "Leave it Alone. please"},
+		{"", "leave it", "leave it"},
+		{CasingTitle, "", ""},
+	}
+	for _, tt := range tests {
+		if got := ApplyCasing(tt.policy, tt.in); got != tt.want {
+			t.Errorf("ApplyCasing(%q, %q) = %q, want %q", tt.policy, tt.in, got, tt.want)
+		}
+	}
+}
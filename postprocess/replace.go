@@ -0,0 +1,75 @@
+package postprocess
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Replacement is one rule of the replacements file. Literal rules match
+// whole words, ignoring case unless CaseSensitive is set; regex rules are Go
+// regular expressions and To may refer to groups as $1.
+type Replacement struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Regex         bool   `json:"regex"`
+	CaseSensitive bool   `json:"case_sensitive"`
+
+	re *regexp.Regexp
+}
+
+// LoadReplacements reads and compiles the rules in path. A missing file
+// means no rules.
+func LoadReplacements(path string) ([]Replacement, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []Replacement
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.From == "" {
+			// it would match between every two characters
+			return nil, fmt.Errorf("%s: rule %d has an empty \"from\"", path, i+1)
+		}
+		pattern := r.From
+		if !r.Regex {
+			pattern = regexp.QuoteMeta(pattern)
+			// only anchor at word characters, so "open paren" is a word
+			// match but rules starting with punctuation still work
+			if regexp.MustCompile(`^\w`).MatchString(r.From) {
+				pattern = `\b` + pattern
+			}
+			if regexp.MustCompile(`\w$`).MatchString(r.From) {
+				pattern += `\b`
+			}
+		}
+		if !r.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+		r.re, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %q: %v", path, r.From, err)
+		}
+	}
+	return rules, nil
+}
+
+// ApplyReplacements runs the rules in order.
+func ApplyReplacements(rules []Replacement, text string) string {
+	for _, r := range rules {
+		if r.Regex {
+			text = r.re.ReplaceAllString(text, r.To)
+		} else {
+			text = r.re.ReplaceAllLiteralString(text, r.To)
+		}
+	}
+	return text
+}
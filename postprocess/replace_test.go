@@ -0,0 +1,67 @@
+package postprocess
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeReplacements(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "replacements.json")
+	if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyReplacements(t *testing.T) {
+	rules, err := LoadReplacements(writeReplacements(t, `[
+		{"from": "kubernetes", "to": "Kubernetes"},
+		{"from": "gee pee tee", "to": "GPT"},
+		{"from": "Go", "to": "Golang", "case_sensitive": true},
+		{"from": ":)", "to": "🙂"},
+		{"from": "(\\d+) percent", "to": "$1%", "regex": true},
+		{"from": "it costs $5", "to": "it is cheap"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"deploy to KUBERNETES today", "deploy to Kubernetes today"},
+		// literal rules match whole words only
+		{"kubernetesy things", "kubernetesy things"},
+		{"ask gee pee tee", "ask GPT"},
+		{"Go and go", "Golang and go"},
+		{"nice :) right", "nice 🙂 right"},
+		{"up 50 percent", "up 50%"},
+		// literal rules have no group references or metacharacters
+		{"it costs $5", "it is cheap"},
+		{"nothing here", "nothing here"},
+	}
+	for _, tt := range tests {
+		if got := ApplyReplacements(rules, tt.in); got != tt.want {
+			t.Errorf("ApplyReplacements(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadReplacements(t *testing.T) {
+	rules, err := LoadReplacements(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || rules != nil {
+		t.Errorf("missing file: %v, %v", rules, err)
+	}
+	for rules, want := range map[string]string{
+		`not json`:                           "invalid character",
+		`[{"from": "", "to": "x"}]`:          `rule 1 has an empty "from"`,
+		`[{"from": "(", "regex": true}]`:     `rule "("`,
+		`[{"from": "a"}, {"to": "nothing"}]`: `rule 2 has an empty "from"`,
+	} {
+		if _, err := LoadReplacements(writeReplacements(t, rules)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want it to contain %q", rules, err, want)
+		}
+	}
+}
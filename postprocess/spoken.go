@@ -0,0 +1,60 @@
+package postprocess
+
+import (
+	"regexp"
+	"strings"
+)
+
+// spokenMarks maps spoken punctuation and formatting commands to what they
+// stand for. Longer phrases come first so "new paragraph" wins over
+// "new line"-style prefixes.
+var spokenMarks = []struct {
+	phrase string
+	mark   string
+}{
+	{"new paragraph", "\n\n"},
+	{"new line", "\n"},
+	{"newline", "\n"},
+	{"question mark", "?"},
+	{"exclamation mark", "!"},
+	{"exclamation point", "!"},
+	{"full stop", "."},
+	{"period", "."},
+	{"comma", ","},
+	{"semicolon", ";"},
+	{"colon", ":"},
+}
+
+var (
+	spokenMarkRes []*regexp.Regexp
+	// sentinel-wrapped marks, with the spacing the speaker (and Whisper's
+	// own punctuation around the command word) left around them
+	spokenSentinel = regexp.MustCompile(`[ \t]*\x00([^\x00]+)\x00[ \t]*`)
+)
+
+func init() {
+	for _, m := range spokenMarks {
+		// Whisper tends to punctuate the command word itself
+		// ("Hello, comma, world.") so swallow that punctuation too
+		p := `(?i)(?:[,.;:!?][ \t]*)?\b` + strings.ReplaceAll(m.phrase, " ", `[\s-]+`) + `\b[,.;:!?]?`
+		spokenMarkRes = append(spokenMarkRes, regexp.MustCompile(p))
+	}
+}
+
+// ApplySpokenPunctuation turns spoken commands like "comma" or "new
+// paragraph" into the characters they stand for.
+func ApplySpokenPunctuation(text string) string {
+	for i, re := range spokenMarkRes {
+		text = re.ReplaceAllLiteralString(text, "\x00"+spokenMarks[i].mark+"\x00")
+	}
+	text = spokenSentinel.ReplaceAllStringFunc(text, func(s string) string {
+		mark := spokenSentinel.FindStringSubmatch(s)[1]
+		if strings.HasPrefix(mark, "\n") {
+			return mark
+		}
+		return mark + " "
+	})
+	// a mark at the very end or before a line break needs no space after it
+	text = strings.ReplaceAll(text, " \n", "\n")
+	return strings.TrimRight(text, " ")
+}
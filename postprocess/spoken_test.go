@@ -0,0 +1,32 @@
+package postprocess
+
+import "testing"
+
+func TestApplySpokenPunctuation(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hello comma world period", "hello, world."},
+		{"is it done question mark", "is it done?"},
+		{"wow exclamation point", "wow!"},
+		{"first new line second", "first\nsecond"},
+		{"one new paragraph two", "one\n\ntwo"},
+		{"items colon apples semicolon pears full stop", "items: apples; pears."},
+		// Whisper's own punctuation around the command word goes
+		{"Hello, comma, world.", "Hello, world."},
+		{"Done. Period.", "Done."},
+		{"end of line. New line. Next", "end of line\nNext"},
+		// case and hyphens don't matter
+		{"stop Full-Stop", "stop."},
+		{"a NEW LINE b", "a\nb"},
+		// whole words only
+		{"the periodic table", "the periodic table"},
+		{"a commander", "a commander"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ApplySpokenPunctuation(tt.in); got != tt.want {
+			t.Errorf("ApplySpokenPunctuation(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package provider describes HTTP endpoints speaking an OpenAI-style API
+// (transcription, speech, chat completions) as they are configured: where
+// requests go, how the API key is sent, and which response formats the
+// endpoint has.
+//
+// Looking up the key itself is left to the caller, which knows where keys
+// are kept.
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/user/dictation/transcribe"
+)
+
+// Provider is one endpoint. Entries of the dictation config's "providers",
+// "tts" and "llm" decode into it.
+type Provider struct {
+	Name string `json:"name"`
+	// URL is the full endpoint. It is a text/template with the provider's
+	// fields available, for endpoints that embed deployment names and API
+	// versions (Azure OpenAI):
+	//
+	//	https://{{.Vars.resource}}.openai.azure.com/openai/deployments/{{.Deployment}}/audio/transcriptions?api-version={{.APIVersion}}
+	//
+	// {{env "NAME"}} reads an environment variable. The dictation command
+	// runs "exec:/path/to/program" URLs as plugins instead.
+	URL string `json:"url"`
+	// TranslateURL is the speech translation endpoint (same template
+	// rules). By default it is URL with "/transcriptions" replaced by
+	// "/translations".
+	TranslateURL string `json:"translate_url"`
+	// Model defaults to the top-level model.
+	Model string `json:"model"`
+	// APIKeyEnv names the environment variable holding the key. Empty means
+	// the provider needs no key.
+	APIKeyEnv string `json:"api_key_env"`
+	// APIKeyFile and APIKeyCmd read the key from a file or from what a
+	// shell command prints instead; they take precedence over APIKeyEnv.
+	APIKeyFile string `json:"api_key_file"`
+	APIKeyCmd  string `json:"api_key_cmd"`
+	// Timeout is how long a request may take, in seconds; by default
+	// request_timeout.
+	Timeout float64 `json:"timeout"`
+	// AuthHeader is the header the key is sent in. Empty sends
+	// "Authorization: Bearer <key>"; anything else (e.g. Azure's "api-key")
+	// sends the bare key in that header.
+	AuthHeader string `json:"auth_header"`
+
+	// ResponseFormats lists the response_format values the provider
+	// supports, out of "text", "json" and "verbose_json". Empty means all
+	// three.
+	ResponseFormats []string `json:"response_formats"`
+
+	// Deployment, APIVersion and Vars are only used by the URL template.
+	Deployment string            `json:"deployment"`
+	APIVersion string            `json:"api_version"`
+	Vars       map[string]string `json:"vars"`
+
+	// Organization and Project are sent as OpenAI-Organization and
+	// OpenAI-Project.
+	Organization string `json:"organization"`
+	Project      string `json:"project"`
+	// Headers are added to every request, e.g. routing headers required by
+	// an API gateway. ${VAR} in values is expanded from the environment.
+	Headers map[string]string `json:"headers"`
+}
+
+// Merge returns the configured providers followed by the built-in ones they
+// do not name. A configured entry named like a built-in customises it:
+// fields it leaves empty keep the built-in value. Entries still without a
+// model get model.
+func Merge(configured, builtins []Provider, model string) []Provider {
+	var list []Provider
+	has := map[string]bool{}
+	for _, p := range configured {
+		for _, b := range builtins {
+			if b.Name == p.Name {
+				p = p.WithDefaults(b)
+			}
+		}
+		if p.Model == "" {
+			p.Model = model
+		}
+		list = append(list, p)
+		has[p.Name] = true
+	}
+	for _, b := range builtins {
+		if !has[b.Name] {
+			list = append(list, b)
+		}
+	}
+	return list
+}
+
+// Find returns the provider called name.
+func Find(list []Provider, name string) (Provider, error) {
+	for _, p := range list {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Provider{}, fmt.Errorf("unknown provider %q", name)
+}
+
+// WithDefaults fills the fields p leaves empty from d. The key file and
+// command, headers and template fields are p's own.
+func (p Provider) WithDefaults(d Provider) Provider {
+	if p.URL == "" {
+		p.URL = d.URL
+	}
+	if p.TranslateURL == "" {
+		p.TranslateURL = d.TranslateURL
+	}
+	if p.Model == "" {
+		p.Model = d.Model
+	}
+	if p.APIKeyEnv == "" {
+		p.APIKeyEnv = d.APIKeyEnv
+	}
+	if p.Organization == "" {
+		p.Organization = d.Organization
+	}
+	if p.Project == "" {
+		p.Project = d.Project
+	}
+	if p.AuthHeader == "" {
+		p.AuthHeader = d.AuthHeader
+	}
+	if p.ResponseFormats == nil {
+		p.ResponseFormats = d.ResponseFormats
+	}
+	return p
+}
+
+// SupportsFormat reports whether the provider has response format f.
+func (p Provider) SupportsFormat(f string) bool {
+	if len(p.ResponseFormats) == 0 {
+		return true
+	}
+	for _, s := range p.ResponseFormats {
+		if s == f {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseFormat picks what to ask the provider for: verbose_json when
+// timings or confidence are needed, else plain text, which is smaller and
+// faster to produce. It falls back to json when the preferred format is not
+// supported; the extra details are then simply missing.
+func (p Provider) ResponseFormat(needDetails bool) string {
+	if needDetails && p.SupportsFormat(transcribe.FormatVerboseJSON) {
+		return transcribe.FormatVerboseJSON
+	}
+	if !needDetails && p.SupportsFormat(transcribe.FormatText) {
+		return transcribe.FormatText
+	}
+	if p.SupportsFormat(transcribe.FormatJSON) || len(p.ResponseFormats) == 0 {
+		return transcribe.FormatJSON
+	}
+	return p.ResponseFormats[0]
+}
+
+// Endpoint expands the URL template.
+func (p Provider) Endpoint() (string, error) {
+	return p.expand(p.URL)
+}
+
+// TranslateEndpoint expands the translation URL template.
+func (p Provider) TranslateEndpoint() (string, error) {
+	if p.TranslateURL != "" {
+		return p.expand(p.TranslateURL)
+	}
+	if !strings.Contains(p.URL, "/transcriptions") {
+		return "", fmt.Errorf("provider %s: set translate_url to use translation", p.Name)
+	}
+	return p.expand(strings.Replace(p.URL, "/transcriptions", "/translations", 1))
+}
+
+func (p Provider) expand(url string) (string, error) {
+	if !strings.Contains(url, "{{") {
+		return url, nil
+	}
+	t, err := template.New(p.Name).Funcs(template.FuncMap{"env": os.Getenv}).Option("missingkey=error").Parse(url)
+	if err != nil {
+		return "", fmt.Errorf("provider %s: bad url template: %v", p.Name, err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, p); err != nil {
+		return "", fmt.Errorf("provider %s: %v", p.Name, err)
+	}
+	return b.String(), nil
+}
+
+// Header holds the API key, the way the provider expects it, and the
+// provider's metadata headers.
+func (p Provider) Header(key string) http.Header {
+	h := http.Header{}
+	switch {
+	case key == "":
+	case p.AuthHeader == "":
+		h.Set("Authorization", "Bearer "+key)
+	default:
+		h.Set(p.AuthHeader, key)
+	}
+	if p.Organization != "" {
+		h.Set("OpenAI-Organization", p.Organization)
+	}
+	if p.Project != "" {
+		h.Set("OpenAI-Project", p.Project)
+	}
+	for k, v := range p.Headers {
+		h.Set(k, os.ExpandEnv(v))
+	}
+	return h
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/dictation/transcribe"
+)
+
+func TestMerge(t *testing.T) {
+	builtins := []Provider{
+		{Name: "openai", URL: transcribe.OpenAIURL, Model: "whisper-1", APIKeyEnv: "OPENAI_API_KEY"},
+		{Name: "worker", URL: "http://127.0.0.1:8765/v1/audio/transcriptions"},
+	}
+	configured := []Provider{
+		{Name: "groq", URL: "https://api.groq.com/openai/v1/audio/transcriptions", APIKeyEnv: "GROQ_API_KEY"},
+		{Name: "openai", Model: "gpt-4o-transcribe", Headers: map[string]string{"X-Team": "a"}},
+	}
+	list := Merge(configured, builtins, "default-model")
+	var names []string
+	for _, p := range list {
+		names = append(names, p.Name)
+	}
+	if want := []string{"groq", "openai", "worker"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Merge names = %v, want %v", names, want)
+	}
+	if list[0].Model != "default-model" {
+		t.Errorf("groq model = %q, want the default", list[0].Model)
+	}
+	openai := list[1]
+	if openai.URL != transcribe.OpenAIURL || openai.APIKeyEnv != "OPENAI_API_KEY" ||
+		openai.Model != "gpt-4o-transcribe" || openai.Headers["X-Team"] != "a" {
+		t.Errorf("customised openai = %+v", openai)
+	}
+	// built-ins nobody names are kept as they are
+	if list[2].Model != "" {
+		t.Errorf("worker model = %q", list[2].Model)
+	}
+
+	if p, err := Find(list, "groq"); err != nil || p.APIKeyEnv != "GROQ_API_KEY" {
+		t.Errorf("Find(groq) = %+v, %v", p, err)
+	}
+	if _, err := Find(list, "nope"); err == nil || !strings.Contains(err.Error(), `"nope"`) {
+		t.Errorf("Find(nope) err = %v", err)
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		formats     []string
+		needDetails bool
+		want        string
+	}{
+		{nil, false, transcribe.FormatText},
+		{nil, true, transcribe.FormatVerboseJSON},
+		{[]string{"json", "verbose_json"}, false, transcribe.FormatJSON},
+		{[]string{"text", "json"}, true, transcribe.FormatJSON},
+		{[]string{"text"}, true, transcribe.FormatText},
+		{[]string{"srt"}, false, "srt"},
+	}
+	for _, tt := range tests {
+		p := Provider{ResponseFormats: tt.formats}
+		if got := p.ResponseFormat(tt.needDetails); got != tt.want {
+			t.Errorf("ResponseFormat(%v) with %v = %q, want %q", tt.needDetails, tt.formats, got, tt.want)
+		}
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	t.Setenv("DICTATION_TEST_HOST", "stt.example.com")
+	azure := Provider{
+		Name:       "azure",
+		URL:        "https://{{.Vars.resource}}.openai.azure.com/openai/deployments/{{.Deployment}}/audio/transcriptions?api-version={{.APIVersion}}",
+		Deployment: "whisper",
+		APIVersion: "2024-06-01",
+		Vars:       map[string]string{"resource": "acme"},
+	}
+	tests := []struct {
+		p         Provider
+		url, tURL string
+	}{
+		{Provider{URL: transcribe.OpenAIURL}, transcribe.OpenAIURL, "https://api.openai.com/v1/audio/translations"},
+		{azure,
+			"https://acme.openai.azure.com/openai/deployments/whisper/audio/transcriptions?api-version=2024-06-01",
+			"https://acme.openai.azure.com/openai/deployments/whisper/audio/translations?api-version=2024-06-01"},
+		{Provider{URL: `https://{{env "DICTATION_TEST_HOST"}}/v1/audio/transcriptions`, TranslateURL: "https://other/translate"},
+			"https://stt.example.com/v1/audio/transcriptions", "https://other/translate"},
+	}
+	for _, tt := range tests {
+		if got, err := tt.p.Endpoint(); got != tt.url || err != nil {
+			t.Errorf("Endpoint(%q) = %q, %v, want %q", tt.p.URL, got, err, tt.url)
+		}
+		if got, err := tt.p.TranslateEndpoint(); got != tt.tURL || err != nil {
+			t.Errorf("TranslateEndpoint(%q) = %q, %v, want %q", tt.p.URL, got, err, tt.tURL)
+		}
+	}
+
+	for _, p := range []Provider{
+		{Name: "bad", URL: "https://{{.Vars.missing}}/x"},
+		{Name: "bad", URL: "https://{{.Nope"},
+	} {
+		if _, err := p.Endpoint(); err == nil || !strings.HasPrefix(err.Error(), "provider bad: ") {
+			t.Errorf("Endpoint(%q) err = %v", p.URL, err)
+		}
+	}
+	if _, err := (Provider{Name: "x", URL: "http://host/asr"}).TranslateEndpoint(); err == nil {
+		t.Error("TranslateEndpoint without /transcriptions or translate_url succeeded")
+	}
+}
+
+func TestHeader(t *testing.T) {
+	t.Setenv("DICTATION_TEST_TEAM", "speech")
+	p := Provider{Organization: "org", Project: "proj", Headers: map[string]string{"X-Team": "${DICTATION_TEST_TEAM}"}}
+	h := p.Header("k")
+	if h.Get("Authorization") != "Bearer k" || h.Get("OpenAI-Organization") != "org" ||
+		h.Get("OpenAI-Project") != "proj" || h.Get("X-Team") != "speech" {
+		t.Errorf("Header = %v", h)
+	}
+
+	h = Provider{AuthHeader: "api-key"}.Header("k")
+	if h.Get("api-key") != "k" || h.Get("Authorization") != "" {
+		t.Errorf("Header with auth_header = %v", h)
+	}
+	if h := (Provider{}).Header(""); len(h) != 0 {
+		t.Errorf("Header without a key = %v", h)
+	}
+}
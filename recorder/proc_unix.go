@@ -0,0 +1,38 @@
+//go:build !windows
+
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// StopProcess asks the recorder to finish. SIGINT lets arecord/sox flush the
+// WAV header; SIGKILL is the fallback.
+func StopProcess(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGINT); err != nil {
+		if killErr := syscall.Kill(pid, syscall.SIGKILL); killErr != nil {
+			return fmt.Errorf("kill failed: %v (also tried SIGKILL: %v)", err, killErr)
+		}
+	}
+	return nil
+}
+
+// ProcessName returns the executable name of a running process, from /proc
+// where there is one and ps elsewhere (macOS).
+func ProcessName(pid int) (string, error) {
+	if b, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		return strings.TrimSpace(string(b)), nil
+	}
+	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	name := strings.TrimSpace(string(out))
+	if err != nil || name == "" {
+		return "", fmt.Errorf("no process %d", pid)
+	}
+	return filepath.Base(name), nil
+}
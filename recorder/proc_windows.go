@@ -0,0 +1,34 @@
+package recorder
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// StopProcess terminates the recorder. Windows cannot deliver SIGINT to
+// another console process, so the WAV header is left unfinished and
+// repaired afterwards (see audio.RepairWAVHeader).
+func StopProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+// ProcessName returns the image name of a running process ("sox.exe").
+func ProcessName(pid int) (string, error) {
+	out, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return "", err
+	}
+	// with no match tasklist prints an INFO line instead of a record
+	rec, err := csv.NewReader(strings.NewReader(string(out))).Read()
+	if err != nil || len(rec) < 2 || !strings.HasPrefix(strings.TrimSpace(string(out)), `"`) {
+		return "", fmt.Errorf("no process %d", pid)
+	}
+	return rec[0], nil
+}
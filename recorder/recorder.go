@@ -0,0 +1,134 @@
+// Package recorder records the microphone to 16 kHz mono 16-bit audio with
+// the platform's command-line recorder: arecord on Linux, sox on macOS and
+// Windows (its waveaudio input, which uses WinMM rather than WASAPI). A
+// recording is a detached process whose pid is kept in a file, so the
+// program stopping it need not be the one that started it.
+//
+// Command lines and pids are logged through log/slog at debug level.
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Rate is the sample rate of every recording.
+const Rate = 16000
+
+// Command is the recorder writing a WAV to outFile until it is stopped.
+func Command(outFile string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		// sox (brew install sox) finalises the WAV header on SIGINT just
+		// like arecord
+		return exec.Command("sox", "-q", "-d", "-r", "16000", "-c", "1", "-b", "16", outFile)
+	case "windows":
+		// waveaudio is WinMM's waveIn, the default device in shared mode
+		return exec.Command("sox", "-q", "-t", "waveaudio", "default", "-r", "16000", "-c", "1", "-b", "16", outFile)
+	}
+	return exec.Command("arecord", "-f", "S16_LE", "-r", "16000", "-c", "1", outFile)
+}
+
+// RawCommand records 16 kHz 16-bit mono PCM to stdout, without a WAV
+// header, for as long as it runs.
+func RawCommand() *exec.Cmd {
+	if runtime.GOOS == "darwin" {
+		return exec.Command("sox", "-q", "-d", "-t", "raw", "-r", "16000", "-c", "1", "-b", "16", "-e", "signed-integer", "-")
+	}
+	return exec.Command("arecord", "-q", "-f", "S16_LE", "-r", "16000", "-c", "1", "-t", "raw", "-")
+}
+
+// OnCrash, when set, is called if a recorder started by this process exits
+// without being stopped (device unplugged, audio server restarting). Only
+// a long-running process sees that.
+var OnCrash func(outFile string, err error)
+
+// stopping is the pid Stop is stopping.
+var stopping atomic.Int64
+
+// Start starts recording to outFile and writes the recorder's pid to
+// pidFile, which is removed when the recorder exits.
+func Start(outFile, pidFile string) error {
+	cmd := Command(outFile)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+	slog.Debug("recorder started", "cmd", cmd.String(), "pid", pid, "file", outFile)
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644); err != nil {
+		// try to kill process if we couldn't write pid
+		_ = cmd.Process.Kill()
+		return err
+	}
+	// detach: do not wait here
+	go func() {
+		err := cmd.Wait()
+		slog.Debug("recorder exited", "pid", pid, "err", err)
+		_ = os.Remove(pidFile)
+		if stopping.Load() != int64(pid) && OnCrash != nil {
+			OnCrash(outFile, err)
+		}
+	}()
+	return nil
+}
+
+// ErrStale is returned by Stop when the recorder in the pid file is no
+// longer running; the file has been removed.
+var ErrStale = errors.New("recorder is no longer running")
+
+// Stop stops the recorder whose pid is in pidFile.
+func Stop(pidFile string) error {
+	pid, err := readPid(pidFile)
+	if err != nil {
+		return err
+	}
+	// The recorder may have died and its pid been reused since; signalling
+	// whatever runs under it now could kill an unrelated program.
+	if !IsRecorder(pid) {
+		if err := os.Remove(pidFile); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w (pid %d); removed stale %s", ErrStale, pid, pidFile)
+	}
+	stopping.Store(int64(pid))
+	slog.Debug("stopping recorder", "pid", pid)
+	if err := StopProcess(pid); err != nil {
+		return err
+	}
+	_ = os.Remove(pidFile)
+	return nil
+}
+
+// Running reports whether the recorder in pidFile is running.
+func Running(pidFile string) bool {
+	pid, err := readPid(pidFile)
+	return err == nil && IsRecorder(pid)
+}
+
+func readPid(pidFile string) (int, error) {
+	b, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(bytes.TrimSpace(b)))
+}
+
+// IsRecorder reports whether pid is a running recorder process.
+func IsRecorder(pid int) bool {
+	name, err := ProcessName(pid)
+	if err != nil {
+		return false
+	}
+	want := filepath.Base(Command("").Path)
+	trim := func(s string) string { return strings.ToLower(strings.TrimSuffix(s, ".exe")) }
+	return trim(name) == trim(want)
+}
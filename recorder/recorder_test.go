@@ -0,0 +1,62 @@
+package recorder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// The test binary is not a recorder, so its own pid stands for a recorder
+// that died and whose pid was reused.
+func writePid(t *testing.T, pid string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "recorder.pid")
+	if err := os.WriteFile(path, []byte(pid+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStopStale(t *testing.T) {
+	path := writePid(t, strconv.Itoa(os.Getpid()))
+	if Running(path) {
+		t.Error("Running reports a process that is not a recorder")
+	}
+	if err := Stop(path); !errors.Is(err, ErrStale) {
+		t.Fatalf("Stop = %v, want ErrStale", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("stale pid file was not removed")
+	}
+}
+
+func TestStopWithoutPidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "none.pid")
+	if Running(path) {
+		t.Error("Running without a pid file")
+	}
+	if err := Stop(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stop = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestStopBadPidFile(t *testing.T) {
+	path := writePid(t, "not a pid")
+	if Running(path) {
+		t.Error("Running with a garbled pid file")
+	}
+	if err := Stop(path); err == nil || errors.Is(err, ErrStale) {
+		t.Errorf("Stop = %v, want a parse error", err)
+	}
+}
+
+func TestIsRecorder(t *testing.T) {
+	if IsRecorder(os.Getpid()) {
+		t.Error("the test binary counts as a recorder")
+	}
+	if IsRecorder(-1) {
+		t.Error("pid -1 counts as a recorder")
+	}
+}
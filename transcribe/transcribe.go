@@ -0,0 +1,217 @@
+// Package transcribe is a client for speech-to-text endpoints speaking the
+// OpenAI audio API (/v1/audio/transcriptions and /v1/audio/translations),
+// which OpenAI, Groq, Azure OpenAI, faster-whisper servers and
+// whisper.cpp's server all do.
+//
+// Requests are logged through log/slog at debug level, without their
+// headers.
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OpenAIURL is OpenAI's transcription endpoint.
+const OpenAIURL = "https://api.openai.com/v1/audio/transcriptions"
+
+// Response formats of the audio API.
+const (
+	FormatText        = "text"
+	FormatJSON        = "json"
+	FormatVerboseJSON = "verbose_json"
+)
+
+// Request describes one transcription request but for the audio.
+type Request struct {
+	// URL is the endpoint; Name the provider, for error messages.
+	URL  string
+	Name string
+	// Header is sent with the request: authorisation and whatever else
+	// the provider wants.
+	Header http.Header
+	Model  string
+	// Language is the spoken language as an ISO-639-1 code; empty lets
+	// the provider detect it. Translation endpoints ignore it.
+	Language string
+	// Prompt is text the speech is likely to continue, or words it
+	// contains, to steer the spelling of names and jargon.
+	Prompt string
+	// Format is the response_format, FormatJSON when empty. Words,
+	// segments and confidence only come with FormatVerboseJSON.
+	Format string
+	// WordTimings asks for per-word timings (verbose_json only).
+	WordTimings bool
+	// Timeout bounds the whole request, 120 seconds when zero.
+	Timeout time.Duration
+}
+
+// Word is a single recognised word with its position in the audio, in
+// seconds.
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Segment is a stretch of speech as returned by verbose responses.
+type Segment struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	AvgLogprob float64 `json:"avg_logprob"`
+}
+
+// Result is what the endpoint returned for one recording.
+type Result struct {
+	Text     string
+	Words    []Word
+	Segments []Segment
+	// Language is the spoken language the provider detected, when it
+	// reports one.
+	Language string
+	// Duration is the length of the audio in seconds, when known.
+	Duration float64
+	// Confidence is the mean per-segment probability (exp of avg_logprob)
+	// from verbose responses; 0 when the provider didn't report it.
+	Confidence float64
+	// EncodeTime covers reading and packing the audio, RequestTime the
+	// upload and the provider's processing.
+	EncodeTime  time.Duration
+	RequestTime time.Duration
+}
+
+// APIError is a non-2xx answer from a provider.
+type APIError struct {
+	Provider string
+	Status   int
+	Body     string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s error: %s", e.Provider, e.Body)
+}
+
+// File transcribes (or translates, depending on the endpoint) the audio
+// file at path. Errors reaching the endpoint are *url.Error; answers other
+// than success are *APIError.
+func File(req Request, path string) (Result, error) {
+	return FileContext(context.Background(), req, path)
+}
+
+// FileContext is File with a context: cancelling it aborts the upload or
+// the wait for the answer, and the error then wraps ctx's.
+func FileContext(ctx context.Context, req Request, path string) (Result, error) {
+	var res Result
+	encStart := time.Now()
+	f, err := os.Open(path)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+
+	format := req.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	fw, err := w.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return res, err
+	}
+	if _, err := io.Copy(fw, f); err != nil {
+		return res, err
+	}
+	_ = w.WriteField("model", req.Model)
+	if req.Language != "" {
+		_ = w.WriteField("language", req.Language)
+	}
+	if req.Prompt != "" {
+		_ = w.WriteField("prompt", req.Prompt)
+	}
+	_ = w.WriteField("response_format", format)
+	if format == FormatVerboseJSON && req.WordTimings {
+		_ = w.WriteField("timestamp_granularities[]", "word")
+	}
+	w.Close()
+	res.EncodeTime = time.Since(encStart)
+	size := b.Len()
+
+	hr, err := http.NewRequestWithContext(ctx, "POST", req.URL, &b)
+	if err != nil {
+		return res, err
+	}
+	for k, v := range req.Header {
+		hr.Header[k] = v
+	}
+	hr.Header.Set("Content-Type", w.FormDataContentType())
+
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = 120 * time.Second
+	}
+	cli := &http.Client{Timeout: timeout}
+	reqStart := time.Now()
+	resp, err := cli.Do(hr)
+	if err != nil {
+		slog.Debug("transcription request failed", "provider", req.Name, "url", req.URL, "err", err)
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	res.RequestTime = time.Since(reqStart)
+	slog.Debug("transcription request", "provider", req.Name, "url", req.URL, "model", req.Model,
+		"status", resp.StatusCode, "bytes", size, "duration", res.RequestTime)
+	if resp.StatusCode >= 300 {
+		return res, &APIError{Provider: req.Name, Status: resp.StatusCode, Body: string(body)}
+	}
+
+	if format == FormatText {
+		res.Text = strings.TrimSpace(string(body))
+		return res, nil
+	}
+
+	var js struct {
+		Text     string    `json:"text"`
+		Language string    `json:"language"`
+		Duration float64   `json:"duration"`
+		Words    []Word    `json:"words"`
+		Segments []Segment `json:"segments"`
+	}
+	if err := json.Unmarshal(body, &js); err != nil {
+		return res, err
+	}
+	res.Text = js.Text
+	res.Words = js.Words
+	res.Duration = js.Duration
+	res.Language = js.Language
+	res.Segments = js.Segments
+	res.Confidence = SegmentConfidence(js.Segments)
+	return res, nil
+}
+
+// SegmentConfidence is the mean probability of the segments, from their
+// avg_logprob, or 0 when there are none.
+func SegmentConfidence(segs []Segment) float64 {
+	if len(segs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, seg := range segs {
+		sum += math.Exp(seg.AvgLogprob)
+	}
+	return sum / float64(len(segs))
+}
@@ -0,0 +1,168 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeAudio(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, []byte("RIFF fake audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileSendsRequest(t *testing.T) {
+	var got *http.Request
+	var audio []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+		}
+		if f, _, err := r.FormFile("file"); err == nil {
+			audio, _ = io.ReadAll(f)
+		}
+		w.Write([]byte(`{"text":"hello world","language":"english","duration":1.5,
+			"words":[{"word":"hello","start":0,"end":0.5}],
+			"segments":[{"start":0,"end":1.5,"text":"hello world","avg_logprob":-0.1}]}`))
+	}))
+	defer srv.Close()
+
+	res, err := File(Request{
+		URL:         srv.URL,
+		Name:        "test",
+		Header:      http.Header{"Authorization": {"Bearer k"}},
+		Model:       "whisper-1",
+		Language:    "en",
+		Prompt:      "Kubernetes",
+		Format:      FormatVerboseJSON,
+		WordTimings: true,
+	}, writeAudio(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Get("Authorization") != "Bearer k" {
+		t.Errorf("Authorization = %q", got.Header.Get("Authorization"))
+	}
+	fields := map[string]string{
+		"model":                     "whisper-1",
+		"language":                  "en",
+		"prompt":                    "Kubernetes",
+		"response_format":           FormatVerboseJSON,
+		"timestamp_granularities[]": "word",
+	}
+	for k, want := range fields {
+		if v := got.FormValue(k); v != want {
+			t.Errorf("%s = %q, want %q", k, v, want)
+		}
+	}
+	if string(audio) != "RIFF fake audio" {
+		t.Errorf("uploaded %q", audio)
+	}
+	if res.Text != "hello world" || res.Language != "english" || res.Duration != 1.5 {
+		t.Errorf("Result = %+v", res)
+	}
+	if len(res.Words) != 1 || res.Words[0].Word != "hello" || len(res.Segments) != 1 {
+		t.Errorf("words %+v, segments %+v", res.Words, res.Segments)
+	}
+	if want := math.Exp(-0.1); math.Abs(res.Confidence-want) > 1e-9 {
+		t.Errorf("Confidence = %v, want %v", res.Confidence, want)
+	}
+}
+
+func TestFileDefaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f := r.FormValue("response_format"); f != FormatJSON {
+			t.Errorf("response_format = %q, want %q", f, FormatJSON)
+		}
+		for _, k := range []string{"language", "prompt", "timestamp_granularities[]"} {
+			if _, ok := r.MultipartForm.Value[k]; ok {
+				t.Errorf("%s sent without being set", k)
+			}
+		}
+		w.Write([]byte(`{"text":"hi"}`))
+	}))
+	defer srv.Close()
+
+	res, err := File(Request{URL: srv.URL, Model: "m", WordTimings: true}, writeAudio(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "hi" || res.Confidence != 0 {
+		t.Errorf("Result = %+v", res)
+	}
+}
+
+func TestFileText(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("  plain text\n"))
+	}))
+	defer srv.Close()
+
+	res, err := File(Request{URL: srv.URL, Format: FormatText}, writeAudio(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "plain text" {
+		t.Errorf("Text = %q", res.Text)
+	}
+}
+
+func TestFileAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := File(Request{URL: srv.URL, Name: "groq"}, writeAudio(t))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an *APIError", err)
+	}
+	if apiErr.Provider != "groq" || apiErr.Status != http.StatusTooManyRequests {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestFileContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server only sees the client go away once the body is read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := FileContext(ctx, Request{URL: srv.URL}, writeAudio(t))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want one wrapping context.DeadlineExceeded", err)
+	}
+}
+
+func TestFileMissing(t *testing.T) {
+	if _, err := File(Request{URL: "http://127.0.0.1:1"}, filepath.Join(t.TempDir(), "none.wav")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestSegmentConfidence(t *testing.T) {
+	if c := SegmentConfidence(nil); c != 0 {
+		t.Errorf("no segments: %v", c)
+	}
+	segs := []Segment{{AvgLogprob: 0}, {AvgLogprob: math.Log(0.5)}}
+	if c := SegmentConfidence(segs); math.Abs(c-0.75) > 1e-9 {
+		t.Errorf("SegmentConfidence = %v, want 0.75", c)
+	}
+}